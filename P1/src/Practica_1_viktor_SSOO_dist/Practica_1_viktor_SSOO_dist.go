@@ -2,10 +2,17 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
 	"fmt"
+	"net/http"
+	"net/smtp"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 type Cliente struct {
@@ -13,6 +20,7 @@ type Cliente struct {
 	Nombre    string
 	Telefono  string
 	Email     string
+	Aviso     string   // "email", "sms", "ninguno"
 	Vehiculos []string // matrículas
 }
 
@@ -53,6 +61,9 @@ type Taller struct {
 	nextCli     int
 	nextInc     int
 	nextMec     int
+	avisos      map[string]Notificador // canal --> notificador
+	esperaAviso time.Duration          // espera antes del primer reintento, luego el doble
+	enviando    sync.WaitGroup         // avisos que aún se están enviando
 }
 
 // ----------------------------- Utilidades de entrada simples -----------------------------
@@ -116,6 +127,8 @@ func nuevoTaller() *Taller {
 		Vehiculos:   map[string]*Vehiculo{},
 		Incidencias: map[int]*Incidencia{},
 		Mecanicos:   map[int]*Mecanico{},
+		avisos:      map[string]Notificador{},
+		esperaAviso: time.Second,
 	}
 }
 
@@ -144,14 +157,146 @@ func (t *Taller) plazasLibres() int {
 	return t.capacidadMaxima() - t.plazasOcupadas()
 }
 
+// ----------------------------- Avisos a clientes -----------------------------
+
+// Notificador envía un aviso a un destino (email o teléfono)
+type Notificador interface {
+	Enviar(destino, asunto, mensaje string) error
+}
+
+// Aviso por email a través de un servidor SMTP
+type notificadorEmail struct {
+	servidor  string // host:puerto
+	remitente string
+}
+
+func (n notificadorEmail) Enviar(destino, asunto, mensaje string) error {
+	correo := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\n\r\n%s\r\n", n.remitente, destino, asunto, mensaje)
+	return smtp.SendMail(n.servidor, nil, n.remitente, []string{destino}, []byte(correo))
+}
+
+// Aviso por SMS a través de una pasarela HTTP
+type notificadorSMS struct {
+	url string
+}
+
+func (n notificadorSMS) Enviar(destino, asunto, mensaje string) error {
+	cuerpo, _ := json.Marshal(map[string]string{"telefono": destino, "mensaje": mensaje})
+	resp, err := http.Post(n.url, "application/json", bytes.NewReader(cuerpo))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("la pasarela respondió %s", resp.Status)
+	}
+	return nil
+}
+
+// Deja el aviso en un archivo de salida, una línea por aviso
+type notificadorBuzon struct {
+	ruta string
+}
+
+func (n notificadorBuzon) Enviar(destino, asunto, mensaje string) error {
+	f, err := os.OpenFile(n.ruta, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = fmt.Fprintf(f, "%s | %s | %s | %s\n", time.Now().Format("2006-01-02 15:04:05"), destino, asunto, mensaje)
+	return err
+}
+
+// avisar manda el aviso al cliente por su canal preferido sin esperar a que
+// llegue, para no parar el menú mientras se reintenta. Si el canal no está
+// configurado o no se consigue enviar, el aviso va al buzón.
+func (t *Taller) avisar(c *Cliente, asunto, mensaje string) {
+	canal := c.Aviso
+	if canal == "" { // clientes sin preferencia: email si tienen, si no SMS
+		canal = "email"
+		if c.Email == "" {
+			canal = "sms"
+		}
+	}
+	if canal == "ninguno" {
+		return
+	}
+	destino := c.Email
+	if canal == "sms" {
+		destino = c.Telefono
+	}
+	if destino == "" {
+		fmt.Printf("No se puede avisar a %s: no tiene %s\n", c.Nombre, canal)
+		return
+	}
+
+	n, ok := t.avisos[canal]
+	if !ok {
+		canal = "buzon"
+		n, ok = t.avisos[canal]
+	}
+	if !ok {
+		return
+	}
+
+	nombre := c.Nombre
+	t.enviando.Add(1)
+	go func() {
+		defer t.enviando.Done()
+		err := t.enviarConReintentos(n, destino, asunto, mensaje)
+		if err == nil {
+			fmt.Printf("Aviso enviado a %s (%s)\n", nombre, canal)
+			return
+		}
+		if buzon, ok := t.avisos["buzon"]; ok && canal != "buzon" {
+			if buzon.Enviar(destino, asunto, mensaje) == nil {
+				fmt.Printf("No se pudo avisar a %s por %s, el aviso queda en el buzón\n", nombre, canal)
+				return
+			}
+		}
+		fmt.Printf("No se pudo avisar a %s: %v\n", nombre, err)
+	}()
+}
+
+// enviarConReintentos prueba hasta 3 veces, doblando la espera entre
+// intentos, y devuelve el último error
+func (t *Taller) enviarConReintentos(n Notificador, destino, asunto, mensaje string) error {
+	espera := t.esperaAviso
+	var err error
+	for intento := 1; intento <= 3; intento++ {
+		if err = n.Enviar(destino, asunto, mensaje); err == nil {
+			return nil
+		}
+		if intento < 3 {
+			time.Sleep(espera)
+			espera *= 2
+		}
+	}
+	return err
+}
+
+// Avisa al dueño del vehículo de que ya puede retirarlo
+func (t *Taller) avisarIncidenciaCerrada(inc *Incidencia) {
+	v, ok := t.Vehiculos[inc.Matricula]
+	if !ok {
+		return
+	}
+	if c, ok := t.Clientes[v.IDCliente]; ok {
+		t.avisar(c, "Su vehículo "+v.Matricula+" está listo",
+			fmt.Sprintf("Hola %s, hemos terminado la reparación de su %s %s (%s). Ya puede pasar a retirarlo.", c.Nombre, v.Marca, v.Modelo, v.Matricula))
+	}
+}
+
 // ----------------------------- CLIENTES -----------------------------
 func (t *Taller) crearCliente() {
 	n := leer("Nombre: ")
 	tel := leer("Teléfono: ")
 	em := leer("Email: ")
+	aviso := elegirOpcion("¿Cómo quiere que le avisemos?", []string{"email", "sms", "ninguno"})
 	t.nextCli++
 	id := t.nextCli
-	t.Clientes[id] = &Cliente{ID: id, Nombre: n, Telefono: tel, Email: em}
+	t.Clientes[id] = &Cliente{ID: id, Nombre: n, Telefono: tel, Email: em, Aviso: aviso}
 	fmt.Println("Cliente creado con ID:", id)
 	fmt.Println()
 }
@@ -162,7 +307,7 @@ func (t *Taller) verClientes() {
 		return
 	}
 	for _, c := range t.Clientes {
-		fmt.Printf("ID: %d | Nombre: %s | Telefono: %s | Email: %s | Vehículos: %v\n\n", c.ID, c.Nombre, c.Telefono, c.Email, c.Vehiculos)
+		fmt.Printf("ID: %d | Nombre: %s | Telefono: %s | Email: %s | Aviso: %s | Vehículos: %v\n\n", c.ID, c.Nombre, c.Telefono, c.Email, c.Aviso, c.Vehiculos)
 	}
 }
 
@@ -183,6 +328,9 @@ func (t *Taller) modificarCliente() {
 	if v := leer("Nuevo email (enter para no modificar): "); v != "" {
 		c.Email = v
 	}
+	if v := leer("Nuevo aviso: email, sms o ninguno (enter para no modificar): "); v == "email" || v == "sms" || v == "ninguno" {
+		c.Aviso = v
+	}
 	fmt.Println("Cliente actualizado con exito")
 	fmt.Println()
 }
@@ -361,6 +509,9 @@ func (t *Taller) modificarIncidencia() {
 	entrada := leer("Opción (1-3, enter para no modificar): ")
 	if entrada != "" {
 		if op, err := strconv.Atoi(entrada); err == nil && op >= 1 && op <= len(estados) {
+			if estados[op-1] == "cerrada" && i.Estado != "cerrada" {
+				defer t.avisarIncidenciaCerrada(i)
+			}
 			i.Estado = estados[op-1]
 		} else {
 			fmt.Println("Entrada inválida, se mantiene el estado actual")
@@ -547,6 +698,10 @@ func (t *Taller) retirarVehiculoDeTaller() {
 	v.EnTaller = false
 	v.FechaEntrada, v.FechaSalidaEst = "", ""
 	fmt.Printf("Retirado %s. Libres: %d\n", mat, t.plazasLibres())
+	if c, ok := t.Clientes[v.IDCliente]; ok {
+		t.avisar(c, "Su vehículo "+mat+" ha salido del taller",
+			fmt.Sprintf("Hola %s, le confirmamos la entrega de su %s %s (%s). Gracias por su confianza.", c.Nombre, v.Marca, v.Modelo, mat))
+	}
 }
 
 func (t *Taller) verEstadoTaller() {
@@ -565,8 +720,12 @@ func (t *Taller) cambiarEstadoIncidencia() {
 	fmt.Printf("Estado actual: %s\n", i.Estado)
 	nuevo := elegirOpcion("Nuevo estado:", estados)
 
+	cerrada := nuevo == "cerrada" && i.Estado != "cerrada"
 	i.Estado = nuevo
 	fmt.Println("Incidencia actualizada con exito")
+	if cerrada {
+		t.avisarIncidenciaCerrada(i)
+	}
 }
 
 func (t *Taller) listarIncidenciasDeVehiculo() {
@@ -690,7 +849,22 @@ func menu() {
 
 // ----------------------------- Main -----------------------------
 func main() {
+	servidorSMTP := flag.String("smtp", "", "servidor SMTP (host:puerto) para los avisos por email")
+	remitente := flag.String("remitente", "taller@localhost", "remitente de los avisos por email")
+	pasarelaSMS := flag.String("sms", "", "URL de la pasarela para los avisos por SMS")
+	buzon := flag.String("buzon", "", "archivo para los avisos que no se pueden enviar (vacío para descartarlos)")
+	flag.Parse()
+
 	t := nuevoTaller()
+	if *servidorSMTP != "" {
+		t.avisos["email"] = notificadorEmail{servidor: *servidorSMTP, remitente: *remitente}
+	}
+	if *pasarelaSMS != "" {
+		t.avisos["sms"] = notificadorSMS{url: *pasarelaSMS}
+	}
+	if *buzon != "" {
+		t.avisos["buzon"] = notificadorBuzon{ruta: *buzon}
+	}
 	for {
 		menu()
 		op := leerInt("Opción: ")
@@ -806,6 +980,7 @@ func main() {
 		case 15:
 			t.listarTodasIncidenciasConEstado()
 		case 0:
+			t.enviando.Wait() // que no se pierdan los avisos pendientes
 			fmt.Println("\n¡Hasta luego!")
 			fmt.Println()
 			return
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// servidorSMTPFalso acepta correos en 127.0.0.1 y manda por el canal el
+// contenido de cada uno
+func servidorSMTPFalso(t *testing.T) (string, <-chan string) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	correos := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go atenderSMTP(conn, correos)
+		}
+	}()
+	return ln.Addr().String(), correos
+}
+
+func atenderSMTP(conn net.Conn, correos chan<- string) {
+	defer conn.Close()
+	lector := bufio.NewReader(conn)
+	responder := func(linea string) { conn.Write([]byte(linea + "\r\n")) }
+
+	responder("220 localhost ESMTP")
+	for {
+		linea, err := lector.ReadString('\n')
+		if err != nil {
+			return
+		}
+		orden := strings.ToUpper(strings.TrimSpace(linea))
+		switch {
+		case strings.HasPrefix(orden, "EHLO"), strings.HasPrefix(orden, "HELO"):
+			responder("250 localhost")
+		case orden == "DATA":
+			responder("354 adelante")
+			var cuerpo strings.Builder
+			for {
+				l, err := lector.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				cuerpo.WriteString(l)
+			}
+			correos <- cuerpo.String()
+			responder("250 recibido")
+		case orden == "QUIT":
+			responder("221 adiós")
+			return
+		default:
+			responder("250 OK")
+		}
+	}
+}
+
+// notificadorFallido falla las primeras veces que se le llama
+type notificadorFallido struct {
+	mutex    sync.Mutex
+	fallos   int
+	llamadas int
+}
+
+func (f *notificadorFallido) Enviar(destino, asunto, mensaje string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.llamadas++
+	if f.llamadas <= f.fallos {
+		return errors.New("canal caído")
+	}
+	return nil
+}
+
+// TestNotificadores prueba cada canal por separado
+func TestNotificadores(t *testing.T) {
+	servidor, correos := servidorSMTPFalso(t)
+	email := notificadorEmail{servidor: servidor, remitente: "taller@test.com"}
+	if err := email.Enviar("ana@test.com", "Su vehículo está listo", "Puede recogerlo"); err != nil {
+		t.Fatalf("email: %v", err)
+	}
+	if correo := <-correos; !strings.Contains(correo, "Subject: Su vehículo está listo") || !strings.Contains(correo, "Puede recogerlo") {
+		t.Fatalf("correo incorrecto:\n%s", correo)
+	}
+
+	var recibido map[string]string
+	pasarela := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&recibido)
+	}))
+	defer pasarela.Close()
+	if err := (notificadorSMS{url: pasarela.URL}).Enviar("600000001", "", "Puede recogerlo"); err != nil {
+		t.Fatalf("sms: %v", err)
+	}
+	if recibido["telefono"] != "600000001" || recibido["mensaje"] != "Puede recogerlo" {
+		t.Fatalf("SMS incorrecto: %v", recibido)
+	}
+	caida := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer caida.Close()
+	if err := (notificadorSMS{url: caida.URL}).Enviar("600000001", "", "Puede recogerlo"); err == nil {
+		t.Fatal("se esperaba error si la pasarela rechaza el envío")
+	}
+
+	buzon := notificadorBuzon{ruta: filepath.Join(t.TempDir(), "avisos.txt")}
+	buzon.Enviar("ana@test.com", "Uno", "Primero")
+	buzon.Enviar("ana@test.com", "Dos", "Segundo")
+	if lineas := leerBuzon(t, buzon.ruta); len(lineas) != 2 || !strings.Contains(lineas[1], "Dos | Segundo") {
+		t.Fatalf("buzón inesperado: %q", lineas)
+	}
+}
+
+func leerBuzon(t *testing.T, ruta string) []string {
+	t.Helper()
+	datos, err := os.ReadFile(ruta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSpace(string(datos)), "\n")
+}
+
+// TestAvisar comprueba la preferencia de cada cliente, los reintentos y que
+// lo que no se puede enviar acaba en el buzón sin parar el menú
+func TestAvisar(t *testing.T) {
+	taller := nuevoTaller()
+	taller.esperaAviso = 100 * time.Millisecond
+	email := &notificadorFallido{fallos: 2}
+	sms := &notificadorFallido{fallos: 5}
+	taller.avisos["email"] = email
+	taller.avisos["sms"] = sms
+	ruta := filepath.Join(t.TempDir(), "avisos.txt")
+	taller.avisos["buzon"] = notificadorBuzon{ruta: ruta}
+
+	empezado := time.Now()
+	taller.avisar(&Cliente{Nombre: "Ana", Email: "ana@test.com", Telefono: "600000001"}, "Listo", "Puede recogerlo")
+	taller.avisar(&Cliente{Nombre: "Luis", Email: "luis@test.com", Telefono: "600000002", Aviso: "sms"}, "Listo", "Puede recogerlo")
+	taller.avisar(&Cliente{Nombre: "Eva", Email: "eva@test.com", Aviso: "ninguno"}, "Listo", "Puede recogerlo")
+	if espera := time.Since(empezado); espera >= taller.esperaAviso {
+		t.Fatalf("avisar paró el menú %v", espera)
+	}
+	taller.enviando.Wait()
+
+	// Ana prefiere email por defecto y llega al tercer intento; el SMS de
+	// Luis falla las tres veces y se queda en el buzón
+	if email.llamadas != 3 || sms.llamadas != 3 {
+		t.Fatalf("intentos: email %d, sms %d", email.llamadas, sms.llamadas)
+	}
+	if lineas := leerBuzon(t, ruta); len(lineas) != 1 || !strings.Contains(lineas[0], "600000002") {
+		t.Fatalf("buzón inesperado: %q", lineas)
+	}
+}
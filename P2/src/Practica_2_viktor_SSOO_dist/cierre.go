@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// ResumenCierre cuenta qué pasó con el trabajo pendiente al cerrar el taller
+type ResumenCierre struct {
+	// Terminados son los trabajos que estaban en reparación y acabaron antes
+	// del plazo
+	Terminados []TrabajoEnCola `json:"terminados"`
+	// Abandonados son los que no llegaron a empezar o no acabaron a tiempo
+	Abandonados  []TrabajoEnCola `json:"abandonados"`
+	PlazoAgotado bool            `json:"plazo_agotado"`
+	Duracion     time.Duration   `json:"duracion"`
+}
+
+// Cerrar deja de aceptar trabajos, saca de las colas los que no han empezado
+// y espera a que los mecánicos acaben lo que tienen entre manos, como mucho
+// hasta que ctx termine. Los trabajos que siguen a medias al vencer el plazo
+// se dan por abandonados.
+func (t *Taller) Cerrar(ctx context.Context) (ResumenCierre, error) {
+	inicio := t.reloj.Ahora()
+
+	t.mutex.Lock()
+	if t.cerrando {
+		t.mutex.Unlock()
+		return ResumenCierre{}, fmt.Errorf("el taller ya está cerrado")
+	}
+	t.cerrando = true
+	repartiendo := t.repartiendo
+	enCurso := make(map[int]TrabajoPendiente)
+	for id, s := range t.trabajos {
+		if !s.inicio.IsZero() {
+			enCurso[id] = s.trabajo
+		}
+	}
+	t.mutex.Unlock()
+
+	// Primero se para el reparto para que nadie más escriba en las colas
+	close(t.terminar)
+	if repartiendo {
+		<-t.repartoParado
+	}
+
+	// La cola GENERAL se vacía mientras terminan los envíos que ya habían
+	// empezado
+	enviosHechos := make(chan struct{})
+	go func() {
+		t.envios.Wait()
+		close(enviosHechos)
+	}()
+	for vaciando := true; vaciando; {
+		select {
+		case trabajo := <-t.colaTrabajos:
+			t.abandonarTrabajo(trabajo)
+		case <-enviosHechos:
+			vaciando = false
+		}
+	}
+	for len(t.colaTrabajos) > 0 {
+		t.abandonarTrabajo(<-t.colaTrabajos)
+	}
+
+	// Con las colas personales cerradas cada mecánico acaba su trabajo actual
+	// y descarta el resto
+	t.mutex.Lock()
+	for _, cola := range t.colasPersonales {
+		close(cola)
+	}
+	t.mutex.Unlock()
+
+	rutinasHechas := make(chan struct{})
+	go func() {
+		t.rutinas.Wait()
+		close(rutinasHechas)
+	}()
+
+	resumen := ResumenCierre{
+		Terminados:  make([]TrabajoEnCola, 0),
+		Abandonados: make([]TrabajoEnCola, 0),
+	}
+	select {
+	case <-rutinasHechas:
+	case <-ctx.Done():
+		resumen.PlazoAgotado = true
+	}
+
+	ahora := t.reloj.Ahora()
+	t.mutex.Lock()
+	for id, trabajo := range enCurso {
+		if _, pendiente := t.trabajos[id]; !pendiente {
+			resumen.Terminados = append(resumen.Terminados, nuevoTrabajoEnCola(trabajo, ahora))
+		}
+	}
+	for _, trabajo := range t.abandonados {
+		resumen.Abandonados = append(resumen.Abandonados, nuevoTrabajoEnCola(trabajo, ahora))
+	}
+	for _, s := range t.trabajos {
+		resumen.Abandonados = append(resumen.Abandonados, nuevoTrabajoEnCola(s.trabajo, ahora))
+	}
+	t.mutex.Unlock()
+
+	sort.Slice(resumen.Terminados, func(i, j int) bool {
+		return resumen.Terminados[i].TrabajoID < resumen.Terminados[j].TrabajoID
+	})
+	sort.Slice(resumen.Abandonados, func(i, j int) bool {
+		return resumen.Abandonados[i].TrabajoID < resumen.Abandonados[j].TrabajoID
+	})
+	resumen.Duracion = t.reloj.Desde(inicio)
+
+	t.eventos.Cerrar()
+	return resumen, nil
+}
+
+// abandonarTrabajo aparta un trabajo que no se va a hacer por el cierre
+func (t *Taller) abandonarTrabajo(trabajo TrabajoPendiente) {
+	t.mutex.Lock()
+	t.abandonados = append(t.abandonados, trabajo)
+	delete(t.trabajos, trabajo.ID)
+	t.mutex.Unlock()
+
+	t.eventos.Publicar(Evento{Tipo: TrabajoAbandonado, IncidenciaID: trabajo.Incidencia.ID, VehiculoID: trabajo.Vehiculo.ID})
+	if t.wg != nil {
+		t.wg.Done()
+	}
+}
+
+// Imprimir escribe el resumen para el usuario
+func (r ResumenCierre) Imprimir(w io.Writer) {
+	fmt.Fprintf(w, "Cierre en %v: %d trabajo(s) terminados, %d abandonado(s)\n",
+		r.Duracion.Round(time.Millisecond), len(r.Terminados), len(r.Abandonados))
+	if r.PlazoAgotado {
+		fmt.Fprintln(w, "Se agotó el plazo antes de que los mecánicos acabaran")
+	}
+	for _, tr := range r.Abandonados {
+		fmt.Fprintf(w, "  - pendiente: %s (incidencia %d, %s, prioridad %s)\n", tr.Matricula, tr.IncidenciaID, tr.Tipo, tr.Prioridad)
+	}
+}
+
+// GuardarAbandonados escribe en JSON los trabajos abandonados para poder
+// retomarlos más adelante
+func (r ResumenCierre) GuardarAbandonados(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r.Abandonados)
+}
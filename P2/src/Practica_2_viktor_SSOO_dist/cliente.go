@@ -2,11 +2,13 @@ package main
 
 import (
 	"fmt"
+	"sync"
 )
 
 type ClienteManager struct {
 	clientes []Cliente
 	nextID   int
+	mutex    sync.RWMutex
 }
 
 func NewClienteManager() *ClienteManager {
@@ -17,6 +19,9 @@ func NewClienteManager() *ClienteManager {
 }
 
 func (cm *ClienteManager) CrearCliente(nombre, telefono, email string) Cliente {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+
 	cliente := Cliente{
 		ID:       cm.nextID,
 		Nombre:   nombre,
@@ -29,6 +34,9 @@ func (cm *ClienteManager) CrearCliente(nombre, telefono, email string) Cliente {
 }
 
 func (cm *ClienteManager) ObtenerCliente(id int) (Cliente, bool) {
+	cm.mutex.RLock()
+	defer cm.mutex.RUnlock()
+
 	for i := 0; i < len(cm.clientes); i++ {
 		if cm.clientes[i].ID == id {
 			return cm.clientes[i], true
@@ -38,6 +46,9 @@ func (cm *ClienteManager) ObtenerCliente(id int) (Cliente, bool) {
 }
 
 func (cm *ClienteManager) ActualizarCliente(id int, nombre, telefono, email string) error {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+
 	for i := 0; i < len(cm.clientes); i++ {
 		if cm.clientes[i].ID == id {
 			if nombre != "" {
@@ -56,6 +67,9 @@ func (cm *ClienteManager) ActualizarCliente(id int, nombre, telefono, email stri
 }
 
 func (cm *ClienteManager) EliminarCliente(id int) error {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+
 	for i := 0; i < len(cm.clientes); i++ {
 		if cm.clientes[i].ID == id {
 			cm.clientes = append(cm.clientes[:i], cm.clientes[i+1:]...)
@@ -66,5 +80,8 @@ func (cm *ClienteManager) EliminarCliente(id int) error {
 }
 
 func (cm *ClienteManager) ListarClientes() []Cliente {
-	return cm.clientes
+	cm.mutex.RLock()
+	defer cm.mutex.RUnlock()
+
+	return append([]Cliente(nil), cm.clientes...)
 }
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// seguimientoTrabajo es lo que el taller sabe de un trabajo que aún no ha
+// terminado: a quién se asignó y cuándo empezó
+type seguimientoTrabajo struct {
+	trabajo    TrabajoPendiente
+	mecanicoID int // 0 mientras está en la cola GENERAL
+	ayudantes  []int
+	inicio     time.Time // cero mientras espera en la cola del mecánico
+}
+
+func (t *Taller) registrarTrabajo(trabajo TrabajoPendiente) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.trabajos[trabajo.ID] = &seguimientoTrabajo{trabajo: trabajo}
+}
+
+func (t *Taller) registrarAsignacion(trabajoID, mecanicoID int) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if s, existe := t.trabajos[trabajoID]; existe {
+		s.mecanicoID = mecanicoID
+	}
+}
+
+func (t *Taller) registrarAyudante(trabajoID, mecanicoID int) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if s, existe := t.trabajos[trabajoID]; existe {
+		s.ayudantes = append(s.ayudantes, mecanicoID)
+	}
+}
+
+// registrarInicio apunta que el trabajo empieza, salvo que el taller esté
+// cerrando. Se mira con el mismo cerrojo con el que Cerrar decide qué
+// trabajos estaban en curso, para que ninguno se quede fuera del resumen.
+func (t *Taller) registrarInicio(trabajoID int, inicio time.Time) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.cerrando {
+		return false
+	}
+	if s, existe := t.trabajos[trabajoID]; existe {
+		s.inicio = inicio
+	}
+	return true
+}
+
+func (t *Taller) registrarFin(trabajoID int) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	delete(t.trabajos, trabajoID)
+}
+
+// TrabajoEnCola resume un trabajo que espera, en la cola GENERAL o en la de
+// un mecánico
+type TrabajoEnCola struct {
+	TrabajoID    int            `json:"trabajo_id"`
+	VehiculoID   int            `json:"vehiculo_id"`
+	Matricula    string         `json:"matricula"`
+	IncidenciaID int            `json:"incidencia_id"`
+	Tipo         TipoIncidencia `json:"tipo"`
+	Prioridad    Prioridad      `json:"prioridad"`
+	Espera       time.Duration  `json:"espera"`
+}
+
+// TrabajoEnCurso es un trabajo que ya se está reparando
+type TrabajoEnCurso struct {
+	TrabajoEnCola
+	Inicio       time.Time     `json:"inicio"`
+	Transcurrido time.Duration `json:"transcurrido"`
+	Ayudante     bool          `json:"ayudante"`
+}
+
+// EstadoMecanico es la carga de un mecánico en un instante
+type EstadoMecanico struct {
+	ID             int              `json:"id"`
+	Nombre         string           `json:"nombre"`
+	Especialidad   Especialidad     `json:"especialidad"`
+	Habilidades    []Habilidad      `json:"habilidades"`
+	Activo         bool             `json:"activo"`
+	PlazasOcupadas int              `json:"plazas_ocupadas"`
+	Capacidad      int              `json:"capacidad"`
+	Cola           []TrabajoEnCola  `json:"cola"`
+	EnCurso        []TrabajoEnCurso `json:"en_curso"`
+}
+
+// EstadoTaller es una foto del taller: la cola GENERAL y la carga de cada
+// mecánico
+type EstadoTaller struct {
+	Instante         time.Time        `json:"instante"`
+	MecanicosActivos int              `json:"mecanicos_activos"`
+	PlazasTotales    int              `json:"plazas_totales"`
+	Cola             []TrabajoEnCola  `json:"cola"`
+	Mecanicos        []EstadoMecanico `json:"mecanicos"`
+}
+
+func nuevoTrabajoEnCola(trabajo TrabajoPendiente, ahora time.Time) TrabajoEnCola {
+	return TrabajoEnCola{
+		TrabajoID:    trabajo.ID,
+		VehiculoID:   trabajo.Vehiculo.ID,
+		Matricula:    trabajo.Vehiculo.Matricula,
+		IncidenciaID: trabajo.Incidencia.ID,
+		Tipo:         trabajo.Incidencia.Tipo,
+		Prioridad:    trabajo.Incidencia.Prioridad,
+		Espera:       ahora.Sub(trabajo.TiempoInicio),
+	}
+}
+
+// Estado devuelve la foto actual del taller. Los trabajos aparecen por orden
+// de llegada.
+func (t *Taller) Estado() EstadoTaller {
+	ahora := t.reloj.Ahora()
+	mecanicos := t.mecanicoManager.ListarMecanicos()
+
+	estado := EstadoTaller{
+		Instante:         ahora,
+		MecanicosActivos: t.mecanicoManager.ContarMecanicosActivos(),
+		Cola:             make([]TrabajoEnCola, 0),
+		Mecanicos:        make([]EstadoMecanico, 0, len(mecanicos)),
+	}
+	estado.PlazasTotales = estado.MecanicosActivos * PlazasPorMecanico
+
+	porMecanico := make(map[int]int, len(mecanicos))
+	for _, m := range mecanicos {
+		porMecanico[m.ID] = len(estado.Mecanicos)
+		estado.Mecanicos = append(estado.Mecanicos, EstadoMecanico{
+			ID:             m.ID,
+			Nombre:         m.Nombre,
+			Especialidad:   m.Especialidad,
+			Habilidades:    m.Habilidades,
+			Activo:         m.Activo,
+			PlazasOcupadas: m.PlazasOcupadas,
+			Capacidad:      PlazasPorMecanico,
+			Cola:           make([]TrabajoEnCola, 0),
+			EnCurso:        make([]TrabajoEnCurso, 0),
+		})
+	}
+
+	t.mutex.Lock()
+	seguimientos := make([]*seguimientoTrabajo, 0, len(t.trabajos))
+	for _, s := range t.trabajos {
+		copia := *s
+		copia.ayudantes = append([]int(nil), s.ayudantes...)
+		seguimientos = append(seguimientos, &copia)
+	}
+	t.mutex.Unlock()
+
+	sort.Slice(seguimientos, func(i, j int) bool {
+		return seguimientos[i].trabajo.ID < seguimientos[j].trabajo.ID
+	})
+
+	for _, s := range seguimientos {
+		enCola := nuevoTrabajoEnCola(s.trabajo, ahora)
+		i, asignado := porMecanico[s.mecanicoID]
+		if !asignado {
+			estado.Cola = append(estado.Cola, enCola)
+			continue
+		}
+		if s.inicio.IsZero() {
+			estado.Mecanicos[i].Cola = append(estado.Mecanicos[i].Cola, enCola)
+			continue
+		}
+
+		enCurso := TrabajoEnCurso{
+			TrabajoEnCola: enCola,
+			Inicio:        s.inicio,
+			Transcurrido:  ahora.Sub(s.inicio),
+		}
+		estado.Mecanicos[i].EnCurso = append(estado.Mecanicos[i].EnCurso, enCurso)
+		for _, ayudanteID := range s.ayudantes {
+			if j, existe := porMecanico[ayudanteID]; existe {
+				enCurso.Ayudante = true
+				estado.Mecanicos[j].EnCurso = append(estado.Mecanicos[j].EnCurso, enCurso)
+			}
+		}
+	}
+	return estado
+}
+
+// FormatoEstado indica cómo se muestra el estado del taller
+type FormatoEstado string
+
+const (
+	FormatoTexto FormatoEstado = "texto"
+	FormatoTabla FormatoEstado = "tabla"
+	FormatoJSON  FormatoEstado = "json"
+)
+
+// RenderizarEstado escribe el estado en w con el formato indicado
+func RenderizarEstado(w io.Writer, estado EstadoTaller, formato FormatoEstado) error {
+	switch formato {
+	case FormatoTexto:
+		return renderizarTexto(w, estado)
+	case FormatoTabla:
+		return renderizarTabla(w, estado)
+	case FormatoJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(estado)
+	}
+	return fmt.Errorf("formato de estado '%s' no válido", formato)
+}
+
+func renderizarTexto(w io.Writer, estado EstadoTaller) error {
+	fmt.Fprintln(w, "\n===== ESTADO DEL TALLER =====")
+
+	fmt.Fprintf(w, "Plazas totales: %d (%d mecánicos x %d)\n", estado.PlazasTotales, estado.MecanicosActivos, PlazasPorMecanico)
+	fmt.Fprintf(w, "Trabajos en cola GENERAL: %d\n", len(estado.Cola))
+
+	fmt.Fprintln(w, "----- Carga de Mecánicos -----")
+	for _, m := range estado.Mecanicos {
+		if m.Activo {
+			fmt.Fprintf(w, "  - %s (%s): %d/%d Plazas Ocupadas\n", m.Nombre, m.Especialidad, m.PlazasOcupadas, m.Capacidad)
+			for _, tr := range m.EnCurso {
+				fmt.Fprintf(w, "      reparando %s (%s) desde hace %v\n", tr.Matricula, tr.Tipo, tr.Transcurrido.Round(time.Second))
+			}
+			for _, tr := range m.Cola {
+				fmt.Fprintf(w, "      en espera %s (%s)\n", tr.Matricula, tr.Tipo)
+			}
+			fmt.Fprintln(w)
+		}
+	}
+	_, err := fmt.Fprintln(w, "=============================")
+	return err
+}
+
+func renderizarTabla(w io.Writer, estado EstadoTaller) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "PLAZAS TOTALES: %d (%d mecánicos activos)\n\n", estado.PlazasTotales, estado.MecanicosActivos)
+
+	fmt.Fprintf(tw, "COLA GENERAL (%d)\n", len(estado.Cola))
+	fmt.Fprintln(tw, "TRABAJO\tMATRÍCULA\tINCIDENCIA\tTIPO\tPRIORIDAD\tESPERA")
+	for _, tr := range estado.Cola {
+		fmt.Fprintf(tw, "%d\t%s\t%d\t%s\t%s\t%v\n",
+			tr.TrabajoID, tr.Matricula, tr.IncidenciaID, tr.Tipo, tr.Prioridad, tr.Espera.Round(time.Second))
+	}
+
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "MECÁNICOS")
+	fmt.Fprintln(tw, "ID\tNOMBRE\tESPECIALIDAD\tACTIVO\tPLAZAS\tREPARANDO\tEN ESPERA")
+	for _, m := range estado.Mecanicos {
+		reparando := "-"
+		for i, tr := range m.EnCurso {
+			texto := fmt.Sprintf("%s (%v)", tr.Matricula, tr.Transcurrido.Round(time.Second))
+			if i == 0 {
+				reparando = texto
+			} else {
+				reparando += ", " + texto
+			}
+		}
+		enEspera := "-"
+		for i, tr := range m.Cola {
+			if i == 0 {
+				enEspera = tr.Matricula
+			} else {
+				enEspera += ", " + tr.Matricula
+			}
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%t\t%d/%d\t%s\t%s\n",
+			m.ID, m.Nombre, m.Especialidad, m.Activo, m.PlazasOcupadas, m.Capacidad, reparando, enEspera)
+	}
+	return tw.Flush()
+}
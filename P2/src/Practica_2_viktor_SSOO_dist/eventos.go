@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// BUS DE EVENTOS
+// ============================================================================
+
+// TipoEvento identifica qué ha pasado en el taller
+type TipoEvento string
+
+const (
+	IncidenciaCreada         TipoEvento = "IncidenciaCreada"
+	IncidenciaEstadoCambiado TipoEvento = "IncidenciaEstadoCambiado"
+	TrabajoEncolado          TipoEvento = "TrabajoEncolado"
+	TrabajoAsignado          TipoEvento = "TrabajoAsignado"
+	TrabajoIniciado          TipoEvento = "TrabajoIniciado"
+	TrabajoTerminado         TipoEvento = "TrabajoTerminado"
+	MecanicoAdicional        TipoEvento = "MecanicoAdicional"
+	TrabajoAbandonado        TipoEvento = "TrabajoAbandonado"
+	MecanicoContratado       TipoEvento = "MecanicoContratado"
+	MecanicoDadoDeAlta       TipoEvento = "MecanicoDadoDeAlta"
+	MecanicoDadoDeBaja       TipoEvento = "MecanicoDadoDeBaja"
+)
+
+// Evento describe un cambio en el taller. Solo se rellenan los campos que
+// tienen sentido para cada tipo.
+type Evento struct {
+	Tipo         TipoEvento       `json:"tipo"`
+	Instante     time.Time        `json:"instante"`
+	IncidenciaID int              `json:"incidencia_id,omitempty"`
+	VehiculoID   int              `json:"vehiculo_id,omitempty"`
+	MecanicoID   int              `json:"mecanico_id,omitempty"`
+	Estado       EstadoIncidencia `json:"estado,omitempty"`
+	Duracion     time.Duration    `json:"duracion,omitempty"`
+}
+
+// suscripcion guarda los eventos pendientes de un suscriptor. Cada
+// suscriptor tiene su propia goroutine, así que uno lento no frena a quien
+// publica ni a los demás suscriptores.
+type suscripcion struct {
+	tipos      map[TipoEvento]bool
+	manejador  func(Evento)
+	pendientes []Evento
+	avisos     chan struct{}
+	terminada  chan struct{}
+	cancelada  bool
+	mutex      sync.Mutex
+}
+
+// BusEventos reparte los eventos publicados entre sus suscriptores
+type BusEventos struct {
+	suscripciones map[int]*suscripcion
+	nextID        int
+	cerrado       bool
+	mutex         sync.Mutex
+}
+
+// NewBusEventos crea un bus sin suscriptores
+func NewBusEventos() *BusEventos {
+	return &BusEventos{
+		suscripciones: make(map[int]*suscripcion),
+		nextID:        1,
+	}
+}
+
+// Suscribir llama a manejador con cada evento de los tipos indicados (con
+// todos si no se indica ninguno), en el orden en que se publicaron. Devuelve
+// la función para cancelar la suscripción.
+func (b *BusEventos) Suscribir(manejador func(Evento), tipos ...TipoEvento) func() {
+	s := &suscripcion{
+		tipos:     make(map[TipoEvento]bool),
+		manejador: manejador,
+		avisos:    make(chan struct{}, 1),
+		terminada: make(chan struct{}),
+	}
+	for _, tipo := range tipos {
+		s.tipos[tipo] = true
+	}
+
+	b.mutex.Lock()
+	id := b.nextID
+	b.nextID++
+	if b.cerrado {
+		s.cancelada = true
+		close(s.avisos)
+	} else {
+		b.suscripciones[id] = s
+	}
+	b.mutex.Unlock()
+
+	go s.atender()
+
+	var una sync.Once
+	return func() {
+		una.Do(func() {
+			b.mutex.Lock()
+			delete(b.suscripciones, id)
+			b.mutex.Unlock()
+			s.cancelar(false)
+		})
+	}
+}
+
+// Publicar entrega el evento a los suscriptores interesados sin esperar a
+// que lo procesen. Se puede llamar sobre un bus nil.
+func (b *BusEventos) Publicar(e Evento) {
+	if b == nil {
+		return
+	}
+	if e.Instante.IsZero() {
+		e.Instante = time.Now()
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.cerrado {
+		return
+	}
+	for _, s := range b.suscripciones {
+		s.encolar(e)
+	}
+}
+
+// Cerrar deja de aceptar eventos y espera a que cada suscriptor procese los
+// que tenía pendientes
+func (b *BusEventos) Cerrar() {
+	b.mutex.Lock()
+	b.cerrado = true
+	suscripciones := b.suscripciones
+	b.suscripciones = make(map[int]*suscripcion)
+	b.mutex.Unlock()
+
+	for _, s := range suscripciones {
+		s.cancelar(true)
+	}
+	for _, s := range suscripciones {
+		<-s.terminada
+	}
+}
+
+func (s *suscripcion) encolar(e Evento) {
+	if len(s.tipos) > 0 && !s.tipos[e.Tipo] {
+		return
+	}
+
+	s.mutex.Lock()
+	if s.cancelada {
+		s.mutex.Unlock()
+		return
+	}
+	s.pendientes = append(s.pendientes, e)
+	s.mutex.Unlock()
+
+	select {
+	case s.avisos <- struct{}{}:
+	default:
+	}
+}
+
+// cancelar detiene la goroutine del suscriptor; si vaciar es true antes
+// procesa lo pendiente
+func (s *suscripcion) cancelar(vaciar bool) {
+	s.mutex.Lock()
+	yaCancelada := s.cancelada
+	s.cancelada = true
+	if !vaciar {
+		s.pendientes = nil
+	}
+	s.mutex.Unlock()
+
+	if !yaCancelada {
+		close(s.avisos)
+	}
+}
+
+func (s *suscripcion) atender() {
+	defer close(s.terminada)
+
+	for range s.avisos {
+		s.procesarPendientes()
+	}
+	// Tras cancelar puede quedar algo por procesar si se pidió vaciar
+	s.procesarPendientes()
+}
+
+func (s *suscripcion) procesarPendientes() {
+	for {
+		s.mutex.Lock()
+		lote := s.pendientes
+		s.pendientes = nil
+		s.mutex.Unlock()
+
+		if len(lote) == 0 {
+			return
+		}
+		for _, e := range lote {
+			s.entregar(e)
+		}
+	}
+}
+
+// entregar llama al manejador sin dejar que un fallo suyo tumbe el taller
+func (s *suscripcion) entregar(e Evento) {
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Printf("AVISO: un suscriptor falló procesando %s: %v\n", e.Tipo, r)
+		}
+	}()
+	s.manejador(e)
+}
+
+// RegistrarAuditoria escribe en w cada evento del bus como una línea JSON.
+// Devuelve la función para dejar de registrar.
+func RegistrarAuditoria(bus *BusEventos, w io.Writer) func() {
+	encoder := json.NewEncoder(w)
+	return bus.Suscribir(func(e Evento) {
+		if err := encoder.Encode(e); err != nil {
+			fmt.Printf("AVISO: no se pudo registrar el evento %s: %v\n", e.Tipo, err)
+		}
+	})
+}
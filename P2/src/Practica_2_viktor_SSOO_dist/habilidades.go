@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// NivelHabilidad mide lo preparado que está un mecánico en una especialidad
+type NivelHabilidad int
+
+const (
+	NivelBasico  NivelHabilidad = 1
+	NivelMedio   NivelHabilidad = 2
+	NivelExperto NivelHabilidad = 3
+)
+
+// EsperaSecundariaDefecto es lo que espera un trabajo a un mecánico de la
+// especialidad antes de aceptar a uno que la tenga como secundaria
+const EsperaSecundariaDefecto = 30 * time.Second
+
+// Habilidad es una especialidad en la que el mecánico está certificado
+type Habilidad struct {
+	Especialidad Especialidad   `json:"especialidad"`
+	Nivel        NivelHabilidad `json:"nivel"`
+}
+
+// nivelPorExperiencia da el nivel inicial de la especialidad principal
+func nivelPorExperiencia(experiencia int) NivelHabilidad {
+	if experiencia >= 8 {
+		return NivelExperto
+	}
+	if experiencia >= 3 {
+		return NivelMedio
+	}
+	return NivelBasico
+}
+
+// sinTildes deja "Mecánica" o "Eléctrica" como se escriben las constantes
+var sinTildes = strings.NewReplacer("á", "a", "é", "e", "í", "i", "ó", "o", "ú", "u")
+
+// parsearEspecialidad acepta tanto "mecanica" como "Mecánica"
+func parsearEspecialidad(s string) (Especialidad, bool) {
+	e := Especialidad(sinTildes.Replace(strings.ToLower(strings.TrimSpace(s))))
+	if !especialidadValida(e) {
+		return "", false
+	}
+	return e, true
+}
+
+func especialidadValida(e Especialidad) bool {
+	switch e {
+	case EspecialidadMecanica, EspecialidadElectrica, EspecialidadCarroceria:
+		return true
+	}
+	return false
+}
+
+// Nivel devuelve el nivel del mecánico en la especialidad, 0 si no la tiene
+func (m Mecanico) Nivel(especialidad Especialidad) NivelHabilidad {
+	for _, h := range m.Habilidades {
+		if h.Especialidad == especialidad {
+			return h.Nivel
+		}
+	}
+	return 0
+}
+
+// AgregarHabilidad certifica al mecánico en una especialidad o le cambia el
+// nivel si ya la tenía
+func (mm *MecanicoManager) AgregarHabilidad(id int, especialidad Especialidad, nivel NivelHabilidad) error {
+	if nivel < NivelBasico || nivel > NivelExperto {
+		return fmt.Errorf("nivel %d no válido (de %d a %d)", nivel, NivelBasico, NivelExperto)
+	}
+	if !especialidadValida(especialidad) {
+		return fmt.Errorf("especialidad '%s' no válida (mecanica/electrica/carroceria)", especialidad)
+	}
+
+	mm.mutex.Lock()
+	for i := 0; i < len(mm.mecanicos); i++ {
+		if mm.mecanicos[i].ID == id {
+			mm.mecanicos[i].Habilidades = conHabilidad(mm.mecanicos[i].Habilidades, Habilidad{especialidad, nivel})
+			mecanico := mm.mecanicos[i]
+			mm.mutex.Unlock()
+
+			// Puede que ahora le toquen trabajos que estaban esperando
+			mm.plazaLibre(mecanico)
+			return nil
+		}
+	}
+	mm.mutex.Unlock()
+	return fmt.Errorf("mecánico con ID %d no encontrado", id)
+}
+
+// QuitarHabilidad retira una especialidad secundaria. La principal no se
+// puede quitar, hay que cambiarla con ActualizarMecanico.
+func (mm *MecanicoManager) QuitarHabilidad(id int, especialidad Especialidad) error {
+	mm.mutex.Lock()
+	defer mm.mutex.Unlock()
+
+	for i := 0; i < len(mm.mecanicos); i++ {
+		if mm.mecanicos[i].ID == id {
+			if mm.mecanicos[i].Especialidad == especialidad {
+				return fmt.Errorf("%s es la especialidad principal del mecánico %d", especialidad, id)
+			}
+			habilidades := make([]Habilidad, 0, len(mm.mecanicos[i].Habilidades))
+			for _, h := range mm.mecanicos[i].Habilidades {
+				if h.Especialidad != especialidad {
+					habilidades = append(habilidades, h)
+				}
+			}
+			mm.mecanicos[i].Habilidades = habilidades
+			return nil
+		}
+	}
+	return fmt.Errorf("mecánico con ID %d no encontrado", id)
+}
+
+// conHabilidad devuelve una lista nueva con la habilidad añadida o
+// actualizada. Nunca se modifica la original porque las copias de Mecanico
+// que ya se han repartido la comparten.
+func conHabilidad(habilidades []Habilidad, nueva Habilidad) []Habilidad {
+	lista := make([]Habilidad, 0, len(habilidades)+1)
+	encontrada := false
+	for _, h := range habilidades {
+		if h.Especialidad == nueva.Especialidad {
+			h.Nivel = nueva.Nivel
+			encontrada = true
+		}
+		lista = append(lista, h)
+	}
+	if !encontrada {
+		lista = append(lista, nueva)
+	}
+	return lista
+}
@@ -2,12 +2,14 @@ package main
 
 import (
 	"fmt"
-	"time"
+	"sync"
 )
 
 type IncidenciaManager struct {
 	incidencias []Incidencia
 	nextID      int
+	eventos     *BusEventos
+	mutex       sync.RWMutex
 }
 
 func NewIncidenciaManager() *IncidenciaManager {
@@ -18,6 +20,9 @@ func NewIncidenciaManager() *IncidenciaManager {
 }
 
 func (im *IncidenciaManager) CrearIncidencia(tipo TipoIncidencia, prioridad Prioridad, descripcion string, vehiculoID int) Incidencia {
+	im.mutex.Lock()
+	defer im.mutex.Unlock()
+
 	incidencia := Incidencia{
 		ID:           im.nextID,
 		MecanicosIDs: make([]int, 0),
@@ -29,10 +34,14 @@ func (im *IncidenciaManager) CrearIncidencia(tipo TipoIncidencia, prioridad Prio
 	}
 	im.incidencias = append(im.incidencias, incidencia)
 	im.nextID++
+	im.eventos.Publicar(Evento{Tipo: IncidenciaCreada, IncidenciaID: incidencia.ID, VehiculoID: vehiculoID, Estado: Abierta})
 	return incidencia
 }
 
 func (im *IncidenciaManager) ObtenerIncidencia(id int) (Incidencia, bool) {
+	im.mutex.RLock()
+	defer im.mutex.RUnlock()
+
 	for i := 0; i < len(im.incidencias); i++ {
 		if im.incidencias[i].ID == id {
 			return im.incidencias[i], true
@@ -42,6 +51,9 @@ func (im *IncidenciaManager) ObtenerIncidencia(id int) (Incidencia, bool) {
 }
 
 func (im *IncidenciaManager) ActualizarIncidencia(id int, tipo TipoIncidencia, prioridad Prioridad, descripcion string) error {
+	im.mutex.Lock()
+	defer im.mutex.Unlock()
+
 	for i := 0; i < len(im.incidencias); i++ {
 		if im.incidencias[i].ID == id {
 			if tipo != "" {
@@ -60,6 +72,9 @@ func (im *IncidenciaManager) ActualizarIncidencia(id int, tipo TipoIncidencia, p
 }
 
 func (im *IncidenciaManager) EliminarIncidencia(id int) error {
+	im.mutex.Lock()
+	defer im.mutex.Unlock()
+
 	for i := 0; i < len(im.incidencias); i++ {
 		if im.incidencias[i].ID == id {
 			im.incidencias = append(im.incidencias[:i], im.incidencias[i+1:]...)
@@ -70,12 +85,26 @@ func (im *IncidenciaManager) EliminarIncidencia(id int) error {
 }
 
 func (im *IncidenciaManager) ListarIncidencias() []Incidencia {
-	return im.incidencias
+	im.mutex.RLock()
+	defer im.mutex.RUnlock()
+
+	return append([]Incidencia(nil), im.incidencias...)
 }
 
 func (im *IncidenciaManager) CambiarEstado(id int, nuevoEstado EstadoIncidencia) error {
+	im.mutex.Lock()
+	defer im.mutex.Unlock()
+
 	for i := 0; i < len(im.incidencias); i++ {
 		if im.incidencias[i].ID == id {
+			if im.incidencias[i].Estado != nuevoEstado {
+				im.eventos.Publicar(Evento{
+					Tipo:         IncidenciaEstadoCambiado,
+					IncidenciaID: id,
+					VehiculoID:   im.incidencias[i].VehiculoID,
+					Estado:       nuevoEstado,
+				})
+			}
 			im.incidencias[i].Estado = nuevoEstado
 			return nil
 		}
@@ -84,6 +113,9 @@ func (im *IncidenciaManager) CambiarEstado(id int, nuevoEstado EstadoIncidencia)
 }
 
 func (im *IncidenciaManager) AsignarMecanico(incidenciaID, mecanicoID int) error {
+	im.mutex.Lock()
+	defer im.mutex.Unlock()
+
 	for i := 0; i < len(im.incidencias); i++ {
 		if im.incidencias[i].ID == incidenciaID {
 			// Verificar si el mecánico ya está asignado
@@ -100,6 +132,9 @@ func (im *IncidenciaManager) AsignarMecanico(incidenciaID, mecanicoID int) error
 }
 
 func (im *IncidenciaManager) DesasignarMecanico(incidenciaID, mecanicoID int) error {
+	im.mutex.Lock()
+	defer im.mutex.Unlock()
+
 	for i := 0; i < len(im.incidencias); i++ {
 		if im.incidencias[i].ID == incidenciaID {
 			nuevaLista := make([]int, 0)
@@ -121,20 +156,10 @@ func (im *IncidenciaManager) DesasignarMecanico(incidenciaID, mecanicoID int) er
 	return fmt.Errorf("incidencia con ID %d no encontrada", incidenciaID)
 }
 
-func ObtenerTiempoAtencion(tipo TipoIncidencia) time.Duration {
-	if tipo == Mecanica {
-		return 5 * time.Second
-	}
-	if tipo == Electrica {
-		return 7 * time.Second
-	}
-	if tipo == Carroceria {
-		return 11 * time.Second
-	}
-	return 5 * time.Second
-}
-
 func (im *IncidenciaManager) ContarTodasIncidencias() map[int]int {
+	im.mutex.RLock()
+	defer im.mutex.RUnlock()
+
 	counts := make(map[int]int)
 	for _, incidencia := range im.incidencias {
 		if incidencia.VehiculoID > 0 {
@@ -145,6 +170,9 @@ func (im *IncidenciaManager) ContarTodasIncidencias() map[int]int {
 }
 
 func (im *IncidenciaManager) ObtenerIncidenciasPorVehiculo(vehiculoID int) []Incidencia {
+	im.mutex.RLock()
+	defer im.mutex.RUnlock()
+
 	lista := make([]Incidencia, 0)
 
 	for i := 0; i < len(im.incidencias); i++ {
@@ -156,6 +184,9 @@ func (im *IncidenciaManager) ObtenerIncidenciasPorVehiculo(vehiculoID int) []Inc
 }
 
 func (im *IncidenciaManager) EliminarIncidenciasPorVehiculo(vehiculoID int) {
+	im.mutex.Lock()
+	defer im.mutex.Unlock()
+
 	nuevaLista := make([]Incidencia, 0)
 	for i := 0; i < len(im.incidencias); i++ {
 		if im.incidencias[i].VehiculoID != vehiculoID {
@@ -2,11 +2,25 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"flag"
 	"fmt"
 	"os"
+	"time"
 )
 
+// PlazoCierre es lo que se espera a que los mecánicos acaben al salir
+const PlazoCierre = 30 * time.Second
+
+// ArchivoPendientes es donde se dejan los trabajos que no se llegaron a hacer
+const ArchivoPendientes = "trabajos_pendientes.json"
+
 func main() {
+	archivoTiempos := flag.String("tiempos", "", "archivo JSON con el modelo de tiempos de reparación (vacío para los de siempre)")
+	semilla := flag.Int64("semilla", 0, "semilla de los tiempos aleatorios, para repetir una ejecución (0 para la del archivo o una nueva)")
+	flag.Int64Var(semilla, "seed", 0, "igual que -semilla")
+	flag.Parse()
+
 	// Inicializar managers
 	clienteManager := NewClienteManager()
 	vehiculoManager := NewVehiculoManager()
@@ -15,6 +29,20 @@ func main() {
 
 	// Crear taller
 	taller := NewTaller(mecanicoManager, vehiculoManager, incidenciaManager)
+	tiempos := ModeloTiemposPorDefecto()
+	if *archivoTiempos != "" {
+		var err error
+		tiempos, err = CargarModeloTiempos(*archivoTiempos)
+		if err != nil {
+			fmt.Printf("Error al cargar el modelo de tiempos: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if *semilla != 0 {
+		tiempos.Semilla = *semilla
+	}
+	fmt.Printf("Semilla de los tiempos: %d (para repetir: -semilla %[1]d)\n", tiempos.ElegirSemilla())
+	taller.ConfigurarTiempos(tiempos)
 	taller.IniciarTaller()
 
 	// Menú principal
@@ -38,10 +66,40 @@ func main() {
 			menuTaller(scanner, taller, vehiculoManager, incidenciaManager)
 		} else if opcion == "0" {
 			fmt.Println("Saliendo del sistema...")
-			taller.DetenerTaller()
+			cerrarTaller(taller)
 			break
 		} else {
 			fmt.Println("Opción no válida")
 		}
 	}
 }
+
+// cerrarTaller espera a los mecánicos, informa del resultado y guarda los
+// trabajos que quedan por hacer
+func cerrarTaller(taller *Taller) {
+	fmt.Printf("Esperando a que los mecánicos terminen (máximo %v)...\n", PlazoCierre)
+	ctx, cancelar := context.WithTimeout(context.Background(), PlazoCierre)
+	defer cancelar()
+
+	resumen, err := taller.Cerrar(ctx)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	resumen.Imprimir(os.Stdout)
+	if len(resumen.Abandonados) == 0 {
+		return
+	}
+
+	archivo, err := os.Create(ArchivoPendientes)
+	if err != nil {
+		fmt.Printf("No se pudieron guardar los trabajos pendientes: %v\n", err)
+		return
+	}
+	defer archivo.Close()
+	if err := resumen.GuardarAbandonados(archivo); err != nil {
+		fmt.Printf("No se pudieron guardar los trabajos pendientes: %v\n", err)
+		return
+	}
+	fmt.Printf("Trabajos pendientes guardados en %s\n", ArchivoPendientes)
+}
@@ -2,11 +2,21 @@ package main
 
 import (
 	"fmt"
+	"sync"
 )
 
+// MecanicoManager se usa a la vez desde el menú, el reparto y las rutinas
+// de los mecánicos, así que todo acceso pasa por mutex y lo que devuelve son
+// copias
 type MecanicoManager struct {
 	mecanicos []Mecanico
 	nextID    int
+	eventos   *BusEventos
+
+	// avisarPlazaLibre se llama, sin el mutex cogido, cada vez que un
+	// mecánico puede aceptar un trabajo más
+	avisarPlazaLibre func(Especialidad)
+	mutex            sync.RWMutex
 }
 
 func NewMecanicoManager() *MecanicoManager {
@@ -17,21 +27,47 @@ func NewMecanicoManager() *MecanicoManager {
 }
 
 func (mm *MecanicoManager) CrearMecanico(nombre string, especialidad Especialidad, experiencia int) Mecanico {
+	mm.mutex.Lock()
+	mecanico := mm.crearMecanico(nombre, especialidad, experiencia)
+	mm.mutex.Unlock()
+
+	mm.plazaLibre(mecanico)
+	return mecanico
+}
+
+// CrearMecanicoExtra contrata un mecánico de refuerzo con la plaza que va a
+// ocupar ya reservada
+func (mm *MecanicoManager) CrearMecanicoExtra(especialidad Especialidad) Mecanico {
+	mm.mutex.Lock()
+	defer mm.mutex.Unlock()
+
+	mecanico := mm.crearMecanico(fmt.Sprintf("Mecanico-Extra-%d", mm.nextID), especialidad, 1)
+	mm.mecanicos[len(mm.mecanicos)-1].PlazasOcupadas++
+	mecanico.PlazasOcupadas++
+	return mecanico
+}
+
+func (mm *MecanicoManager) crearMecanico(nombre string, especialidad Especialidad, experiencia int) Mecanico {
 	mecanico := Mecanico{
 		ID:             mm.nextID,
 		Nombre:         nombre,
 		Especialidad:   especialidad,
+		Habilidades:    []Habilidad{{especialidad, nivelPorExperiencia(experiencia)}},
 		Experiencia:    experiencia,
 		Activo:         true,
 		PlazasOcupadas: 0,
-		ColaPersonal:   make(chan TrabajoPendiente, 2),
+		ColaPersonal:   make(chan TrabajoPendiente, PlazasPorMecanico),
 	}
 	mm.mecanicos = append(mm.mecanicos, mecanico)
 	mm.nextID++
+	mm.eventos.Publicar(Evento{Tipo: MecanicoContratado, MecanicoID: mecanico.ID})
 	return mecanico
 }
 
 func (mm *MecanicoManager) ObtenerMecanico(id int) (Mecanico, bool) {
+	mm.mutex.RLock()
+	defer mm.mutex.RUnlock()
+
 	for i := 0; i < len(mm.mecanicos); i++ {
 		if mm.mecanicos[i].ID == id {
 			return mm.mecanicos[i], true
@@ -41,13 +77,21 @@ func (mm *MecanicoManager) ObtenerMecanico(id int) (Mecanico, bool) {
 }
 
 func (mm *MecanicoManager) ActualizarMecanico(id int, nombre string, especialidad Especialidad, experiencia int) error {
+	mm.mutex.Lock()
+	defer mm.mutex.Unlock()
+
 	for i := 0; i < len(mm.mecanicos); i++ {
 		if mm.mecanicos[i].ID == id {
 			if nombre != "" {
 				mm.mecanicos[i].Nombre = nombre
 			}
 			if especialidad != "" {
+				// La principal anterior se queda como secundaria
 				mm.mecanicos[i].Especialidad = especialidad
+				if mm.mecanicos[i].Nivel(especialidad) == 0 {
+					mm.mecanicos[i].Habilidades = conHabilidad(mm.mecanicos[i].Habilidades,
+						Habilidad{especialidad, nivelPorExperiencia(mm.mecanicos[i].Experiencia)})
+				}
 			}
 			if experiencia > 0 {
 				mm.mecanicos[i].Experiencia = experiencia
@@ -59,6 +103,9 @@ func (mm *MecanicoManager) ActualizarMecanico(id int, nombre string, especialida
 }
 
 func (mm *MecanicoManager) EliminarMecanico(id int) error {
+	mm.mutex.Lock()
+	defer mm.mutex.Unlock()
+
 	for i := 0; i < len(mm.mecanicos); i++ {
 		if mm.mecanicos[i].ID == id {
 			mm.mecanicos = append(mm.mecanicos[:i], mm.mecanicos[i+1:]...)
@@ -69,20 +116,41 @@ func (mm *MecanicoManager) EliminarMecanico(id int) error {
 }
 
 func (mm *MecanicoManager) ListarMecanicos() []Mecanico {
-	return mm.mecanicos
+	mm.mutex.RLock()
+	defer mm.mutex.RUnlock()
+
+	return append([]Mecanico(nil), mm.mecanicos...)
 }
 
 func (mm *MecanicoManager) CambiarEstadoActivo(id int, activo bool) error {
+	mm.mutex.Lock()
 	for i := 0; i < len(mm.mecanicos); i++ {
 		if mm.mecanicos[i].ID == id {
+			if mm.mecanicos[i].Activo != activo {
+				tipo := MecanicoDadoDeBaja
+				if activo {
+					tipo = MecanicoDadoDeAlta
+				}
+				mm.eventos.Publicar(Evento{Tipo: tipo, MecanicoID: id})
+			}
 			mm.mecanicos[i].Activo = activo
+			mecanico := mm.mecanicos[i]
+			mm.mutex.Unlock()
+
+			if activo {
+				mm.plazaLibre(mecanico)
+			}
 			return nil
 		}
 	}
+	mm.mutex.Unlock()
 	return fmt.Errorf("mecánico con ID %d no encontrado", id)
 }
 
 func (mm *MecanicoManager) IncrementarPlaza(id int) error {
+	mm.mutex.Lock()
+	defer mm.mutex.Unlock()
+
 	for i := 0; i < len(mm.mecanicos); i++ {
 		if mm.mecanicos[i].ID == id {
 			mm.mecanicos[i].PlazasOcupadas++
@@ -93,21 +161,84 @@ func (mm *MecanicoManager) IncrementarPlaza(id int) error {
 }
 
 func (mm *MecanicoManager) DecrementarPlaza(id int) error {
+	mm.mutex.Lock()
 	for i := 0; i < len(mm.mecanicos); i++ {
 		if mm.mecanicos[i].ID == id {
 			if mm.mecanicos[i].PlazasOcupadas > 0 {
 				mm.mecanicos[i].PlazasOcupadas--
 			}
+			mecanico := mm.mecanicos[i]
+			mm.mutex.Unlock()
+
+			mm.plazaLibre(mecanico)
 			return nil
 		}
 	}
+	mm.mutex.Unlock()
 	return fmt.Errorf("mecánico con ID %d no encontrado", id)
 }
 
+// ReservarPlaza busca, entre los mecánicos activos con hueco que tienen la
+// especialidad como principal, el de más nivel y le ocupa una plaza en la
+// misma operación, para que dos goroutines no se queden con el mismo hueco.
+// Devuelve el mecánico ya con la plaza ocupada.
+func (mm *MecanicoManager) ReservarPlaza(especialidad Especialidad) (Mecanico, bool) {
+	return mm.reservarPlaza(func(m Mecanico) NivelHabilidad {
+		if m.Especialidad != especialidad {
+			return 0
+		}
+		return m.Nivel(especialidad)
+	})
+}
+
+// ReservarPlazaSecundaria es como ReservarPlaza pero entre los que tienen
+// la especialidad como secundaria
+func (mm *MecanicoManager) ReservarPlazaSecundaria(especialidad Especialidad) (Mecanico, bool) {
+	return mm.reservarPlaza(func(m Mecanico) NivelHabilidad {
+		if m.Especialidad == especialidad {
+			return 0
+		}
+		return m.Nivel(especialidad)
+	})
+}
+
+// ReservarPlazaCualquiera es como ReservarPlaza pero vale cualquier
+// especialidad
+func (mm *MecanicoManager) ReservarPlazaCualquiera() (Mecanico, bool) {
+	return mm.reservarPlaza(func(m Mecanico) NivelHabilidad { return NivelBasico })
+}
+
+// reservarPlaza se queda con el mecánico de más nivel según nivel (0 si no
+// sirve). A igual nivel gana el que se dio de alta antes.
+func (mm *MecanicoManager) reservarPlaza(nivel func(Mecanico) NivelHabilidad) (Mecanico, bool) {
+	mm.mutex.Lock()
+	defer mm.mutex.Unlock()
+
+	elegido := -1
+	mejor := NivelHabilidad(0)
+	for i := 0; i < len(mm.mecanicos); i++ {
+		m := mm.mecanicos[i]
+		if !m.Activo || m.PlazasOcupadas >= PlazasPorMecanico {
+			continue
+		}
+		if n := nivel(m); n > mejor {
+			elegido, mejor = i, n
+		}
+	}
+	if elegido < 0 {
+		return Mecanico{}, false
+	}
+	mm.mecanicos[elegido].PlazasOcupadas++
+	return mm.mecanicos[elegido], true
+}
+
 func (mm *MecanicoManager) ListarMecanicosDisponibles() []Mecanico {
+	mm.mutex.RLock()
+	defer mm.mutex.RUnlock()
+
 	lista := make([]Mecanico, 0)
 	for i := 0; i < len(mm.mecanicos); i++ {
-		if mm.mecanicos[i].Activo && mm.mecanicos[i].PlazasOcupadas < 2 {
+		if mm.mecanicos[i].Activo && mm.mecanicos[i].PlazasOcupadas < PlazasPorMecanico {
 			lista = append(lista, mm.mecanicos[i])
 		}
 	}
@@ -115,6 +246,9 @@ func (mm *MecanicoManager) ListarMecanicosDisponibles() []Mecanico {
 }
 
 func (mm *MecanicoManager) ListarMecanicosPorEspecialidad(especialidad Especialidad) []Mecanico {
+	mm.mutex.RLock()
+	defer mm.mutex.RUnlock()
+
 	lista := make([]Mecanico, 0)
 	for i := 0; i < len(mm.mecanicos); i++ {
 		if mm.mecanicos[i].Especialidad == especialidad && mm.mecanicos[i].Activo {
@@ -125,6 +259,9 @@ func (mm *MecanicoManager) ListarMecanicosPorEspecialidad(especialidad Especiali
 }
 
 func (mm *MecanicoManager) ContarMecanicosPorEspecialidad() map[Especialidad]int {
+	mm.mutex.RLock()
+	defer mm.mutex.RUnlock()
+
 	conteo := make(map[Especialidad]int)
 	conteo[EspecialidadMecanica] = 0
 	conteo[EspecialidadElectrica] = 0
@@ -139,6 +276,9 @@ func (mm *MecanicoManager) ContarMecanicosPorEspecialidad() map[Especialidad]int
 }
 
 func (mm *MecanicoManager) ContarMecanicosActivos() int {
+	mm.mutex.RLock()
+	defer mm.mutex.RUnlock()
+
 	count := 0
 	for i := 0; i < len(mm.mecanicos); i++ {
 		if mm.mecanicos[i].Activo {
@@ -147,3 +287,14 @@ func (mm *MecanicoManager) ContarMecanicosActivos() int {
 	}
 	return count
 }
+
+// plazaLibre avisa por cada especialidad del mecánico, porque el hueco le
+// puede servir a cualquiera de ellas
+func (mm *MecanicoManager) plazaLibre(mecanico Mecanico) {
+	if mm.avisarPlazaLibre == nil {
+		return
+	}
+	for _, h := range mecanico.Habilidades {
+		mm.avisarPlazaLibre(h.Especialidad)
+	}
+}
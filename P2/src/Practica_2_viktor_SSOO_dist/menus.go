@@ -3,6 +3,7 @@ package main
 import (
 	"bufio"
 	"fmt"
+	"os"
 	"strconv"
 	"strings"
 )
@@ -297,6 +298,7 @@ func menuMecanicos(scanner *bufio.Scanner, mm *MecanicoManager, taller *Taller)
 		fmt.Println("1. Crear Mecánico")
 		fmt.Println("2. Listar Mecánicos")
 		fmt.Println("3. Cambiar Estado (Alta/Baja)")
+		fmt.Println("4. Habilidades")
 		fmt.Println("0. Volver")
 		fmt.Print("Opción: ")
 		scanner.Scan()
@@ -308,8 +310,11 @@ func menuMecanicos(scanner *bufio.Scanner, mm *MecanicoManager, taller *Taller)
 			nombre := scanner.Text()
 			fmt.Print("Especialidad (mecanica/electrica/carroceria): ")
 			scanner.Scan()
-			espStr := scanner.Text()
-			especialidad := Especialidad(espStr)
+			especialidad, valida := parsearEspecialidad(scanner.Text())
+			if !valida {
+				fmt.Println("Especialidad no válida. Operación cancelada.")
+				continue
+			}
 			fmt.Print("Años de experiencia: ")
 			scanner.Scan()
 			exp, _ := strconv.Atoi(scanner.Text())
@@ -331,6 +336,7 @@ func menuMecanicos(scanner *bufio.Scanner, mm *MecanicoManager, taller *Taller)
 				fmt.Printf("ID: %d | Nombre: %s | Especialidad: %s | Exp: %d años | Estado: %s | Cola: %d/2\n",
 					mecanicos[i].ID, mecanicos[i].Nombre, mecanicos[i].Especialidad,
 					mecanicos[i].Experiencia, estado, len(mecanicos[i].ColaPersonal))
+				fmt.Printf("    Habilidades: %s\n", textoHabilidades(mecanicos[i].Habilidades))
 			}
 
 		} else if opcion == "3" {
@@ -386,6 +392,51 @@ func menuMecanicos(scanner *bufio.Scanner, mm *MecanicoManager, taller *Taller)
 				fmt.Printf("Mecánico con ID %d ha sido %s correctamente.\n", id, accion)
 			}
 
+		} else if opcion == "4" {
+			fmt.Print("ID del mecánico: ")
+			scanner.Scan()
+			id, _ := strconv.Atoi(scanner.Text())
+
+			mecanicoActual, existe := mm.ObtenerMecanico(id)
+			if !existe {
+				fmt.Printf("Error: Mecánico con ID %d no encontrado.\n", id)
+				continue
+			}
+			fmt.Printf("Habilidades de %s: %s\n", mecanicoActual.Nombre, textoHabilidades(mecanicoActual.Habilidades))
+
+			fmt.Println("1. Añadir o cambiar nivel")
+			fmt.Println("2. Quitar")
+			fmt.Print("Opción: ")
+			scanner.Scan()
+			subOpcion := scanner.Text()
+
+			fmt.Print("Especialidad (mecanica/electrica/carroceria): ")
+			scanner.Scan()
+			especialidad, valida := parsearEspecialidad(scanner.Text())
+			if !valida {
+				fmt.Println("Especialidad no válida. Operación cancelada.")
+				continue
+			}
+
+			var err error
+			if subOpcion == "1" {
+				fmt.Print("Nivel (1=básico, 2=medio, 3=experto): ")
+				scanner.Scan()
+				nivel, _ := strconv.Atoi(scanner.Text())
+				err = mm.AgregarHabilidad(id, especialidad, NivelHabilidad(nivel))
+			} else if subOpcion == "2" {
+				err = mm.QuitarHabilidad(id, especialidad)
+			} else {
+				fmt.Println("Opción no válida. Operación cancelada.")
+				continue
+			}
+
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+			} else {
+				fmt.Println("Habilidades actualizadas correctamente")
+			}
+
 		} else if opcion == "0" {
 			break
 		}
@@ -397,6 +448,8 @@ func menuTaller(scanner *bufio.Scanner, t *Taller, vm *VehiculoManager, im *Inci
 		fmt.Println("\n===== ESTADO DEL TALLER =====")
 		fmt.Println("1. Ver Estado")
 		fmt.Println("2. Enviar Vehículo(s) al Taller")
+		fmt.Println("3. Ver Estado (tabla)")
+		fmt.Println("4. Ver Estado (JSON)")
 		fmt.Println("0. Volver")
 		fmt.Print("Opción: ")
 		scanner.Scan()
@@ -404,6 +457,10 @@ func menuTaller(scanner *bufio.Scanner, t *Taller, vm *VehiculoManager, im *Inci
 
 		if opcion == "1" {
 			t.ObtenerEstadoTaller()
+		} else if opcion == "3" {
+			RenderizarEstado(os.Stdout, t.Estado(), FormatoTabla)
+		} else if opcion == "4" {
+			RenderizarEstado(os.Stdout, t.Estado(), FormatoJSON)
 		} else if opcion == "2" {
 			fmt.Print("IDs de los vehículos a enviar (separados por coma): ")
 			scanner.Scan()
@@ -443,3 +500,11 @@ func menuTaller(scanner *bufio.Scanner, t *Taller, vm *VehiculoManager, im *Inci
 		}
 	}
 }
+
+func textoHabilidades(habilidades []Habilidad) string {
+	partes := make([]string, 0, len(habilidades))
+	for _, h := range habilidades {
+		partes = append(partes, fmt.Sprintf("%s (nivel %d)", h.Especialidad, h.Nivel))
+	}
+	return strings.Join(partes, ", ")
+}
@@ -58,6 +58,9 @@ type Incidencia struct {
 
 type Especialidad string
 
+// PlazasPorMecanico es el número de coches que un mecánico atiende a la vez
+const PlazasPorMecanico = 2
+
 const (
 	EspecialidadMecanica   Especialidad = "mecanica"
 	EspecialidadElectrica  Especialidad = "electrica"
@@ -65,9 +68,12 @@ const (
 )
 
 type Mecanico struct {
-	ID             int
-	Nombre         string
-	Especialidad   Especialidad
+	ID           int
+	Nombre       string
+	Especialidad Especialidad // la principal, también está en Habilidades
+	// Habilidades son todas las especialidades en las que está certificado,
+	// las que no son la principal se usan como secundarias
+	Habilidades    []Habilidad
 	Experiencia    int
 	Activo         bool
 	PlazasOcupadas int
@@ -75,6 +81,7 @@ type Mecanico struct {
 }
 
 type TrabajoPendiente struct {
+	ID           int
 	Vehiculo     *Vehiculo
 	Incidencia   *Incidencia
 	TiempoInicio time.Time
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"container/heap"
+	"time"
+)
+
+// EnvejecimientoDefecto es lo que tiene que esperar un trabajo para subir un
+// nivel de prioridad
+const EnvejecimientoDefecto = 2 * time.Minute
+
+// antesEnCola dice si el trabajo a debe atenderse antes que b. Cada trabajo
+// sube un nivel de prioridad por cada intervalo de envejecimiento que lleva
+// esperando, así que uno de prioridad baja acaba pasando por delante de los
+// de alta que lleguen mucho después. Como todos envejecen al mismo ritmo el
+// orden entre dos trabajos no cambia con el tiempo y se puede usar un heap.
+// Con envejecimiento <= 0 solo cuenta la prioridad y, a igualdad, el orden
+// de llegada.
+func antesEnCola(a, b TrabajoPendiente, envejecimiento time.Duration) bool {
+	prioridadA := rangoPrioridad(a.Incidencia.Prioridad)
+	prioridadB := rangoPrioridad(b.Incidencia.Prioridad)
+
+	if envejecimiento <= 0 {
+		if prioridadA != prioridadB {
+			return prioridadA > prioridadB
+		}
+		return a.TiempoInicio.Before(b.TiempoInicio)
+	}
+
+	// a va antes si su ventaja de prioridad compensa lo que llegó más tarde
+	ventaja := time.Duration(prioridadA-prioridadB) * envejecimiento
+	retraso := a.TiempoInicio.Sub(b.TiempoInicio)
+	if ventaja != retraso {
+		return ventaja > retraso
+	}
+	return a.TiempoInicio.Before(b.TiempoInicio)
+}
+
+func rangoPrioridad(p Prioridad) int {
+	switch p {
+	case Alta:
+		return 3
+	case Media:
+		return 2
+	case Baja:
+		return 1
+	}
+	return 0
+}
+
+// filaTrabajos implementa heap.Interface para sacar siempre el trabajo que
+// toca según antesEnCola
+type filaTrabajos struct {
+	trabajos       []TrabajoPendiente
+	envejecimiento time.Duration
+}
+
+func (f *filaTrabajos) Len() int { return len(f.trabajos) }
+
+func (f *filaTrabajos) Less(i, j int) bool {
+	return antesEnCola(f.trabajos[i], f.trabajos[j], f.envejecimiento)
+}
+
+func (f *filaTrabajos) Swap(i, j int) {
+	f.trabajos[i], f.trabajos[j] = f.trabajos[j], f.trabajos[i]
+}
+
+func (f *filaTrabajos) Push(x interface{}) {
+	f.trabajos = append(f.trabajos, x.(TrabajoPendiente))
+}
+
+func (f *filaTrabajos) Pop() interface{} {
+	n := len(f.trabajos)
+	trabajo := f.trabajos[n-1]
+	f.trabajos = f.trabajos[:n-1]
+	return trabajo
+}
+
+func (f *filaTrabajos) meter(trabajo TrabajoPendiente) {
+	heap.Push(f, trabajo)
+}
+
+// primero devuelve el trabajo que saldrá con sacar, sin sacarlo
+func (f *filaTrabajos) primero() TrabajoPendiente {
+	return f.trabajos[0]
+}
+
+func (f *filaTrabajos) sacar() TrabajoPendiente {
+	return heap.Pop(f).(TrabajoPendiente)
+}
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"os"
 	"sync"
 	"time"
 )
@@ -14,17 +16,94 @@ type Taller struct {
 	incidenciaManager *IncidenciaManager
 	terminar          chan bool
 	wg                *sync.WaitGroup
+	trabajos          map[int]*seguimientoTrabajo
+	nextTrabajoID     int
+	envejecimiento    time.Duration
+	esperaSecundaria  time.Duration
+	tiempos           *ModeloTiempos
+	reloj             Reloj
+	eventos           *BusEventos
+	mutex             sync.Mutex
+
+	// Estado del cierre, ver Cerrar
+	cerrando        bool
+	repartoParado   chan struct{}
+	repartiendo     bool
+	envios          sync.WaitGroup
+	rutinas         sync.WaitGroup
+	colasPersonales map[int]chan TrabajoPendiente
+	abandonados     []TrabajoPendiente
+
+	// Avisos de plazas libres para el reparto, ver avisarPlazaLibre
+	plazaLiberada chan struct{}
+	conPlaza      map[Especialidad]bool
 }
 
 func NewTaller(mm *MecanicoManager, vm *VehiculoManager, im *IncidenciaManager) *Taller {
-	return &Taller{
+	// Los managers publican en el mismo bus que el taller
+	eventos := NewBusEventos()
+	mm.eventos = eventos
+	im.eventos = eventos
+
+	t := &Taller{
 		plazasOcupadas:    0,
 		colaTrabajos:      make(chan TrabajoPendiente, 100),
 		mecanicoManager:   mm,
 		vehiculoManager:   vm,
 		incidenciaManager: im,
 		terminar:          make(chan bool),
+		trabajos:          make(map[int]*seguimientoTrabajo),
+		nextTrabajoID:     1,
+		envejecimiento:    EnvejecimientoDefecto,
+		esperaSecundaria:  EsperaSecundariaDefecto,
+		tiempos:           ModeloTiemposPorDefecto(),
+		reloj:             RelojReal{},
+		eventos:           eventos,
+		repartoParado:     make(chan struct{}),
+		colasPersonales:   make(map[int]chan TrabajoPendiente),
+		plazaLiberada:     make(chan struct{}, 1),
+		conPlaza:          make(map[Especialidad]bool),
 	}
+	mm.avisarPlazaLibre = t.avisarPlazaLibre
+	return t
+}
+
+// Eventos devuelve el bus donde el taller publica lo que va pasando
+func (t *Taller) Eventos() *BusEventos {
+	return t.eventos
+}
+
+// ConfigurarEnvejecimiento cambia cada cuánto sube de prioridad un trabajo
+// que espera (0 para no envejecer). Tiene efecto al llamar a IniciarTaller.
+func (t *Taller) ConfigurarEnvejecimiento(cada time.Duration) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.envejecimiento = cada
+}
+
+// ConfigurarEsperaSecundaria cambia cuánto espera un trabajo antes de
+// aceptar a un mecánico que tiene su especialidad como secundaria (negativo
+// para no recurrir nunca a ellos). Tiene efecto al llamar a IniciarTaller.
+func (t *Taller) ConfigurarEsperaSecundaria(espera time.Duration) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.esperaSecundaria = espera
+}
+
+// ConfigurarTiempos cambia el modelo con el que se calcula lo que tarda
+// cada reparación
+func (t *Taller) ConfigurarTiempos(tiempos *ModeloTiempos) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.tiempos = tiempos
+}
+
+// ConfigurarReloj cambia el reloj del taller. Hay que llamarlo antes de
+// IniciarTaller y de añadir trabajos.
+func (t *Taller) ConfigurarReloj(reloj Reloj) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.reloj = reloj
 }
 
 func (t *Taller) IniciarTaller() {
@@ -36,46 +115,188 @@ func (t *Taller) IniciarTaller() {
 		go t.ArrancarRutinaMecanico(&mecanicos[i])
 	}
 
+	t.mutex.Lock()
+	t.repartiendo = true
+	t.mutex.Unlock()
 	go t.procesarTrabajos()
 }
 
 func (t *Taller) ArrancarRutinaMecanico(m *Mecanico) {
+	t.mutex.Lock()
+	if t.cerrando {
+		t.mutex.Unlock()
+		return
+	}
+	t.rutinas.Add(1)
+	t.colasPersonales[m.ID] = m.ColaPersonal
+	t.mutex.Unlock()
+	defer t.rutinas.Done()
+
 	for trabajo := range m.ColaPersonal {
-		t.atenderVehiculo(trabajo, *m)
+		inicio := t.reloj.Ahora()
+		if !t.registrarInicio(trabajo.ID, inicio) {
+			// Al cerrar solo se acaba lo que ya estaba empezado
+			t.mecanicoManager.DecrementarPlaza(m.ID)
+			t.abandonarTrabajo(trabajo)
+			continue
+		}
+		t.atenderVehiculo(trabajo, *m, inicio)
 	}
 }
 
+// procesarTrabajos reparte los trabajos de la cola GENERAL. Cada especialidad
+// tiene su propia fila, ordenada por prioridad con envejecimiento; cuando
+// no hay hueco la fila espera a que DecrementarPlaza (o un mecánico nuevo)
+// avise de que se ha liberado una plaza de esa especialidad, o a que el
+// primero de la fila lleve esperando lo bastante para aceptar a un mecánico
+// con la especialidad como secundaria.
 func (t *Taller) procesarTrabajos() {
+	defer close(t.repartoParado)
+
+	t.mutex.Lock()
+	envejecimiento := t.envejecimiento
+	esperaSecundaria := t.esperaSecundaria
+	t.mutex.Unlock()
+
+	filas := make(map[Especialidad]*filaTrabajos)
+	fila := func(especialidad Especialidad) *filaTrabajos {
+		if filas[especialidad] == nil {
+			filas[especialidad] = &filaTrabajos{envejecimiento: envejecimiento}
+		}
+		return filas[especialidad]
+	}
+
 	for {
+		// Si hay que parar, se para antes de repartir nada más
+		select {
+		case <-t.terminar:
+			t.abandonarFilas(filas)
+			fmt.Println("Taller cerrado")
+			return
+		default:
+		}
+
+		var vencimiento <-chan time.Time
+		if espera, ok := proximaEsperaSecundaria(filas, esperaSecundaria, t.reloj.Ahora()); ok {
+			vencimiento = t.reloj.Despues(espera)
+		}
+
 		select {
 		case trabajo := <-t.colaTrabajos:
-			especialidadRequerida := t.obtenerEspecialidadPorTipo(trabajo.Incidencia.Tipo)
+			especialidad := t.obtenerEspecialidadPorTipo(trabajo.Incidencia.Tipo)
+			fila(especialidad).meter(trabajo)
+			t.repartirFila(especialidad, fila(especialidad), esperaSecundaria)
 
-			mecanicoAsignado := t.buscarMecanicoConHueco(especialidadRequerida)
+		case <-t.plazaLiberada:
+			for _, especialidad := range t.especialidadesConPlaza() {
+				t.repartirFila(especialidad, fila(especialidad), esperaSecundaria)
+			}
 
-			if mecanicoAsignado == nil {
-				time.Sleep(50 * time.Millisecond)
-				t.colaTrabajos <- trabajo
-				continue
+		case <-vencimiento:
+			for especialidad, f := range filas {
+				t.repartirFila(especialidad, f, esperaSecundaria)
 			}
 
-			t.mecanicoManager.IncrementarPlaza(mecanicoAsignado.ID)
+		case <-t.terminar:
+			t.abandonarFilas(filas)
+			fmt.Println("Taller cerrado")
+			return
+		}
+	}
+}
 
-			t.incidenciaManager.AsignarMecanico(trabajo.Incidencia.ID, mecanicoAsignado.ID)
+// repartirFila asigna trabajos de la fila mientras haya mecánicos con hueco.
+// Se prefiere al más cualificado de la especialidad; si no hay ninguno y el
+// primero de la fila ya ha esperado esperaSecundaria, vale uno que la tenga
+// como secundaria.
+func (t *Taller) repartirFila(especialidad Especialidad, fila *filaTrabajos, esperaSecundaria time.Duration) {
+	for fila.Len() > 0 {
+		mecanicoAsignado, ok := t.mecanicoManager.ReservarPlaza(especialidad)
+		if !ok && esperaSecundaria >= 0 && t.reloj.Desde(fila.primero().TiempoInicio) >= esperaSecundaria {
+			mecanicoAsignado, ok = t.mecanicoManager.ReservarPlazaSecundaria(especialidad)
+		}
+		if !ok {
+			return
+		}
+		t.asignarTrabajo(fila.sacar(), mecanicoAsignado)
+	}
+}
 
-			mecanicoAsignado.ColaPersonal <- trabajo
+// proximaEsperaSecundaria dice cuánto falta para que el primero de alguna
+// fila pueda ir a un mecánico secundario. Si ya puede (o no hay filas con
+// trabajo) no hace falta temporizador: el siguiente aviso de plaza libre
+// lo reparte.
+func proximaEsperaSecundaria(filas map[Especialidad]*filaTrabajos, esperaSecundaria time.Duration, ahora time.Time) (time.Duration, bool) {
+	if esperaSecundaria < 0 {
+		return 0, false
+	}
+	var proxima time.Duration
+	hay := false
+	for _, fila := range filas {
+		if fila.Len() == 0 {
+			continue
+		}
+		falta := fila.primero().TiempoInicio.Add(esperaSecundaria).Sub(ahora)
+		if falta > 0 && (!hay || falta < proxima) {
+			proxima, hay = falta, true
+		}
+	}
+	return proxima, hay
+}
 
-			fmt.Printf("-> Coche asignado a %s (Ocupación: %d/2)\n",
-				mecanicoAsignado.Nombre, mecanicoAsignado.PlazasOcupadas)
+// asignarTrabajo entrega el trabajo a un mecánico al que ya se le ha
+// reservado la plaza
+func (t *Taller) asignarTrabajo(trabajo TrabajoPendiente, mecanicoAsignado Mecanico) {
+	t.incidenciaManager.AsignarMecanico(trabajo.Incidencia.ID, mecanicoAsignado.ID)
+	t.registrarAsignacion(trabajo.ID, mecanicoAsignado.ID)
+	t.eventos.Publicar(Evento{
+		Tipo:         TrabajoAsignado,
+		IncidenciaID: trabajo.Incidencia.ID,
+		VehiculoID:   trabajo.Vehiculo.ID,
+		MecanicoID:   mecanicoAsignado.ID,
+	})
+
+	mecanicoAsignado.ColaPersonal <- trabajo
+
+	fmt.Printf("-> Coche asignado a %s (Ocupación: %d/2)\n",
+		mecanicoAsignado.Nombre, mecanicoAsignado.PlazasOcupadas)
+}
 
-		case <-t.terminar:
-			fmt.Println("Taller cerrado")
-			return
+func (t *Taller) abandonarFilas(filas map[Especialidad]*filaTrabajos) {
+	for _, fila := range filas {
+		for _, trabajo := range fila.trabajos {
+			t.abandonarTrabajo(trabajo)
 		}
 	}
 }
 
-func (t *Taller) atenderVehiculo(trabajo TrabajoPendiente, mecanico Mecanico) {
+// avisarPlazaLibre apunta que hay hueco para la especialidad y despierta al
+// reparto. Los avisos que llegan mientras el reparto está ocupado se juntan
+// en uno solo, así que nunca bloquea.
+func (t *Taller) avisarPlazaLibre(especialidad Especialidad) {
+	t.mutex.Lock()
+	t.conPlaza[especialidad] = true
+	t.mutex.Unlock()
+
+	select {
+	case t.plazaLiberada <- struct{}{}:
+	default:
+	}
+}
+
+func (t *Taller) especialidadesConPlaza() []Especialidad {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	especialidades := make([]Especialidad, 0, len(t.conPlaza))
+	for especialidad := range t.conPlaza {
+		especialidades = append(especialidades, especialidad)
+	}
+	t.conPlaza = make(map[Especialidad]bool)
+	return especialidades
+}
+
+func (t *Taller) atenderVehiculo(trabajo TrabajoPendiente, mecanico Mecanico, inicio time.Time) {
 	vehiculo := trabajo.Vehiculo
 	incidencia := trabajo.Incidencia
 
@@ -83,9 +304,19 @@ func (t *Taller) atenderVehiculo(trabajo TrabajoPendiente, mecanico Mecanico) {
 		mecanico.Nombre, mecanico.ID, vehiculo.Marca)
 
 	t.incidenciaManager.CambiarEstado(incidencia.ID, EnProceso)
-
-	tiempoAtencion := ObtenerTiempoAtencion(incidencia.Tipo)
-	time.Sleep(tiempoAtencion)
+	t.eventos.Publicar(Evento{
+		Tipo:         TrabajoIniciado,
+		Instante:     inicio,
+		IncidenciaID: incidencia.ID,
+		VehiculoID:   vehiculo.ID,
+		MecanicoID:   mecanico.ID,
+	})
+
+	t.mutex.Lock()
+	tiempos := t.tiempos
+	t.mutex.Unlock()
+	tiempoAtencion := tiempos.TiempoReparacion(incidencia.Tipo, mecanico.Experiencia)
+	t.reloj.Dormir(tiempoAtencion)
 
 	tiempoSegundos := tiempoAtencion.Seconds()
 	t.vehiculoManager.ActualizarTiempoAcumulado(vehiculo.ID, tiempoSegundos)
@@ -96,30 +327,30 @@ func (t *Taller) atenderVehiculo(trabajo TrabajoPendiente, mecanico Mecanico) {
 		fmt.Printf("PRIORIDAD: Vehículo %s ha acumulado %.1f segundos. Asignando mecánico adicional...\n",
 			vehiculo.Matricula, tiempoAcumulado)
 
-		mecanicoAdicional := t.buscarCualquierMecanicoConHueco()
+		mecanicoAdicional, ok := t.mecanicoManager.ReservarPlazaCualquiera()
 
-		if mecanicoAdicional == nil {
+		if !ok {
 			especialidadRequerida := t.obtenerEspecialidadPorTipo(incidencia.Tipo)
 			fmt.Printf("No hay mecánicos adicionales disponibles, contratando uno de %s...\n", especialidadRequerida)
 
-			nuevoMecanico := t.mecanicoManager.CrearMecanico(
-				fmt.Sprintf("Mecanico-Extra-%d", t.mecanicoManager.nextID),
-				especialidadRequerida,
-				1,
-			)
-
-			go t.ArrancarRutinaMecanico(&nuevoMecanico)
+			mecanicoAdicional = t.mecanicoManager.CrearMecanicoExtra(especialidadRequerida)
 
-			mecanicoAdicional = &nuevoMecanico
+			go t.ArrancarRutinaMecanico(&mecanicoAdicional)
 		}
 
-		t.mecanicoManager.IncrementarPlaza(mecanicoAdicional.ID)
 		t.incidenciaManager.AsignarMecanico(incidencia.ID, mecanicoAdicional.ID)
+		t.registrarAyudante(trabajo.ID, mecanicoAdicional.ID)
+		t.eventos.Publicar(Evento{
+			Tipo:         MecanicoAdicional,
+			IncidenciaID: incidencia.ID,
+			VehiculoID:   vehiculo.ID,
+			MecanicoID:   mecanicoAdicional.ID,
+		})
 
 		fmt.Printf("Mecánico adicional %s (#%d) asignado al vehículo %s\n",
 			mecanicoAdicional.Nombre, mecanicoAdicional.ID, vehiculo.Matricula)
 
-		time.Sleep(tiempoAtencion)
+		t.reloj.Dormir(tiempoAtencion)
 		t.vehiculoManager.ActualizarTiempoAcumulado(vehiculo.ID, tiempoSegundos)
 
 		t.mecanicoManager.DecrementarPlaza(mecanicoAdicional.ID)
@@ -131,6 +362,14 @@ func (t *Taller) atenderVehiculo(trabajo TrabajoPendiente, mecanico Mecanico) {
 	t.incidenciaManager.EliminarIncidencia(incidencia.ID)
 
 	t.mecanicoManager.DecrementarPlaza(mecanico.ID)
+	t.registrarFin(trabajo.ID)
+	t.eventos.Publicar(Evento{
+		Tipo:         TrabajoTerminado,
+		IncidenciaID: incidencia.ID,
+		VehiculoID:   vehiculo.ID,
+		MecanicoID:   mecanico.ID,
+		Duracion:     t.reloj.Desde(inicio),
+	})
 
 	if t.wg != nil {
 		t.wg.Done()
@@ -141,12 +380,24 @@ func (t *Taller) atenderVehiculo(trabajo TrabajoPendiente, mecanico Mecanico) {
 		vehiculo.Marca, mecanico.Nombre, tiempoTotal)
 }
 
-func (t *Taller) AgregarTrabajo(vehiculo Vehiculo, incidencia Incidencia) {
+func (t *Taller) AgregarTrabajo(vehiculo Vehiculo, incidencia Incidencia) error {
+	t.mutex.Lock()
+	if t.cerrando {
+		t.mutex.Unlock()
+		return fmt.Errorf("el taller está cerrando, no se admiten más trabajos")
+	}
+	t.envios.Add(1)
+	defer t.envios.Done()
 	trabajo := TrabajoPendiente{
+		ID:           t.nextTrabajoID,
 		Vehiculo:     &vehiculo,
 		Incidencia:   &incidencia,
-		TiempoInicio: time.Now(),
+		TiempoInicio: t.reloj.Ahora(),
 	}
+	t.nextTrabajoID++
+	t.mutex.Unlock()
+	t.registrarTrabajo(trabajo)
+	t.eventos.Publicar(Evento{Tipo: TrabajoEncolado, IncidenciaID: incidencia.ID, VehiculoID: vehiculo.ID})
 
 	if t.wg != nil {
 		t.wg.Add(1)
@@ -156,28 +407,6 @@ func (t *Taller) AgregarTrabajo(vehiculo Vehiculo, incidencia Incidencia) {
 		vehiculo.Marca, vehiculo.Matricula)
 
 	t.colaTrabajos <- trabajo
-}
-
-func (t *Taller) buscarMecanicoConHueco(especialidad Especialidad) *Mecanico {
-	mecanicos := t.mecanicoManager.ListarMecanicos()
-
-	for i := 0; i < len(mecanicos); i++ {
-		m := &mecanicos[i]
-		if m.Activo && m.Especialidad == especialidad && m.PlazasOcupadas < 2 {
-			return m
-		}
-	}
-	return nil
-}
-
-func (t *Taller) buscarCualquierMecanicoConHueco() *Mecanico {
-	mecanicos := t.mecanicoManager.ListarMecanicos()
-	for i := 0; i < len(mecanicos); i++ {
-		m := &mecanicos[i]
-		if m.Activo && m.PlazasOcupadas < 2 {
-			return m
-		}
-	}
 	return nil
 }
 
@@ -194,25 +423,13 @@ func (t *Taller) obtenerEspecialidadPorTipo(tipo TipoIncidencia) Especialidad {
 	return EspecialidadMecanica
 }
 
+// DetenerTaller cierra el taller esperando a que se terminen los trabajos
+// empezados
 func (t *Taller) DetenerTaller() {
-	t.terminar <- true
+	t.Cerrar(context.Background())
 }
 
+// ObtenerEstadoTaller muestra por pantalla el estado actual del taller
 func (t *Taller) ObtenerEstadoTaller() {
-	fmt.Println("\n===== ESTADO DEL TALLER =====")
-
-	mecanicosActivos := t.mecanicoManager.ContarMecanicosActivos()
-	plazasTotales := mecanicosActivos * 2
-
-	fmt.Printf("Plazas totales: %d (%d mecánicos x 2)\n", plazasTotales, mecanicosActivos)
-	fmt.Printf("Trabajos en cola GENERAL: %d\n", len(t.colaTrabajos))
-
-	mecanicos := t.mecanicoManager.ListarMecanicos()
-	fmt.Println("----- Carga de Mecánicos -----")
-	for _, m := range mecanicos {
-		if m.Activo {
-			fmt.Printf("  - %s (%s): %d/2 Plazas Ocupadas\n\n", m.Nombre, m.Especialidad, m.PlazasOcupadas)
-		}
-	}
-	fmt.Println("=============================")
+	RenderizarEstado(os.Stdout, t.Estado(), FormatoTexto)
 }
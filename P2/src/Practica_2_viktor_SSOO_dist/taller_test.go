@@ -1,12 +1,23 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"sync"
 	"testing"
+	"time"
 )
 
+// setupTest usa un reloj virtual que avanza solo, así que los segundos de
+// cada reparación no se esperan de verdad
 func setupTest(t *testing.T, mechanicConfig map[Especialidad]int) (*Taller, *VehiculoManager, *IncidenciaManager, *ClienteManager) {
+	reloj := NewRelojVirtual(time.Now())
+	t.Cleanup(reloj.Automatico(50 * time.Microsecond))
+	return setupTestConReloj(t, mechanicConfig, reloj)
+}
+
+func setupTestConReloj(t *testing.T, mechanicConfig map[Especialidad]int, reloj Reloj) (*Taller, *VehiculoManager, *IncidenciaManager, *ClienteManager) {
 	cm := NewClienteManager()
 	mm := NewMecanicoManager()
 	vm := NewVehiculoManager()
@@ -19,6 +30,7 @@ func setupTest(t *testing.T, mechanicConfig map[Especialidad]int) (*Taller, *Veh
 	}
 
 	taller := NewTaller(mm, vm, im)
+	taller.ConfigurarReloj(reloj)
 	taller.IniciarTaller()
 
 	t.Cleanup(func() {
@@ -28,8 +40,15 @@ func setupTest(t *testing.T, mechanicConfig map[Especialidad]int) (*Taller, *Veh
 	return taller, vm, im, cm
 }
 
+// runTestSimulation usa un reloj virtual, así que los segundos de cada
+// reparación no se esperan de verdad
 func runTestSimulation(t *testing.T, numCars int, tipo TipoIncidencia, mechanicConfig map[Especialidad]int) {
-	taller, vm, im, cm := setupTest(t, mechanicConfig)
+	reloj := NewRelojVirtual(time.Now())
+	parar := reloj.Automatico(50 * time.Microsecond)
+	t.Cleanup(parar)
+	inicio := reloj.Ahora()
+
+	taller, vm, im, cm := setupTestConReloj(t, mechanicConfig, reloj)
 	var wg sync.WaitGroup
 	taller.wg = &wg
 
@@ -54,6 +73,7 @@ func runTestSimulation(t *testing.T, numCars int, tipo TipoIncidencia, mechanicC
 	}
 
 	wg.Wait()
+	t.Logf("%d coches reparados en %v simulados", numCars, reloj.Desde(inicio))
 }
 
 // --- CASO 1: Test de Comparativa DUPLICANDO COCHES ---
@@ -115,3 +135,334 @@ func Test_Proporcion_Favorable(t *testing.T) {
 func Test_Proporcion_Desfavorable(t *testing.T) {
 	runTestSimulation(t, 10, Mecanica, configProporcion_Desfavorable)
 }
+
+// --- Eventos: el ciclo de vida de un trabajo llega a los suscriptores ---
+
+func Test_EventosCicloTrabajo(t *testing.T) {
+	taller, vm, im, cm := setupTest(t, map[Especialidad]int{EspecialidadMecanica: 1})
+	var wg sync.WaitGroup
+	taller.wg = &wg
+
+	var tipos []TipoEvento
+	taller.Eventos().Suscribir(func(e Evento) {
+		tipos = append(tipos, e.Tipo)
+	})
+	var auditoria bytes.Buffer
+	RegistrarAuditoria(taller.Eventos(), &auditoria)
+
+	cliente := cm.CrearCliente("Cliente", "000000000", "test@test.com")
+	v, _ := vm.CrearVehiculo("Matricula1", "TEST-CAR1", "modelo1", cliente.ID, cm)
+	incidencia := im.CrearIncidencia(Mecanica, Alta, "Test incidence", v.ID)
+	taller.AgregarTrabajo(v, incidencia)
+	wg.Wait()
+	taller.Eventos().Cerrar()
+
+	esperados := []TipoEvento{IncidenciaCreada, TrabajoEncolado, TrabajoAsignado, IncidenciaEstadoCambiado,
+		TrabajoIniciado, IncidenciaEstadoCambiado, TrabajoTerminado}
+	if fmt.Sprint(tipos) != fmt.Sprint(esperados) {
+		t.Fatalf("eventos recibidos %v, se esperaban %v", tipos, esperados)
+	}
+	if lineas := bytes.Count(auditoria.Bytes(), []byte("\n")); lineas != len(esperados) {
+		t.Fatalf("la auditoría tiene %d líneas, se esperaban %d", lineas, len(esperados))
+	}
+}
+
+// --- Cierre: lo empezado se termina y lo demás se devuelve como pendiente ---
+
+// prepararCierre usa un reloj virtual parado, así que la primera reparación
+// no acaba hasta que el test haga avanzar el reloj
+func prepararCierre(t *testing.T) (*Taller, *RelojVirtual) {
+	reloj := NewRelojVirtual(time.Now())
+	taller, vm, im, cm := setupTestConReloj(t, map[Especialidad]int{EspecialidadMecanica: 1}, reloj)
+	cliente := cm.CrearCliente("Cliente", "000000000", "test@test.com")
+	for i := 0; i < 4; i++ {
+		v, _ := vm.CrearVehiculo(fmt.Sprintf("Matricula%d", i+1), "TEST-CAR", "modelo", cliente.ID, cm)
+		taller.AgregarTrabajo(v, im.CrearIncidencia(Mecanica, Alta, "Test incidence", v.ID))
+	}
+
+	// Esperamos a que el mecánico tenga dos coches y uno en reparación
+	for {
+		estado := taller.Estado()
+		if len(estado.Mecanicos[0].EnCurso) == 1 && len(estado.Mecanicos[0].Cola) == 1 {
+			return taller, reloj
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func Test_CierreOrdenado(t *testing.T) {
+	taller, reloj := prepararCierre(t)
+	t.Cleanup(reloj.Automatico(50 * time.Microsecond))
+
+	ctx, cancelar := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancelar()
+	resumen, err := taller.Cerrar(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resumen.PlazoAgotado || len(resumen.Terminados) != 1 || len(resumen.Abandonados) != 3 {
+		t.Fatalf("resumen incorrecto: %+v", resumen)
+	}
+	if resumen.Terminados[0].Matricula != "Matricula1" {
+		t.Fatalf("debía terminarse el primer coche: %+v", resumen.Terminados)
+	}
+
+	v := Vehiculo{ID: 99, Matricula: "Tarde"}
+	if err := taller.AgregarTrabajo(v, Incidencia{ID: 99, Tipo: Mecanica}); err == nil {
+		t.Fatal("un taller cerrado no debería aceptar trabajos")
+	}
+	if _, err := taller.Cerrar(context.Background()); err == nil {
+		t.Fatal("cerrar dos veces debería dar error")
+	}
+}
+
+func Test_CierrePlazoAgotado(t *testing.T) {
+	taller, _ := prepararCierre(t)
+
+	ctx, cancelar := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancelar()
+	resumen, _ := taller.Cerrar(ctx)
+	if !resumen.PlazoAgotado || len(resumen.Terminados) != 0 || len(resumen.Abandonados) != 4 {
+		t.Fatalf("resumen incorrecto: %+v", resumen)
+	}
+}
+
+// --- Reparto: se espera a que haya plaza sin reencolar y respetando prioridad ---
+
+func Test_RepartoPorPrioridad(t *testing.T) {
+	mm := NewMecanicoManager()
+	vm := NewVehiculoManager()
+	im := NewIncidenciaManager()
+	mec := mm.CrearMecanico("Electricista", EspecialidadElectrica, 5)
+	taller := NewTaller(mm, vm, im)
+
+	// Sin rutina de mecánico: los trabajos se quedan en su cola personal y
+	// somos nosotros quienes liberamos las plazas
+	go taller.procesarTrabajos()
+	t.Cleanup(taller.DetenerTaller)
+
+	prioridades := []Prioridad{Baja, Baja, Baja, Alta}
+	for i, p := range prioridades {
+		v := Vehiculo{ID: i + 1, Matricula: fmt.Sprintf("Matricula%d", i+1)}
+		taller.AgregarTrabajo(v, Incidencia{ID: i + 1, Tipo: Electrica, Prioridad: p})
+	}
+	// Muchos más trabajos de los que caben en la cola GENERAL no la bloquean
+	for i := 0; i < 150; i++ {
+		v := Vehiculo{ID: 100 + i, Matricula: "Extra"}
+		taller.AgregarTrabajo(v, Incidencia{ID: 100 + i, Tipo: Carroceria, Prioridad: Media})
+	}
+
+	siguiente := func() int {
+		select {
+		case trabajo := <-mec.ColaPersonal:
+			return trabajo.Vehiculo.ID
+		case <-time.After(2 * time.Second):
+			t.Fatal("no se asignó ningún trabajo")
+			return 0
+		}
+	}
+
+	if a, b := siguiente(), siguiente(); a != 1 || b != 2 {
+		t.Fatalf("primeros trabajos asignados %d y %d, se esperaban 1 y 2", a, b)
+	}
+	mm.DecrementarPlaza(mec.ID)
+	if v := siguiente(); v != 4 {
+		t.Fatalf("con plaza libre debía ir el de prioridad alta (4), fue %d", v)
+	}
+	mm.DecrementarPlaza(mec.ID)
+	if v := siguiente(); v != 3 {
+		t.Fatalf("se esperaba el trabajo 3, fue %d", v)
+	}
+}
+
+func Test_FilaEnvejecimiento(t *testing.T) {
+	base := time.Now()
+	trabajo := func(id int, p Prioridad, llegada time.Duration) TrabajoPendiente {
+		return TrabajoPendiente{ID: id, Incidencia: &Incidencia{Prioridad: p}, TiempoInicio: base.Add(llegada)}
+	}
+
+	// Sin envejecer, la baja que llegó primero sale la última; envejeciendo
+	// un nivel cada 20s ya ha ganado a la media y a una de las altas
+	for cada, esperado := range map[time.Duration]string{0: "[4 3 2 1]", 20 * time.Second: "[4 1 2 3]"} {
+		fila := &filaTrabajos{envejecimiento: cada}
+		fila.meter(trabajo(1, Baja, 0))
+		fila.meter(trabajo(2, Media, 30*time.Second))
+		fila.meter(trabajo(3, Alta, 90*time.Second))
+		fila.meter(trabajo(4, Alta, 10*time.Second))
+
+		orden := make([]int, 0)
+		for fila.Len() > 0 {
+			orden = append(orden, fila.sacar().ID)
+		}
+		if fmt.Sprint(orden) != esperado {
+			t.Errorf("envejecimiento %v: orden %v, se esperaba %s", cada, orden, esperado)
+		}
+	}
+}
+
+// --- Concurrencia: reparto, reparaciones y menú a la vez (go test -race) ---
+
+func Test_ReservarPlazaConcurrente(t *testing.T) {
+	mm := NewMecanicoManager()
+	for i := 0; i < 3; i++ {
+		mm.CrearMecanico("Mecanico", EspecialidadMecanica, 5)
+	}
+
+	var reservas sync.WaitGroup
+	var mutex sync.Mutex
+	conseguidas := 0
+	for i := 0; i < 50; i++ {
+		reservas.Add(1)
+		go func() {
+			defer reservas.Done()
+			if _, ok := mm.ReservarPlaza(EspecialidadMecanica); ok {
+				mutex.Lock()
+				conseguidas++
+				mutex.Unlock()
+			}
+		}()
+	}
+	reservas.Wait()
+
+	if conseguidas != 3*PlazasPorMecanico {
+		t.Fatalf("se reservaron %d plazas, había %d", conseguidas, 3*PlazasPorMecanico)
+	}
+	for _, m := range mm.ListarMecanicos() {
+		if m.PlazasOcupadas != PlazasPorMecanico {
+			t.Fatalf("el mecánico %d tiene %d plazas ocupadas", m.ID, m.PlazasOcupadas)
+		}
+	}
+}
+
+func Test_ConcurrenciaMenuYReparto(t *testing.T) {
+	taller, vm, im, cm := setupTest(t, map[Especialidad]int{EspecialidadMecanica: 2})
+	mm := taller.mecanicoManager
+	var wg sync.WaitGroup
+	taller.wg = &wg
+
+	cliente := cm.CrearCliente("Cliente", "000000000", "test@test.com")
+	for i := 0; i < 4; i++ {
+		v, _ := vm.CrearVehiculo(fmt.Sprintf("Matricula%d", i+1), "TEST-CAR", "modelo", cliente.ID, cm)
+		taller.AgregarTrabajo(v, im.CrearIncidencia(Mecanica, Alta, "Test incidence", v.ID))
+	}
+
+	// Mientras se reparan los coches, "el menú" consulta y modifica los
+	// mismos datos que usan el reparto y los mecánicos
+	hecho := make(chan struct{})
+	menu := make(chan struct{})
+	go func() {
+		defer close(menu)
+		for i := 0; ; i++ {
+			select {
+			case <-hecho:
+				return
+			default:
+			}
+			for _, m := range mm.ListarMecanicos() {
+				mm.ActualizarMecanico(m.ID, fmt.Sprintf("Mecanico %d", i), "", 0)
+			}
+			for _, inc := range im.ListarIncidencias() {
+				im.ActualizarIncidencia(inc.ID, "", "", fmt.Sprintf("Revisión %d", i))
+			}
+			v, _ := vm.CrearVehiculo(fmt.Sprintf("Nueva%d", i), "TEST-CAR", "modelo", cliente.ID, cm)
+			extra := im.CrearIncidencia(Electrica, Baja, "Sin mecánico", v.ID)
+			im.EliminarIncidencia(extra.ID)
+			vm.EliminarVehiculo(v.ID)
+			im.ContarTodasIncidencias()
+			taller.Estado()
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	wg.Wait()
+	close(hecho)
+	<-menu
+
+	for _, m := range mm.ListarMecanicos() {
+		if m.PlazasOcupadas != 0 {
+			t.Fatalf("el mecánico %d se quedó con %d plazas ocupadas", m.ID, m.PlazasOcupadas)
+		}
+	}
+	if n := len(im.ListarIncidencias()); n != 0 {
+		t.Fatalf("quedan %d incidencias sin eliminar", n)
+	}
+}
+
+// --- Habilidades: primero el más cualificado, luego los secundarios ---
+
+func Test_ReservarMasCualificado(t *testing.T) {
+	mm := NewMecanicoManager()
+	novato := mm.CrearMecanico("Novato", EspecialidadElectrica, 1)
+	veterano := mm.CrearMecanico("Veterano", EspecialidadElectrica, 10)
+	chapista := mm.CrearMecanico("Chapista", EspecialidadCarroceria, 10)
+	mm.AgregarHabilidad(chapista.ID, EspecialidadElectrica, NivelExperto)
+
+	var ids []int
+	for i := 0; i < 5; i++ {
+		m, ok := mm.ReservarPlaza(EspecialidadElectrica)
+		if !ok {
+			break
+		}
+		ids = append(ids, m.ID)
+	}
+	esperado := []int{veterano.ID, veterano.ID, novato.ID, novato.ID}
+	if fmt.Sprint(ids) != fmt.Sprint(esperado) {
+		t.Fatalf("reservas %v, se esperaban %v", ids, esperado)
+	}
+
+	if m, ok := mm.ReservarPlazaSecundaria(EspecialidadElectrica); !ok || m.ID != chapista.ID {
+		t.Fatalf("el chapista debía servir como electricista secundario: %+v %v", m, ok)
+	}
+	if err := mm.QuitarHabilidad(chapista.ID, EspecialidadCarroceria); err == nil {
+		t.Fatal("no se debería poder quitar la especialidad principal")
+	}
+	if err := mm.AgregarHabilidad(chapista.ID, EspecialidadMecanica, 7); err == nil {
+		t.Fatal("se esperaba error con un nivel no válido")
+	}
+	if err := mm.AgregarHabilidad(chapista.ID, "Mecanica", NivelBasico); err == nil {
+		t.Fatal("se esperaba error con una especialidad no válida")
+	}
+	if e, ok := parsearEspecialidad(" Mecánica "); !ok || e != EspecialidadMecanica {
+		t.Fatalf("\" Mecánica \" debía leerse como %s: %q %v", EspecialidadMecanica, e, ok)
+	}
+}
+
+func Test_RepartoSecundarioTrasEspera(t *testing.T) {
+	mm := NewMecanicoManager()
+	vm := NewVehiculoManager()
+	im := NewIncidenciaManager()
+	electricista := mm.CrearMecanico("Electricista", EspecialidadElectrica, 5)
+	chapista := mm.CrearMecanico("Chapista", EspecialidadCarroceria, 5)
+	mm.AgregarHabilidad(chapista.ID, EspecialidadElectrica, NivelBasico)
+	taller := NewTaller(mm, vm, im)
+	taller.ConfigurarEsperaSecundaria(300 * time.Millisecond)
+
+	go taller.procesarTrabajos()
+	t.Cleanup(taller.DetenerTaller)
+
+	for i := 1; i <= 3; i++ {
+		v := Vehiculo{ID: i, Matricula: fmt.Sprintf("Matricula%d", i)}
+		taller.AgregarTrabajo(v, Incidencia{ID: i, Tipo: Electrica, Prioridad: Media})
+	}
+
+	for i := 0; i < PlazasPorMecanico; i++ {
+		select {
+		case <-electricista.ColaPersonal:
+		case <-time.After(2 * time.Second):
+			t.Fatal("el electricista debía recibir los primeros trabajos")
+		}
+	}
+	select {
+	case trabajo := <-chapista.ColaPersonal:
+		t.Fatalf("el trabajo %d fue al chapista sin esperar", trabajo.ID)
+	case <-time.After(100 * time.Millisecond):
+	}
+	select {
+	case trabajo := <-chapista.ColaPersonal:
+		if trabajo.Vehiculo.ID != 3 {
+			t.Fatalf("el chapista recibió el vehículo %d, se esperaba el 3", trabajo.Vehiculo.ID)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("pasada la espera el chapista debía coger el trabajo")
+	}
+}
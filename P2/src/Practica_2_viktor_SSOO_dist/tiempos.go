@@ -0,0 +1,291 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// MODELO DE TIEMPOS
+// ============================================================================
+
+// TipoDistribucion es la forma en que varía un tiempo alrededor de su media
+type TipoDistribucion string
+
+const (
+	DistribucionFija        TipoDistribucion = "fija"
+	DistribucionUniforme    TipoDistribucion = "uniforme"
+	DistribucionExponencial TipoDistribucion = "exponencial"
+	DistribucionLogNormal   TipoDistribucion = "lognormal"
+)
+
+// Distribucion describe cómo se reparte un tiempo. Todas conservan la media,
+// así que cambiar de distribución no cambia el tiempo medio sino cuánto se
+// aleja cada caso de él.
+type Distribucion struct {
+	Tipo TipoDistribucion `json:"tipo"`
+	// Amplitud es, en la uniforme, lo que se aleja como mucho de la media en
+	// proporción (0.2 = ±20 %)
+	Amplitud float64 `json:"amplitud,omitempty"`
+	// Sigma es la desviación típica del logaritmo en la lognormal
+	Sigma float64 `json:"sigma,omitempty"`
+}
+
+func (d Distribucion) validar() error {
+	switch d.Tipo {
+	case "", DistribucionFija, DistribucionExponencial:
+	case DistribucionUniforme:
+		if d.Amplitud < 0 || d.Amplitud > 1 {
+			return fmt.Errorf("la amplitud de la uniforme debe estar entre 0 y 1, es %v", d.Amplitud)
+		}
+	case DistribucionLogNormal:
+		if d.Sigma < 0 {
+			return fmt.Errorf("la sigma de la lognormal no puede ser negativa, es %v", d.Sigma)
+		}
+	default:
+		return fmt.Errorf("distribución '%s' no válida", d.Tipo)
+	}
+	return nil
+}
+
+// muestrear saca un tiempo de media dada
+func (d Distribucion) muestrear(media time.Duration, rng *rand.Rand) time.Duration {
+	factor := 1.0
+	switch d.Tipo {
+	case DistribucionUniforme:
+		factor = 1 + d.Amplitud*(2*rng.Float64()-1)
+	case DistribucionExponencial:
+		factor = rng.ExpFloat64()
+	case DistribucionLogNormal:
+		// exp(N(-σ²/2, σ)) tiene media 1
+		factor = math.Exp(d.Sigma*rng.NormFloat64() - d.Sigma*d.Sigma/2)
+	}
+	return time.Duration(float64(media) * factor)
+}
+
+// TramoExperiencia multiplica por Factor el tiempo de los mecánicos con al
+// menos Desde años de experiencia
+type TramoExperiencia struct {
+	Desde  int     `json:"desde"`
+	Factor float64 `json:"factor"`
+}
+
+// ModeloTiempos calcula lo que duran las reparaciones del taller. Es seguro
+// usarlo desde varias goroutines.
+type ModeloTiempos struct {
+	// Reparacion es el tiempo base de una reparación según el tipo de
+	// incidencia, antes de aplicar la experiencia del mecánico
+	Reparacion map[TipoIncidencia]time.Duration
+	// Experiencia está ordenada por Desde; se aplica el último tramo al que
+	// llega el mecánico. Sin tramos la experiencia no cuenta.
+	Experiencia []TramoExperiencia
+	// Distribucion se usa para todos los tipos salvo los que tengan una
+	// propia en Distribuciones
+	Distribucion   Distribucion
+	Distribuciones map[TipoIncidencia]Distribucion
+	// Minimo es lo menos que puede durar una reparación
+	Minimo time.Duration
+	// Semilla del generador aleatorio. Con 0 se elige una al primer uso y se
+	// guarda aquí para poder repetir la ejecución.
+	Semilla int64
+
+	rng   *rand.Rand
+	mutex sync.Mutex
+}
+
+// ModeloTiemposPorDefecto da los tiempos de siempre: 5 segundos las
+// mecánicas, 7 las eléctricas y 11 las de carrocería, sin que cuente la
+// experiencia ni el azar
+func ModeloTiemposPorDefecto() *ModeloTiempos {
+	return &ModeloTiempos{
+		Reparacion: map[TipoIncidencia]time.Duration{
+			Mecanica:   5 * time.Second,
+			Electrica:  7 * time.Second,
+			Carroceria: 11 * time.Second,
+		},
+		Distribucion:   Distribucion{Tipo: DistribucionFija},
+		Distribuciones: make(map[TipoIncidencia]Distribucion),
+	}
+}
+
+// Duracion es un time.Duration que en JSON se escribe como texto ("1m30s").
+// También se aceptan números, que se toman como segundos.
+type Duracion time.Duration
+
+func (d Duracion) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duracion) UnmarshalJSON(datos []byte) error {
+	var segundos float64
+	if err := json.Unmarshal(datos, &segundos); err == nil {
+		*d = Duracion(segundos * float64(time.Second))
+		return nil
+	}
+	var texto string
+	if err := json.Unmarshal(datos, &texto); err != nil {
+		return fmt.Errorf("duración no válida: %s", datos)
+	}
+	duracion, err := time.ParseDuration(texto)
+	if err != nil {
+		return err
+	}
+	*d = Duracion(duracion)
+	return nil
+}
+
+// archivoTiempos es el formato del archivo de configuración. Los tipos se
+// escriben como en el menú ("mecanica", "electrica", "carroceria").
+type archivoTiempos struct {
+	Reparacion     map[string]Duracion     `json:"reparacion"`
+	Experiencia    []TramoExperiencia      `json:"experiencia"`
+	Distribucion   *Distribucion           `json:"distribucion"`
+	Distribuciones map[string]Distribucion `json:"distribuciones"`
+	Minimo         *Duracion               `json:"minimo"`
+	Semilla        int64                   `json:"semilla"`
+}
+
+// CargarModeloTiempos lee el modelo de un archivo JSON. Lo que no aparezca
+// en el archivo se queda como en ModeloTiemposPorDefecto, por ejemplo:
+//
+//	{
+//	  "reparacion": {"mecanica": "8s", "carroceria": "15s"},
+//	  "experiencia": [{"desde": 0, "factor": 1.2}, {"desde": 5, "factor": 0.8}],
+//	  "distribucion": {"tipo": "lognormal", "sigma": 0.3},
+//	  "semilla": 42
+//	}
+func CargarModeloTiempos(ruta string) (*ModeloTiempos, error) {
+	datos, err := os.ReadFile(ruta)
+	if err != nil {
+		return nil, err
+	}
+	var archivo archivoTiempos
+	if err := json.Unmarshal(datos, &archivo); err != nil {
+		return nil, fmt.Errorf("%s: %w", ruta, err)
+	}
+
+	m := ModeloTiemposPorDefecto()
+	if err := m.aplicar(archivo); err != nil {
+		return nil, fmt.Errorf("%s: %w", ruta, err)
+	}
+	return m, nil
+}
+
+func (m *ModeloTiempos) aplicar(archivo archivoTiempos) error {
+	for nombre, duracion := range archivo.Reparacion {
+		tipo, ok := parsearTipoIncidencia(nombre)
+		if !ok {
+			return fmt.Errorf("tipo de incidencia '%s' no válido", nombre)
+		}
+		if duracion < 0 {
+			return fmt.Errorf("el tiempo de %s no puede ser negativo", nombre)
+		}
+		m.Reparacion[tipo] = time.Duration(duracion)
+	}
+
+	if archivo.Experiencia != nil {
+		for _, tramo := range archivo.Experiencia {
+			if tramo.Factor <= 0 {
+				return fmt.Errorf("el factor del tramo desde %d años debe ser positivo", tramo.Desde)
+			}
+		}
+		m.Experiencia = append([]TramoExperiencia(nil), archivo.Experiencia...)
+		sort.Slice(m.Experiencia, func(i, j int) bool { return m.Experiencia[i].Desde < m.Experiencia[j].Desde })
+	}
+
+	if archivo.Distribucion != nil {
+		if err := archivo.Distribucion.validar(); err != nil {
+			return err
+		}
+		m.Distribucion = *archivo.Distribucion
+	}
+	for nombre, distribucion := range archivo.Distribuciones {
+		tipo, ok := parsearTipoIncidencia(nombre)
+		if !ok {
+			return fmt.Errorf("tipo de incidencia '%s' no válido", nombre)
+		}
+		if err := distribucion.validar(); err != nil {
+			return fmt.Errorf("%s: %w", nombre, err)
+		}
+		m.Distribuciones[tipo] = distribucion
+	}
+
+	if archivo.Minimo != nil {
+		m.Minimo = time.Duration(*archivo.Minimo)
+	}
+	m.Semilla = archivo.Semilla
+	return nil
+}
+
+// TiempoReparacion es lo que tarda un mecánico con esa experiencia en
+// reparar una incidencia del tipo dado
+func (m *ModeloTiempos) TiempoReparacion(tipo TipoIncidencia, experiencia int) time.Duration {
+	media := time.Duration(float64(m.Reparacion[tipo]) * m.factorExperiencia(experiencia))
+	tiempo := m.muestrear(tipo, media)
+	if tiempo < m.Minimo {
+		tiempo = m.Minimo
+	}
+	return tiempo
+}
+
+func (m *ModeloTiempos) factorExperiencia(experiencia int) float64 {
+	factor := 1.0
+	for _, tramo := range m.Experiencia {
+		if experiencia < tramo.Desde {
+			break
+		}
+		factor = tramo.Factor
+	}
+	return factor
+}
+
+func (m *ModeloTiempos) muestrear(tipo TipoIncidencia, media time.Duration) time.Duration {
+	distribucion, propia := m.Distribuciones[tipo]
+	if !propia {
+		distribucion = m.Distribucion
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return distribucion.muestrear(media, m.generador())
+}
+
+// ElegirSemilla devuelve la semilla de la que sale la secuencia aleatoria,
+// eligiéndola ya si era 0, para poder apuntarla y repetir la ejecución
+func (m *ModeloTiempos) ElegirSemilla() int64 {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.generador()
+	return m.Semilla
+}
+
+// generador crea el generador aleatorio la primera vez. Hay que tener cogido
+// el mutex.
+func (m *ModeloTiempos) generador() *rand.Rand {
+	if m.rng == nil {
+		if m.Semilla == 0 {
+			m.Semilla = time.Now().UnixNano()
+		}
+		m.rng = rand.New(rand.NewSource(m.Semilla))
+	}
+	return m.rng
+}
+
+func parsearTipoIncidencia(s string) (TipoIncidencia, bool) {
+	switch TipoIncidencia(strings.ToLower(s)) {
+	case Mecanica:
+		return Mecanica, true
+	case Electrica:
+		return Electrica, true
+	case Carroceria:
+		return Carroceria, true
+	}
+	return "", false
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_ModeloTiempos(t *testing.T) {
+	m := ModeloTiemposPorDefecto()
+	for tipo, esperado := range map[TipoIncidencia]time.Duration{
+		Mecanica: 5 * time.Second, Electrica: 7 * time.Second, Carroceria: 11 * time.Second,
+	} {
+		if tiempo := m.TiempoReparacion(tipo, 10); tiempo != esperado {
+			t.Errorf("%s: %v, se esperaba %v", tipo, tiempo, esperado)
+		}
+	}
+
+	ruta := filepath.Join(t.TempDir(), "tiempos.json")
+	os.WriteFile(ruta, []byte(`{
+		"reparacion": {"mecanica": "4s"},
+		"experiencia": [{"desde": 0, "factor": 1.5}, {"desde": 5, "factor": 1}],
+		"distribucion": {"tipo": "exponencial"},
+		"minimo": "2s",
+		"semilla": 7
+	}`), 0644)
+	m, err := CargarModeloTiempos(ruta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otro, _ := CargarModeloTiempos(ruta)
+	for i := 0; i < 10; i++ {
+		if m.TiempoReparacion(Mecanica, 1) != otro.TiempoReparacion(Mecanica, 1) {
+			t.Fatal("con la misma semilla se esperaban los mismos tiempos")
+		}
+	}
+
+	// Sin semilla se elige una, y con ella se repiten los tiempos
+	azar := ModeloTiemposPorDefecto()
+	azar.Distribucion = Distribucion{Tipo: DistribucionExponencial}
+	repetido := ModeloTiemposPorDefecto()
+	repetido.Distribucion = azar.Distribucion
+	if repetido.Semilla = azar.ElegirSemilla(); repetido.Semilla == 0 {
+		t.Fatal("no se eligió ninguna semilla")
+	}
+	for i := 0; i < 10; i++ {
+		if azar.TiempoReparacion(Electrica, 3) != repetido.TiempoReparacion(Electrica, 3) {
+			t.Fatal("con la semilla elegida se esperaban los mismos tiempos")
+		}
+	}
+
+	const n = 5000
+	var novato, veterano time.Duration
+	for i := 0; i < n; i++ {
+		tiempo := m.TiempoReparacion(Mecanica, 1)
+		if tiempo < 2*time.Second {
+			t.Fatalf("%v no respeta el mínimo", tiempo)
+		}
+		novato += tiempo
+		veterano += m.TiempoReparacion(Mecanica, 8)
+	}
+	if novato <= veterano {
+		t.Fatalf("el novato (%v de media) debía tardar más que el veterano (%v)", novato/n, veterano/n)
+	}
+
+	os.WriteFile(ruta, []byte(`{"distribucion": {"tipo": "lognormal", "sigma": -1}}`), 0644)
+	if _, err := CargarModeloTiempos(ruta); err == nil {
+		t.Fatal("se esperaba error con una sigma negativa")
+	}
+}
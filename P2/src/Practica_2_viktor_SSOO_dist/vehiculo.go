@@ -2,12 +2,14 @@ package main
 
 import (
 	"fmt"
+	"sync"
 	"time"
 )
 
 type VehiculoManager struct {
 	vehiculos []Vehiculo
 	nextID    int
+	mutex     sync.RWMutex
 }
 
 func NewVehiculoManager() *VehiculoManager {
@@ -22,6 +24,9 @@ func (vm *VehiculoManager) CrearVehiculo(matricula, marca, modelo string, client
 		return Vehiculo{}, fmt.Errorf("cliente con ID %d no encontrado. No se puede crear el vehículo", clienteID)
 	}
 
+	vm.mutex.Lock()
+	defer vm.mutex.Unlock()
+
 	vehiculo := Vehiculo{
 		ID:              vm.nextID,
 		Matricula:       matricula,
@@ -37,6 +42,9 @@ func (vm *VehiculoManager) CrearVehiculo(matricula, marca, modelo string, client
 }
 
 func (vm *VehiculoManager) ObtenerVehiculo(id int) (Vehiculo, bool) {
+	vm.mutex.RLock()
+	defer vm.mutex.RUnlock()
+
 	for i := 0; i < len(vm.vehiculos); i++ {
 		if vm.vehiculos[i].ID == id {
 			return vm.vehiculos[i], true
@@ -46,6 +54,9 @@ func (vm *VehiculoManager) ObtenerVehiculo(id int) (Vehiculo, bool) {
 }
 
 func (vm *VehiculoManager) ActualizarVehiculo(id int, matricula, marca, modelo string) error {
+	vm.mutex.Lock()
+	defer vm.mutex.Unlock()
+
 	for i := 0; i < len(vm.vehiculos); i++ {
 		if vm.vehiculos[i].ID == id {
 			if matricula != "" {
@@ -64,6 +75,9 @@ func (vm *VehiculoManager) ActualizarVehiculo(id int, matricula, marca, modelo s
 }
 
 func (vm *VehiculoManager) EliminarVehiculo(id int) error {
+	vm.mutex.Lock()
+	defer vm.mutex.Unlock()
+
 	for i := 0; i < len(vm.vehiculos); i++ {
 		if vm.vehiculos[i].ID == id {
 			vm.vehiculos = append(vm.vehiculos[:i], vm.vehiculos[i+1:]...)
@@ -74,10 +88,16 @@ func (vm *VehiculoManager) EliminarVehiculo(id int) error {
 }
 
 func (vm *VehiculoManager) ListarVehiculos() []Vehiculo {
-	return vm.vehiculos
+	vm.mutex.RLock()
+	defer vm.mutex.RUnlock()
+
+	return append([]Vehiculo(nil), vm.vehiculos...)
 }
 
 func (vm *VehiculoManager) ActualizarTiempoAcumulado(vehiculoID int, tiempo float64) error {
+	vm.mutex.Lock()
+	defer vm.mutex.Unlock()
+
 	for i := 0; i < len(vm.vehiculos); i++ {
 		if vm.vehiculos[i].ID == vehiculoID {
 			vm.vehiculos[i].TiempoAcumulado = vm.vehiculos[i].TiempoAcumulado + tiempo
@@ -88,6 +108,9 @@ func (vm *VehiculoManager) ActualizarTiempoAcumulado(vehiculoID int, tiempo floa
 }
 
 func (vm *VehiculoManager) ObtenerTiempoAcumulado(vehiculoID int) (float64, bool) {
+	vm.mutex.RLock()
+	defer vm.mutex.RUnlock()
+
 	for i := 0; i < len(vm.vehiculos); i++ {
 		if vm.vehiculos[i].ID == vehiculoID {
 			return vm.vehiculos[i].TiempoAcumulado, true
@@ -97,6 +120,9 @@ func (vm *VehiculoManager) ObtenerTiempoAcumulado(vehiculoID int) (float64, bool
 }
 
 func (vm *VehiculoManager) ListarVehiculosPorCliente(clienteID int) []Vehiculo {
+	vm.mutex.RLock()
+	defer vm.mutex.RUnlock()
+
 	lista := make([]Vehiculo, 0)
 	for i := 0; i < len(vm.vehiculos); i++ {
 		if vm.vehiculos[i].ClienteID == clienteID {
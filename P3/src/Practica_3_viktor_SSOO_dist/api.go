@@ -0,0 +1,655 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// API REST (JSON SOBRE HTTP)
+// ============================================================================
+
+// ServidorAPI expone los managers y el taller como una API REST en JSON
+type ServidorAPI struct {
+	clientes    *ClienteManager
+	vehiculos   *VehiculoManager
+	incidencias *IncidenciaManager
+	mecanicos   *MecanicoManager
+	taller      *Taller
+}
+
+// NewServidorAPI crea el servidor REST sobre los managers y el taller dados
+func NewServidorAPI(cm *ClienteManager, vm *VehiculoManager, im *IncidenciaManager, mm *MecanicoManager, t *Taller) *ServidorAPI {
+	return &ServidorAPI{
+		clientes:    cm,
+		vehiculos:   vm,
+		incidencias: im,
+		mecanicos:   mm,
+		taller:      t,
+	}
+}
+
+// Handler devuelve el enrutador con todos los endpoints de la API
+func (s *ServidorAPI) Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /clientes", s.listarClientes)
+	mux.HandleFunc("POST /clientes", s.crearCliente)
+	mux.HandleFunc("GET /clientes/{id}", s.obtenerCliente)
+	mux.HandleFunc("PUT /clientes/{id}", s.actualizarCliente)
+	mux.HandleFunc("DELETE /clientes/{id}", s.eliminarCliente)
+
+	mux.HandleFunc("GET /vehiculos", s.listarVehiculos)
+	mux.HandleFunc("POST /vehiculos", s.crearVehiculo)
+	mux.HandleFunc("GET /vehiculos/{id}", s.obtenerVehiculo)
+	mux.HandleFunc("PUT /vehiculos/{id}", s.actualizarVehiculo)
+	mux.HandleFunc("DELETE /vehiculos/{id}", s.eliminarVehiculo)
+
+	mux.HandleFunc("GET /incidencias", s.listarIncidencias)
+	mux.HandleFunc("POST /incidencias", s.crearIncidencia)
+	mux.HandleFunc("GET /incidencias/{id}", s.obtenerIncidencia)
+	mux.HandleFunc("PUT /incidencias/{id}", s.actualizarIncidencia)
+	mux.HandleFunc("DELETE /incidencias/{id}", s.eliminarIncidencia)
+
+	mux.HandleFunc("GET /mecanicos", s.listarMecanicos)
+	mux.HandleFunc("POST /mecanicos", s.crearMecanico)
+	mux.HandleFunc("GET /mecanicos/{id}", s.obtenerMecanico)
+	mux.HandleFunc("PUT /mecanicos/{id}", s.actualizarMecanico)
+
+	mux.HandleFunc("POST /taller/trabajos", s.agregarTrabajo)
+	mux.HandleFunc("GET /taller/estado", s.estadoTaller)
+
+	return mux
+}
+
+// ----------------------------------------------------------------------------
+// Utilidades
+// ----------------------------------------------------------------------------
+
+// errorAPI es el cuerpo de las respuestas de error
+type errorAPI struct {
+	Error string `json:"error"`
+}
+
+func responderJSON(w http.ResponseWriter, estado int, valor interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(estado)
+	json.NewEncoder(w).Encode(valor)
+}
+
+// copia lee el registro con el cerrojo de su manager, para no codificar en
+// JSON uno que otra petición o un mecánico está modificando
+func copia[T any](mutex *sync.RWMutex, registro *T) *T {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	c := *registro
+	return &c
+}
+
+// copias hace lo mismo que copia con una lista entera
+func copias[T any](mutex *sync.RWMutex, registros []*T) []*T {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	lista := make([]*T, len(registros))
+	for i, r := range registros {
+		c := *r
+		lista[i] = &c
+	}
+	return lista
+}
+
+func responderError(w http.ResponseWriter, estado int, formato string, args ...interface{}) {
+	responderJSON(w, estado, errorAPI{Error: fmt.Sprintf(formato, args...)})
+}
+
+// leerJSON decodifica el cuerpo de la petición en destino
+func leerJSON(w http.ResponseWriter, r *http.Request, destino interface{}) bool {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(destino); err != nil {
+		responderError(w, http.StatusBadRequest, "cuerpo JSON no válido: %v", err)
+		return false
+	}
+	return true
+}
+
+// leerID obtiene el {id} de la ruta
+func leerID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		responderError(w, http.StatusBadRequest, "'%s' no es un ID válido", r.PathValue("id"))
+		return 0, false
+	}
+	return id, true
+}
+
+// parsearTipoIncidencia acepta tanto "mecanica" como "Mecánica"
+func parsearTipoIncidencia(s string) (TipoIncidencia, bool) {
+	switch strings.ToLower(s) {
+	case "mecanica", strings.ToLower(string(Mecanica)):
+		return Mecanica, true
+	case "electrica", strings.ToLower(string(Electrica)):
+		return Electrica, true
+	case "carroceria", strings.ToLower(string(Carroceria)):
+		return Carroceria, true
+	}
+	return "", false
+}
+
+func parsearEstadoIncidencia(s string) (EstadoIncidencia, bool) {
+	switch EstadoIncidencia(strings.ToLower(s)) {
+	case Abierta:
+		return Abierta, true
+	case EnProceso:
+		return EnProceso, true
+	case Cerrada:
+		return Cerrada, true
+	}
+	return "", false
+}
+
+func parsearEspecialidad(s string) (Especialidad, bool) {
+	switch Especialidad(strings.ToLower(s)) {
+	case EspMecanica:
+		return EspMecanica, true
+	case EspElectrica:
+		return EspElectrica, true
+	case EspCarroceria:
+		return EspCarroceria, true
+	}
+	return "", false
+}
+
+func prioridadValida(p Prioridad) bool {
+	return p >= PrioridadBaja && p <= PrioridadAlta
+}
+
+// ----------------------------------------------------------------------------
+// Clientes
+// ----------------------------------------------------------------------------
+
+type peticionCliente struct {
+	Nombre    string `json:"nombre"`
+	Telefono  string `json:"telefono"`
+	Email     string `json:"email"`
+	Notificar string `json:"notificar"`
+}
+
+func (s *ServidorAPI) listarClientes(w http.ResponseWriter, r *http.Request) {
+	clientes := copias(&s.clientes.mutex, s.clientes.ListarClientes())
+	sort.Slice(clientes, func(i, j int) bool { return clientes[i].ID < clientes[j].ID })
+	responderJSON(w, http.StatusOK, clientes)
+}
+
+func (s *ServidorAPI) crearCliente(w http.ResponseWriter, r *http.Request) {
+	var p peticionCliente
+	if !leerJSON(w, r, &p) {
+		return
+	}
+	if p.Nombre == "" {
+		responderError(w, http.StatusBadRequest, "el nombre es obligatorio")
+		return
+	}
+	canal, valido := parsearCanalNotificacion(p.Notificar)
+	if !valido {
+		responderError(w, http.StatusBadRequest, "canal de notificación '%s' no válido", p.Notificar)
+		return
+	}
+	cliente := s.clientes.CrearCliente(p.Nombre, p.Telefono, p.Email)
+	if canal != "" {
+		if err := s.clientes.CambiarPreferencia(cliente.ID, canal); err != nil {
+			responderError(w, http.StatusInternalServerError, "%v", err)
+			return
+		}
+	}
+	responderJSON(w, http.StatusCreated, copia(&s.clientes.mutex, cliente))
+}
+
+func (s *ServidorAPI) obtenerCliente(w http.ResponseWriter, r *http.Request) {
+	id, ok := leerID(w, r)
+	if !ok {
+		return
+	}
+	cliente, existe := s.clientes.ObtenerCliente(id)
+	if !existe {
+		responderError(w, http.StatusNotFound, "cliente con ID %d no encontrado", id)
+		return
+	}
+	responderJSON(w, http.StatusOK, copia(&s.clientes.mutex, cliente))
+}
+
+func (s *ServidorAPI) actualizarCliente(w http.ResponseWriter, r *http.Request) {
+	id, ok := leerID(w, r)
+	if !ok {
+		return
+	}
+	var p peticionCliente
+	if !leerJSON(w, r, &p) {
+		return
+	}
+	canal, valido := parsearCanalNotificacion(p.Notificar)
+	if !valido {
+		responderError(w, http.StatusBadRequest, "canal de notificación '%s' no válido", p.Notificar)
+		return
+	}
+	if _, existe := s.clientes.ObtenerCliente(id); !existe {
+		responderError(w, http.StatusNotFound, "cliente con ID %d no encontrado", id)
+		return
+	}
+	if err := s.clientes.ActualizarCliente(id, p.Nombre, p.Telefono, p.Email); err != nil {
+		responderError(w, http.StatusInternalServerError, "%v", err)
+		return
+	}
+	if canal != "" {
+		if err := s.clientes.CambiarPreferencia(id, canal); err != nil {
+			responderError(w, http.StatusInternalServerError, "%v", err)
+			return
+		}
+	}
+	cliente, _ := s.clientes.ObtenerCliente(id)
+	responderJSON(w, http.StatusOK, copia(&s.clientes.mutex, cliente))
+}
+
+func (s *ServidorAPI) eliminarCliente(w http.ResponseWriter, r *http.Request) {
+	id, ok := leerID(w, r)
+	if !ok {
+		return
+	}
+	if _, existe := s.clientes.ObtenerCliente(id); !existe {
+		responderError(w, http.StatusNotFound, "cliente con ID %d no encontrado", id)
+		return
+	}
+	if err := s.clientes.EliminarCliente(id); err != nil {
+		responderError(w, http.StatusInternalServerError, "%v", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ----------------------------------------------------------------------------
+// Vehículos
+// ----------------------------------------------------------------------------
+
+type peticionVehiculo struct {
+	Matricula string `json:"matricula"`
+	Marca     string `json:"marca"`
+	Modelo    string `json:"modelo"`
+	ClienteID int    `json:"cliente_id"`
+}
+
+func (s *ServidorAPI) listarVehiculos(w http.ResponseWriter, r *http.Request) {
+	var vehiculos []*VehiculoCompleto
+	if clienteID := r.URL.Query().Get("cliente_id"); clienteID != "" {
+		id, err := strconv.Atoi(clienteID)
+		if err != nil {
+			responderError(w, http.StatusBadRequest, "'%s' no es un ID de cliente válido", clienteID)
+			return
+		}
+		vehiculos = copias(&s.vehiculos.mutex, s.vehiculos.ListarVehiculosPorCliente(id))
+	} else {
+		vehiculos = copias(&s.vehiculos.mutex, s.vehiculos.ListarVehiculos())
+	}
+	sort.Slice(vehiculos, func(i, j int) bool { return vehiculos[i].ID < vehiculos[j].ID })
+	responderJSON(w, http.StatusOK, vehiculos)
+}
+
+func (s *ServidorAPI) crearVehiculo(w http.ResponseWriter, r *http.Request) {
+	var p peticionVehiculo
+	if !leerJSON(w, r, &p) {
+		return
+	}
+	if p.Matricula == "" {
+		responderError(w, http.StatusBadRequest, "la matrícula es obligatoria")
+		return
+	}
+	if !s.clientes.ExisteCliente(p.ClienteID) {
+		responderError(w, http.StatusUnprocessableEntity, "el cliente con ID %d no existe", p.ClienteID)
+		return
+	}
+	vehiculo, err := s.vehiculos.CrearVehiculo(p.Matricula, p.Marca, p.Modelo, p.ClienteID, s.clientes)
+	if err != nil {
+		responderError(w, http.StatusInternalServerError, "%v", err)
+		return
+	}
+	responderJSON(w, http.StatusCreated, copia(&s.vehiculos.mutex, vehiculo))
+}
+
+func (s *ServidorAPI) obtenerVehiculo(w http.ResponseWriter, r *http.Request) {
+	id, ok := leerID(w, r)
+	if !ok {
+		return
+	}
+	vehiculo, existe := s.vehiculos.ObtenerVehiculo(id)
+	if !existe {
+		responderError(w, http.StatusNotFound, "vehículo con ID %d no encontrado", id)
+		return
+	}
+	responderJSON(w, http.StatusOK, copia(&s.vehiculos.mutex, vehiculo))
+}
+
+func (s *ServidorAPI) actualizarVehiculo(w http.ResponseWriter, r *http.Request) {
+	id, ok := leerID(w, r)
+	if !ok {
+		return
+	}
+	var p peticionVehiculo
+	if !leerJSON(w, r, &p) {
+		return
+	}
+	if _, existe := s.vehiculos.ObtenerVehiculo(id); !existe {
+		responderError(w, http.StatusNotFound, "vehículo con ID %d no encontrado", id)
+		return
+	}
+	if err := s.vehiculos.ActualizarVehiculo(id, p.Matricula, p.Marca, p.Modelo); err != nil {
+		responderError(w, http.StatusInternalServerError, "%v", err)
+		return
+	}
+	vehiculo, _ := s.vehiculos.ObtenerVehiculo(id)
+	responderJSON(w, http.StatusOK, copia(&s.vehiculos.mutex, vehiculo))
+}
+
+// eliminarVehiculo borra el vehículo junto con sus incidencias, igual que el menú
+func (s *ServidorAPI) eliminarVehiculo(w http.ResponseWriter, r *http.Request) {
+	id, ok := leerID(w, r)
+	if !ok {
+		return
+	}
+	if _, existe := s.vehiculos.ObtenerVehiculo(id); !existe {
+		responderError(w, http.StatusNotFound, "vehículo con ID %d no encontrado", id)
+		return
+	}
+	s.incidencias.EliminarIncidenciasPorVehiculo(id)
+	if err := s.vehiculos.EliminarVehiculo(id); err != nil {
+		responderError(w, http.StatusInternalServerError, "%v", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ----------------------------------------------------------------------------
+// Incidencias
+// ----------------------------------------------------------------------------
+
+type peticionIncidencia struct {
+	VehiculoID  int       `json:"vehiculo_id"`
+	Tipo        string    `json:"tipo"`
+	Prioridad   Prioridad `json:"prioridad"`
+	Estado      string    `json:"estado"`
+	Descripcion string    `json:"descripcion"`
+}
+
+func (s *ServidorAPI) listarIncidencias(w http.ResponseWriter, r *http.Request) {
+	incidencias := copias(&s.incidencias.mutex, s.incidencias.ListarIncidencias())
+	sort.Slice(incidencias, func(i, j int) bool { return incidencias[i].ID < incidencias[j].ID })
+	responderJSON(w, http.StatusOK, incidencias)
+}
+
+func (s *ServidorAPI) crearIncidencia(w http.ResponseWriter, r *http.Request) {
+	var p peticionIncidencia
+	if !leerJSON(w, r, &p) {
+		return
+	}
+	tipo, ok := parsearTipoIncidencia(p.Tipo)
+	if !ok {
+		responderError(w, http.StatusBadRequest, "tipo '%s' no válido (mecanica/electrica/carroceria)", p.Tipo)
+		return
+	}
+	if p.Prioridad == 0 {
+		p.Prioridad = PrioridadMedia
+	}
+	if !prioridadValida(p.Prioridad) {
+		responderError(w, http.StatusBadRequest, "prioridad %d no válida (1=baja, 2=media, 3=alta)", p.Prioridad)
+		return
+	}
+	if _, existe := s.vehiculos.ObtenerVehiculo(p.VehiculoID); !existe {
+		responderError(w, http.StatusUnprocessableEntity, "el vehículo con ID %d no existe", p.VehiculoID)
+		return
+	}
+	incidencia := s.incidencias.CrearIncidencia(tipo, p.Prioridad, p.Descripcion, p.VehiculoID)
+	responderJSON(w, http.StatusCreated, copia(&s.incidencias.mutex, incidencia))
+}
+
+func (s *ServidorAPI) obtenerIncidencia(w http.ResponseWriter, r *http.Request) {
+	id, ok := leerID(w, r)
+	if !ok {
+		return
+	}
+	incidencia, existe := s.incidencias.ObtenerIncidencia(id)
+	if !existe {
+		responderError(w, http.StatusNotFound, "incidencia con ID %d no encontrada", id)
+		return
+	}
+	responderJSON(w, http.StatusOK, copia(&s.incidencias.mutex, incidencia))
+}
+
+// actualizarIncidencia cambia los campos indicados; los vacíos no se tocan
+func (s *ServidorAPI) actualizarIncidencia(w http.ResponseWriter, r *http.Request) {
+	id, ok := leerID(w, r)
+	if !ok {
+		return
+	}
+	var p peticionIncidencia
+	if !leerJSON(w, r, &p) {
+		return
+	}
+	if _, existe := s.incidencias.ObtenerIncidencia(id); !existe {
+		responderError(w, http.StatusNotFound, "incidencia con ID %d no encontrada", id)
+		return
+	}
+
+	var tipo TipoIncidencia
+	if p.Tipo != "" {
+		if tipo, ok = parsearTipoIncidencia(p.Tipo); !ok {
+			responderError(w, http.StatusBadRequest, "tipo '%s' no válido (mecanica/electrica/carroceria)", p.Tipo)
+			return
+		}
+	}
+	if p.Prioridad != 0 && !prioridadValida(p.Prioridad) {
+		responderError(w, http.StatusBadRequest, "prioridad %d no válida (1=baja, 2=media, 3=alta)", p.Prioridad)
+		return
+	}
+	var estado EstadoIncidencia
+	if p.Estado != "" {
+		if estado, ok = parsearEstadoIncidencia(p.Estado); !ok {
+			responderError(w, http.StatusBadRequest, "estado '%s' no válido (abierta/en proceso/cerrada)", p.Estado)
+			return
+		}
+	}
+
+	if err := s.incidencias.ActualizarIncidencia(id, tipo, p.Prioridad, p.Descripcion); err != nil {
+		responderError(w, http.StatusInternalServerError, "%v", err)
+		return
+	}
+	if estado != "" {
+		if err := s.incidencias.CambiarEstado(id, estado); err != nil {
+			responderError(w, http.StatusInternalServerError, "%v", err)
+			return
+		}
+	}
+	incidencia, _ := s.incidencias.ObtenerIncidencia(id)
+	responderJSON(w, http.StatusOK, copia(&s.incidencias.mutex, incidencia))
+}
+
+func (s *ServidorAPI) eliminarIncidencia(w http.ResponseWriter, r *http.Request) {
+	id, ok := leerID(w, r)
+	if !ok {
+		return
+	}
+	if _, existe := s.incidencias.ObtenerIncidencia(id); !existe {
+		responderError(w, http.StatusNotFound, "incidencia con ID %d no encontrada", id)
+		return
+	}
+	if err := s.incidencias.EliminarIncidencia(id); err != nil {
+		responderError(w, http.StatusInternalServerError, "%v", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ----------------------------------------------------------------------------
+// Mecánicos
+// ----------------------------------------------------------------------------
+
+type peticionMecanico struct {
+	Nombre       string `json:"nombre"`
+	Especialidad string `json:"especialidad"`
+	Experiencia  int    `json:"experiencia"`
+	Activo       *bool  `json:"activo"`
+}
+
+func (s *ServidorAPI) listarMecanicos(w http.ResponseWriter, r *http.Request) {
+	mecanicos := s.mecanicos.ListarMecanicos()
+	sort.Slice(mecanicos, func(i, j int) bool { return mecanicos[i].ID < mecanicos[j].ID })
+
+	ahora := time.Now()
+	lista := make([]EstadoMecanico, 0, len(mecanicos))
+	for _, m := range mecanicos {
+		lista = append(lista, nuevoEstadoMecanico(m, ahora))
+	}
+	responderJSON(w, http.StatusOK, lista)
+}
+
+// crearMecanico da de alta al mecánico y arranca su rutina de trabajo
+func (s *ServidorAPI) crearMecanico(w http.ResponseWriter, r *http.Request) {
+	var p peticionMecanico
+	if !leerJSON(w, r, &p) {
+		return
+	}
+	if p.Nombre == "" {
+		responderError(w, http.StatusBadRequest, "el nombre es obligatorio")
+		return
+	}
+	especialidad, ok := parsearEspecialidad(p.Especialidad)
+	if !ok {
+		responderError(w, http.StatusBadRequest, "especialidad '%s' no válida (mecanica/electrica/carroceria)", p.Especialidad)
+		return
+	}
+
+	mecanico := s.mecanicos.CrearMecanico(p.Nombre, especialidad, p.Experiencia)
+	go s.taller.ArrancarRutinaMecanico(mecanico)
+	responderJSON(w, http.StatusCreated, nuevoEstadoMecanico(mecanico, time.Now()))
+}
+
+func (s *ServidorAPI) obtenerMecanico(w http.ResponseWriter, r *http.Request) {
+	id, ok := leerID(w, r)
+	if !ok {
+		return
+	}
+	mecanico, existe := s.mecanicos.ObtenerMecanico(id)
+	if !existe {
+		responderError(w, http.StatusNotFound, "mecánico con ID %d no encontrado", id)
+		return
+	}
+	responderJSON(w, http.StatusOK, nuevoEstadoMecanico(mecanico, time.Now()))
+}
+
+// actualizarMecanico da de alta o de baja al mecánico. Igual que en el menú,
+// no se puede dar de baja a un mecánico con coches en su cola.
+func (s *ServidorAPI) actualizarMecanico(w http.ResponseWriter, r *http.Request) {
+	id, ok := leerID(w, r)
+	if !ok {
+		return
+	}
+	var p peticionMecanico
+	if !leerJSON(w, r, &p) {
+		return
+	}
+	mecanico, existe := s.mecanicos.ObtenerMecanico(id)
+	if !existe {
+		responderError(w, http.StatusNotFound, "mecánico con ID %d no encontrado", id)
+		return
+	}
+	if p.Nombre != "" || p.Especialidad != "" || p.Experiencia != 0 {
+		responderError(w, http.StatusBadRequest, "solo se puede cambiar el campo 'activo' de un mecánico")
+		return
+	}
+	if p.Activo == nil {
+		responderError(w, http.StatusBadRequest, "falta el campo 'activo'")
+		return
+	}
+
+	if !*p.Activo {
+		mecanico.mutex.Lock()
+		enCola := len(mecanico.ColaPersonal)
+		mecanico.mutex.Unlock()
+		if enCola > 0 {
+			responderError(w, http.StatusConflict, "no se puede dar de baja a %s porque tiene %d coches en cola/proceso",
+				mecanico.Nombre, enCola)
+			return
+		}
+	}
+	if err := s.mecanicos.CambiarEstadoActivo(id, *p.Activo); err != nil {
+		responderError(w, http.StatusInternalServerError, "%v", err)
+		return
+	}
+	responderJSON(w, http.StatusOK, nuevoEstadoMecanico(mecanico, time.Now()))
+}
+
+// ----------------------------------------------------------------------------
+// Taller
+// ----------------------------------------------------------------------------
+
+// peticionTrabajo envía al taller las incidencias abiertas de un vehículo, o
+// solo la indicada en incidencia_id. Las que ya están en el taller se saltan;
+// si no queda ninguna se responde 409.
+type peticionTrabajo struct {
+	VehiculoID   int `json:"vehiculo_id"`
+	IncidenciaID int `json:"incidencia_id"`
+}
+
+func (s *ServidorAPI) agregarTrabajo(w http.ResponseWriter, r *http.Request) {
+	var p peticionTrabajo
+	if !leerJSON(w, r, &p) {
+		return
+	}
+	vehiculo, existe := s.vehiculos.ObtenerVehiculo(p.VehiculoID)
+	if !existe {
+		responderError(w, http.StatusNotFound, "vehículo con ID %d no encontrado", p.VehiculoID)
+		return
+	}
+	vehiculo = copia(&s.vehiculos.mutex, vehiculo)
+
+	abiertas := copias(&s.incidencias.mutex, s.incidencias.ObtenerIncidenciasPorVehiculo(p.VehiculoID))
+	incidencias := make([]*IncidenciaCompleta, 0, len(abiertas))
+	for _, inc := range abiertas {
+		if p.IncidenciaID == 0 || inc.ID == p.IncidenciaID {
+			incidencias = append(incidencias, inc)
+		}
+	}
+	if len(incidencias) == 0 {
+		if p.IncidenciaID != 0 {
+			responderError(w, http.StatusUnprocessableEntity, "el vehículo %d no tiene abierta la incidencia %d", p.VehiculoID, p.IncidenciaID)
+		} else {
+			responderError(w, http.StatusUnprocessableEntity, "el vehículo %d no tiene incidencias abiertas", p.VehiculoID)
+		}
+		return
+	}
+
+	sort.Slice(incidencias, func(i, j int) bool { return incidencias[i].ID < incidencias[j].ID })
+	encolados := make([]TrabajoEnCola, 0, len(incidencias))
+	for _, inc := range incidencias {
+		if err := s.taller.AgregarTrabajo(vehiculo, inc); err != nil {
+			continue
+		}
+		encolados = append(encolados, nuevoTrabajoEnCola(&TrabajoMecanico{Vehiculo: *vehiculo, Incidencia: *inc}))
+	}
+	if len(encolados) == 0 {
+		if p.IncidenciaID != 0 {
+			responderError(w, http.StatusConflict, "la incidencia %d ya está en el taller", p.IncidenciaID)
+		} else {
+			responderError(w, http.StatusConflict, "las incidencias abiertas del vehículo %d ya están en el taller", p.VehiculoID)
+		}
+		return
+	}
+	responderJSON(w, http.StatusAccepted, encolados)
+}
+
+func (s *ServidorAPI) estadoTaller(w http.ResponseWriter, r *http.Request) {
+	responderJSON(w, http.StatusOK, s.taller.Estado())
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// peticion lanza una petición contra la API y decodifica la respuesta en destino
+func peticion(t *testing.T, servidor *httptest.Server, metodo, ruta, cuerpo string, destino interface{}) int {
+	t.Helper()
+
+	req, err := http.NewRequest(metodo, servidor.URL+ruta, bytes.NewBufferString(cuerpo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := servidor.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if destino != nil && resp.StatusCode < 300 {
+		if err := json.NewDecoder(resp.Body).Decode(destino); err != nil {
+			t.Fatalf("%s %s: respuesta no válida: %v", metodo, ruta, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func nuevoServidorPrueba() *httptest.Server {
+	cm := NewClienteManager()
+	vm := NewVehiculoManager()
+	im := NewIncidenciaManager()
+	mm := NewMecanicoManager()
+	taller := NewTaller(mm, vm, im)
+	return httptest.NewServer(NewServidorAPI(cm, vm, im, mm, taller).Handler())
+}
+
+// TestAPI_FlujoCompleto recorre el alta de cliente, vehículo e incidencia y
+// el envío del vehículo al taller
+func TestAPI_FlujoCompleto(t *testing.T) {
+	servidor := nuevoServidorPrueba()
+	defer servidor.Close()
+
+	var cliente Cliente
+	if c := peticion(t, servidor, "POST", "/clientes", `{"nombre":"Ana","telefono":"600000001","email":"ana@test.com"}`, &cliente); c != http.StatusCreated {
+		t.Fatalf("crear cliente: estado %d", c)
+	}
+
+	var vehiculo VehiculoCompleto
+	cuerpo := `{"matricula":"1234ABC","marca":"Seat","modelo":"Ibiza","cliente_id":1}`
+	if c := peticion(t, servidor, "POST", "/vehiculos", cuerpo, &vehiculo); c != http.StatusCreated {
+		t.Fatalf("crear vehículo: estado %d", c)
+	}
+	if vehiculo.ClienteID != cliente.ID {
+		t.Fatalf("el vehículo no pertenece al cliente: %+v", vehiculo)
+	}
+
+	var incidencia IncidenciaCompleta
+	cuerpo = `{"vehiculo_id":1,"tipo":"electrica","prioridad":3,"descripcion":"No arranca"}`
+	if c := peticion(t, servidor, "POST", "/incidencias", cuerpo, &incidencia); c != http.StatusCreated {
+		t.Fatalf("crear incidencia: estado %d", c)
+	}
+	if incidencia.Tipo != Electrica || incidencia.Estado != Abierta {
+		t.Fatalf("incidencia incorrecta: %+v", incidencia)
+	}
+
+	// Solo hay un mecánico de carrocería, así que el trabajo se queda en cola
+	var mecanico EstadoMecanico
+	if c := peticion(t, servidor, "POST", "/mecanicos", `{"nombre":"Luis","especialidad":"carroceria","experiencia":4}`, &mecanico); c != http.StatusCreated {
+		t.Fatalf("crear mecánico: estado %d", c)
+	}
+
+	var encolados []TrabajoEnCola
+	if c := peticion(t, servidor, "POST", "/taller/trabajos", `{"vehiculo_id":1}`, &encolados); c != http.StatusAccepted {
+		t.Fatalf("enviar al taller: estado %d", c)
+	}
+	if len(encolados) != 1 || encolados[0].IncidenciaID != incidencia.ID {
+		t.Fatalf("trabajos encolados incorrectos: %+v", encolados)
+	}
+	for _, cuerpo := range []string{`{"vehiculo_id":1}`, `{"vehiculo_id":1,"incidencia_id":1}`} {
+		if c := peticion(t, servidor, "POST", "/taller/trabajos", cuerpo, nil); c != http.StatusConflict {
+			t.Fatalf("reenviar %s al taller: estado %d", cuerpo, c)
+		}
+	}
+
+	var estado EstadoTaller
+	if c := peticion(t, servidor, "GET", "/taller/estado", "", &estado); c != http.StatusOK {
+		t.Fatalf("estado del taller: estado %d", c)
+	}
+	if len(estado.Cola) != 1 || len(estado.Mecanicos) != 1 {
+		t.Fatalf("estado del taller incorrecto: %+v", estado)
+	}
+
+	var actualizada IncidenciaCompleta
+	if c := peticion(t, servidor, "PUT", "/incidencias/1", `{"estado":"cerrada"}`, &actualizada); c != http.StatusOK {
+		t.Fatalf("actualizar incidencia: estado %d", c)
+	}
+	if actualizada.Estado != Cerrada || actualizada.Descripcion != "No arranca" {
+		t.Fatalf("incidencia mal actualizada: %+v", actualizada)
+	}
+
+	if c := peticion(t, servidor, "DELETE", "/vehiculos/1", "", nil); c != http.StatusNoContent {
+		t.Fatalf("eliminar vehículo: estado %d", c)
+	}
+	if c := peticion(t, servidor, "GET", "/incidencias/1", "", nil); c != http.StatusNotFound {
+		t.Fatalf("la incidencia debía borrarse con el vehículo, estado %d", c)
+	}
+}
+
+// TestAPI_Errores comprueba los códigos de error más habituales
+func TestAPI_Errores(t *testing.T) {
+	servidor := nuevoServidorPrueba()
+	defer servidor.Close()
+
+	casos := []struct {
+		metodo, ruta, cuerpo string
+		esperado             int
+	}{
+		{"GET", "/clientes/7", "", http.StatusNotFound},
+		{"GET", "/clientes/abc", "", http.StatusBadRequest},
+		{"POST", "/clientes", `{"nombre":`, http.StatusBadRequest},
+		{"POST", "/clientes", `{"nombre":"Ana","edad":30}`, http.StatusBadRequest},
+		{"POST", "/vehiculos", `{"matricula":"1234ABC","cliente_id":9}`, http.StatusUnprocessableEntity},
+		{"POST", "/incidencias", `{"vehiculo_id":1,"tipo":"pintura"}`, http.StatusBadRequest},
+		{"POST", "/mecanicos", `{"nombre":"Luis","especialidad":"fontaneria"}`, http.StatusBadRequest},
+		{"PUT", "/mecanicos/1", `{"activo":false}`, http.StatusNotFound},
+		{"POST", "/taller/trabajos", `{"vehiculo_id":3}`, http.StatusNotFound},
+		{"PATCH", "/clientes/1", `{}`, http.StatusMethodNotAllowed},
+	}
+	for _, caso := range casos {
+		if c := peticion(t, servidor, caso.metodo, caso.ruta, caso.cuerpo, nil); c != caso.esperado {
+			t.Errorf("%s %s: esperado %d, obtenido %d", caso.metodo, caso.ruta, caso.esperado, c)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrColaCerrada lo devuelven Meter y Sacar cuando la cola ya está cerrada
+var ErrColaCerrada = errors.New("cola cerrada")
+
+// ColaBloqueante es una ColaPrioridad que pueden compartir varias
+// goroutines: Sacar duerme en una variable de condición hasta que alguien
+// mete un coche, se cierra la cola o se cancela su contexto, así que una
+// fase sin trabajo no gasta CPU.
+type ColaBloqueante struct {
+	mutex   sync.RWMutex
+	hay     *sync.Cond
+	cola    ColaPrioridad
+	cerrada bool
+}
+
+// NewColaBloqueante crea una cola vacía y abierta
+func NewColaBloqueante() *ColaBloqueante {
+	c := &ColaBloqueante{}
+	c.hay = sync.NewCond(&c.mutex)
+	heap.Init(&c.cola)
+	return c
+}
+
+// Meter añade v a la cola y despierta a uno de los que esperan en Sacar
+func (c *ColaBloqueante) Meter(v *Vehiculo) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.cerrada {
+		return ErrColaCerrada
+	}
+	heap.Push(&c.cola, v)
+	c.hay.Signal()
+	return nil
+}
+
+// Sacar espera a que haya alguien en la cola y saca al de más prioridad. Una
+// cola cerrada se sigue vaciando, y cuando ya no queda nadie devuelve
+// ErrColaCerrada. Si se cancela ctx devuelve su error.
+func (c *ColaBloqueante) Sacar(ctx context.Context) (*Vehiculo, error) {
+	// Al cancelar hay que despertar a los que esperan para que lo vean
+	parar := context.AfterFunc(ctx, func() {
+		c.mutex.Lock()
+		c.hay.Broadcast()
+		c.mutex.Unlock()
+	})
+	defer parar()
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for c.cola.Len() == 0 && !c.cerrada && ctx.Err() == nil {
+		c.hay.Wait()
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if c.cola.Len() == 0 {
+		return nil, ErrColaCerrada
+	}
+	return heap.Pop(&c.cola).(*Vehiculo), nil
+}
+
+// Cerrar no deja meter a nadie más y despierta a todos los que esperan
+func (c *ColaBloqueante) Cerrar() {
+	c.mutex.Lock()
+	c.cerrada = true
+	c.mutex.Unlock()
+	c.hay.Broadcast()
+}
+
+// Len dice cuántos coches esperan
+func (c *ColaBloqueante) Len() int {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.cola.Len()
+}
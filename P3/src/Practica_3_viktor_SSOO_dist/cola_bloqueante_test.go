@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestColaBloqueantePrioridad(t *testing.T) {
+	cola := NewColaBloqueante()
+	vehiculos := generarVehiculos(1, 1, 1)
+	for i := len(vehiculos) - 1; i >= 0; i-- {
+		cola.Meter(vehiculos[i])
+	}
+	// Primero mecánica, luego eléctrica y por último carrocería
+	for _, id := range []int{1, 2, 3} {
+		v, err := cola.Sacar(context.Background())
+		if err != nil || v.ID != id {
+			t.Fatalf("se esperaba el coche %d y salió %v (%v)", id, v, err)
+		}
+	}
+}
+
+// TestColaBloqueanteEspera comprueba que Sacar duerme hasta que alguien mete
+// un coche
+func TestColaBloqueanteEspera(t *testing.T) {
+	cola := NewColaBloqueante()
+	sacado := make(chan *Vehiculo)
+	go func() {
+		v, _ := cola.Sacar(context.Background())
+		sacado <- v
+	}()
+
+	select {
+	case <-sacado:
+		t.Fatal("Sacar volvió con la cola vacía")
+	case <-time.After(20 * time.Millisecond):
+	}
+	v := NewVehiculo(7, Mecanica)
+	cola.Meter(v)
+	if <-sacado != v {
+		t.Fatal("no salió el coche que se metió")
+	}
+}
+
+// TestColaBloqueanteCerrada comprueba que una cola cerrada se vacía y después
+// despierta a todos con ErrColaCerrada
+func TestColaBloqueanteCerrada(t *testing.T) {
+	cola := NewColaBloqueante()
+	cola.Meter(NewVehiculo(1, Mecanica))
+	cola.Cerrar()
+	if err := cola.Meter(NewVehiculo(2, Mecanica)); err != ErrColaCerrada {
+		t.Fatalf("se pudo meter en una cola cerrada: %v", err)
+	}
+	if v, err := cola.Sacar(context.Background()); err != nil || v.ID != 1 {
+		t.Fatalf("no se vació la cola cerrada: %v %v", v, err)
+	}
+
+	otra := NewColaBloqueante()
+	errores := make(chan error)
+	for i := 0; i < 3; i++ {
+		go func() {
+			_, err := otra.Sacar(context.Background())
+			errores <- err
+		}()
+	}
+	otra.Cerrar()
+	for i := 0; i < 3; i++ {
+		if err := <-errores; err != ErrColaCerrada {
+			t.Fatalf("se esperaba ErrColaCerrada y se obtuvo %v", err)
+		}
+	}
+	if _, err := cola.Sacar(context.Background()); err != ErrColaCerrada {
+		t.Fatalf("se esperaba ErrColaCerrada y se obtuvo %v", err)
+	}
+}
+
+func TestColaBloqueanteCancelada(t *testing.T) {
+	cola := NewColaBloqueante()
+	ctx, cancelar := context.WithCancel(context.Background())
+	errores := make(chan error)
+	go func() {
+		_, err := cola.Sacar(ctx)
+		errores <- err
+	}()
+	cancelar()
+	if err := <-errores; err != context.Canceled {
+		t.Fatalf("se esperaba context.Canceled y se obtuvo %v", err)
+	}
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ============================================================================
+// COMANDOS
+// ============================================================================
+
+// comandoSimular corre un escenario y escribe sus métricas:
+//
+//	taller simular -escenario escenarios/caso1.yaml -json informe.json -csv coches.csv
+//
+// Con -semilla se repite exactamente una ejecución anterior, aunque el
+// escenario no fije la suya.
+//
+// Devuelve el código de salida del programa.
+func comandoSimular(args []string) int {
+	opciones := flag.NewFlagSet("simular", flag.ContinueOnError)
+	archivoEscenario := opciones.String("escenario", "", "archivo YAML o JSON con el escenario")
+	archivoJSON := opciones.String("json", "", "archivo donde guardar el resultado completo en JSON")
+	archivoCSV := opciones.String("csv", "", "archivo donde guardar una fila por coche en CSV")
+	archivoCategorias := opciones.String("categorias", "", "archivo donde guardar una fila por categoría en CSV")
+	registro := opciones.Bool("registro", false, "escribir el estado de cada coche en cada fase")
+	semilla := opciones.Int64("semilla", 0, "semilla de las llegadas y los tiempos (0 para la del escenario)")
+	opciones.Int64Var(semilla, "seed", 0, "igual que -semilla")
+	if err := opciones.Parse(args); err != nil {
+		return 2
+	}
+	if *archivoEscenario == "" {
+		fmt.Fprintln(os.Stderr, "Falta el escenario: simular -escenario archivo.yaml")
+		opciones.Usage()
+		return 2
+	}
+
+	escenario, err := CargarEscenario(*archivoEscenario)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error al cargar el escenario: %v\n", err)
+		return 1
+	}
+	if *semilla != 0 {
+		escenario.FijarSemilla(*semilla)
+	}
+	var salida io.Writer
+	if *registro {
+		salida = os.Stdout
+	}
+
+	fmt.Printf("Escenario %s (motor %s, %d plazas, %s, semilla %d)\n",
+		escenario.Nombre, escenario.Motor, escenario.Plazas, escenario.DescribirMecanicos(), escenario.Semilla)
+	resultado, err := escenario.Ejecutar(salida)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error en la simulación: %v\n", err)
+		return 1
+	}
+	resultado.Imprimir(os.Stdout)
+
+	exportar := []struct {
+		ruta     string
+		escribir func(io.Writer) error
+	}{
+		{*archivoJSON, resultado.EscribirJSON},
+		{*archivoCSV, resultado.EscribirCSV},
+		{*archivoCategorias, resultado.EscribirCategoriasCSV},
+	}
+	for _, e := range exportar {
+		if e.ruta == "" {
+			continue
+		}
+		if err := escribirArchivo(e.ruta, e.escribir); err != nil {
+			fmt.Fprintf(os.Stderr, "Error al guardar %s: %v\n", e.ruta, err)
+			return 1
+		}
+		fmt.Printf("Guardado %s\n", e.ruta)
+	}
+	return 0
+}
+
+// comandoComparar corre todos los motores sobre una rejilla de casos y
+// escribe una tabla con lo que rinde y lo que cuesta cada uno:
+//
+//	taller comparar -coches 20,100 -recursos 5x3,10x6 -mezclas equilibrada,mecanica -csv comparacion.csv
+//
+// Devuelve el código de salida del programa.
+func comandoComparar(args []string) int {
+	opciones := flag.NewFlagSet("comparar", flag.ContinueOnError)
+	listaCoches := opciones.String("coches", "20,100", "números de coches, separados por comas")
+	listaRecursos := opciones.String("recursos", "5x3,10x6", "plazas x mecánicos, separados por comas")
+	listaMezclas := opciones.String("mezclas", "equilibrada", "mezclas de categorías (equilibrada, mecanica, carroceria), separadas por comas")
+	listaMotores := opciones.String("motores", "", "motores a comparar, separados por comas (vacío para todos)")
+	repeticiones := opciones.Int("repeticiones", 3, "ejecuciones de cada motor en cada caso")
+	archivoCSV := opciones.String("csv", "", "archivo donde guardar la comparación en CSV")
+	if err := opciones.Parse(args); err != nil {
+		return 2
+	}
+
+	casos, motores, err := rejillaDesdeOpciones(*listaCoches, *listaRecursos, *listaMezclas, *listaMotores)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error en las opciones: %v\n", err)
+		return 2
+	}
+	filas, err := CompararMotores(casos, motores, *repeticiones)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error al comparar: %v\n", err)
+		return 1
+	}
+	ImprimirComparacion(os.Stdout, filas)
+
+	if *archivoCSV != "" {
+		err := escribirArchivo(*archivoCSV, func(w io.Writer) error { return EscribirComparacionCSV(w, filas) })
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error al guardar %s: %v\n", *archivoCSV, err)
+			return 1
+		}
+		fmt.Printf("Guardado %s\n", *archivoCSV)
+	}
+	return 0
+}
+
+func rejillaDesdeOpciones(listaCoches, listaRecursos, listaMezclas, listaMotores string) ([]CasoComparacion, []MotorSimulacion, error) {
+	var coches []int
+	for _, s := range strings.Split(listaCoches, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil || n <= 0 {
+			return nil, nil, fmt.Errorf("número de coches '%s' no válido", s)
+		}
+		coches = append(coches, n)
+	}
+
+	var recursos [][2]int
+	for _, s := range strings.Split(listaRecursos, ",") {
+		var plazas, mecanicos int
+		if _, err := fmt.Sscanf(strings.TrimSpace(s), "%dx%d", &plazas, &mecanicos); err != nil {
+			return nil, nil, fmt.Errorf("recursos '%s' no válidos, se esperaba plazasxmecanicos", s)
+		}
+		recursos = append(recursos, [2]int{plazas, mecanicos})
+	}
+
+	var mezclas []MezclaCategorias
+	for _, s := range strings.Split(listaMezclas, ",") {
+		mezcla, err := BuscarMezcla(strings.TrimSpace(s))
+		if err != nil {
+			return nil, nil, err
+		}
+		mezclas = append(mezclas, mezcla)
+	}
+
+	motores := MotoresComparacion()
+	if listaMotores != "" {
+		motores = nil
+		for _, s := range strings.Split(listaMotores, ",") {
+			motor := MotorSimulacion(strings.TrimSpace(s))
+			if motor != MotorEventos {
+				if _, err := BuscarEstrategia(string(motor)); err != nil {
+					return nil, nil, err
+				}
+			}
+			motores = append(motores, motor)
+		}
+	}
+	return RejillaComparacion(coches, recursos, mezclas), motores, nil
+}
+
+// comandoOptimizar busca la combinación más barata de recursos con la que un
+// escenario cumple un objetivo de espera:
+//
+//	taller optimizar -escenario escenarios/dia_normal.yaml -recursos plazas=2-12,mecanicos=1-8 \
+//		-costes plazas=40,mecanicos=160 -categoria mecanica -percentil 95 -espera 30m
+//
+// Si el escenario separa los mecánicos por especialidad, se varían con
+// mecanicos_mecanica, mecanicos_electrica, mecanicos_carroceria y
+// mecanicos_polivalentes en lugar de mecanicos.
+//
+// Devuelve el código de salida del programa: 1 también si ninguna
+// combinación cumple.
+func comandoOptimizar(args []string) int {
+	opciones := flag.NewFlagSet("optimizar", flag.ContinueOnError)
+	archivoEscenario := opciones.String("escenario", "", "archivo YAML o JSON con el escenario (llegadas, categorías y tiempos)")
+	listaRecursos := opciones.String("recursos", "plazas=1-10,mecanicos=1-6", "rangos de capacidad a probar, recurso=mínimo-máximo separados por comas")
+	listaCostes := opciones.String("costes", "plazas=40,mecanicos=160", "coste de cada unidad de recurso, recurso=coste separados por comas")
+	categoria := opciones.String("categoria", string(Mecanica), "categoría cuya espera se mide (todas para todos los coches)")
+	percentil := opciones.Int("percentil", 95, "percentil de la espera (50, 90, 95, 99 o 0 para la media)")
+	espera := opciones.Duration("espera", 30*time.Minute, "espera máxima que se admite")
+	repeticiones := opciones.Int("repeticiones", 3, "ejecuciones de cada combinación, con semillas distintas")
+	semilla := opciones.Int64("semilla", 0, "semilla de la primera repetición (0 para la del escenario)")
+	opciones.Int64Var(semilla, "seed", 0, "igual que -semilla")
+	archivoCSV := opciones.String("csv", "", "archivo donde guardar las combinaciones simuladas en CSV")
+	if err := opciones.Parse(args); err != nil {
+		return 2
+	}
+	if *archivoEscenario == "" {
+		fmt.Fprintln(os.Stderr, "Falta el escenario: optimizar -escenario archivo.yaml")
+		opciones.Usage()
+		return 2
+	}
+
+	rangos, costes, objetivo, err := capacidadDesdeOpciones(*listaRecursos, *listaCostes, *categoria, *percentil, *espera)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error en las opciones: %v\n", err)
+		return 2
+	}
+	escenario, err := CargarEscenario(*archivoEscenario)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error al cargar el escenario: %v\n", err)
+		return 1
+	}
+	if *semilla != 0 {
+		escenario.FijarSemilla(*semilla)
+	}
+
+	fmt.Printf("Escenario %s (motor %s, semilla %d)\n", escenario.Nombre, escenario.Motor, escenario.Semilla)
+	plan, err := OptimizarCapacidad(escenario, rangos, costes, objetivo, *repeticiones)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error al optimizar: %v\n", err)
+		return 1
+	}
+	ImprimirPlanCapacidad(os.Stdout, plan)
+
+	if *archivoCSV != "" {
+		err := escribirArchivo(*archivoCSV, func(w io.Writer) error { return EscribirPlanCapacidadCSV(w, plan) })
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error al guardar %s: %v\n", *archivoCSV, err)
+			return 1
+		}
+		fmt.Printf("Guardado %s\n", *archivoCSV)
+	}
+	if plan.Mejor == nil {
+		return 1
+	}
+	return 0
+}
+
+func capacidadDesdeOpciones(listaRecursos, listaCostes, categoria string, percentil int, espera time.Duration) ([]RangoRecurso, map[string]float64, ObjetivoEspera, error) {
+	objetivo := ObjetivoEspera{Percentil: percentil, Maximo: espera}
+	if categoria != "todas" {
+		tipo, ok := parsearTipoIncidencia(categoria)
+		if !ok {
+			return nil, nil, objetivo, fmt.Errorf("categoría '%s' no válida", categoria)
+		}
+		objetivo.Incidencia = tipo
+	}
+	if err := objetivo.validar(); err != nil {
+		return nil, nil, objetivo, err
+	}
+	rangos, err := parsearRangosRecursos(listaRecursos)
+	if err != nil {
+		return nil, nil, objetivo, err
+	}
+	costes, err := parsearCostesRecursos(listaCostes)
+	return rangos, costes, objetivo, err
+}
+
+// parsearRangosRecursos lee "plazas=2-8,mecanicos=1-4"; un número solo es un
+// rango de un valor
+func parsearRangosRecursos(lista string) ([]RangoRecurso, error) {
+	var rangos []RangoRecurso
+	for _, s := range strings.Split(lista, ",") {
+		recurso, rango, ok := strings.Cut(strings.TrimSpace(s), "=")
+		minimo, maximo, esRango := strings.Cut(rango, "-")
+		if !esRango {
+			maximo = minimo
+		}
+		r := RangoRecurso{Recurso: strings.TrimSpace(recurso)}
+		var errMinimo, errMaximo error
+		r.Minimo, errMinimo = strconv.Atoi(strings.TrimSpace(minimo))
+		r.Maximo, errMaximo = strconv.Atoi(strings.TrimSpace(maximo))
+		if !ok || r.Recurso == "" || errMinimo != nil || errMaximo != nil {
+			return nil, fmt.Errorf("rango '%s' no válido, se esperaba recurso=mínimo-máximo", s)
+		}
+		rangos = append(rangos, r)
+	}
+	return rangos, nil
+}
+
+// parsearCostesRecursos lee "plazas=40,mecanicos=160"
+func parsearCostesRecursos(lista string) (map[string]float64, error) {
+	costes := make(map[string]float64)
+	for _, s := range strings.Split(lista, ",") {
+		recurso, texto, ok := strings.Cut(strings.TrimSpace(s), "=")
+		coste, err := strconv.ParseFloat(strings.TrimSpace(texto), 64)
+		if !ok || err != nil || coste < 0 {
+			return nil, fmt.Errorf("coste '%s' no válido, se esperaba recurso=coste", s)
+		}
+		costes[strings.TrimSpace(recurso)] = coste
+	}
+	return costes, nil
+}
+
+func escribirArchivo(ruta string, escribir func(io.Writer) error) error {
+	archivo, err := os.Create(ruta)
+	if err != nil {
+		return err
+	}
+	if err := escribir(archivo); err != nil {
+		archivo.Close()
+		return err
+	}
+	return archivo.Close()
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"runtime"
+	"strconv"
+	"time"
+)
+
+// ============================================================================
+// COMPARACIÓN DE ESTRATEGIAS
+// ============================================================================
+
+// MezclaCategorias es qué parte de los coches es de cada categoría
+type MezclaCategorias struct {
+	Nombre       string
+	Proporciones map[TipoIncidencia]float64
+}
+
+// MezclasComparacion son las mezclas con las que se comparan los motores
+var MezclasComparacion = []MezclaCategorias{
+	{"equilibrada", map[TipoIncidencia]float64{Mecanica: 1.0 / 3, Electrica: 1.0 / 3, Carroceria: 1.0 / 3}},
+	{"mecanica", map[TipoIncidencia]float64{Mecanica: 0.6, Electrica: 0.2, Carroceria: 0.2}},
+	{"carroceria", map[TipoIncidencia]float64{Mecanica: 0.2, Electrica: 0.2, Carroceria: 0.6}},
+}
+
+// BuscarMezcla devuelve la mezcla con ese nombre
+func BuscarMezcla(nombre string) (MezclaCategorias, error) {
+	for _, mezcla := range MezclasComparacion {
+		if mezcla.Nombre == nombre {
+			return mezcla, nil
+		}
+	}
+	return MezclaCategorias{}, fmt.Errorf("mezcla '%s' no encontrada", nombre)
+}
+
+// CasoComparacion es un punto de la rejilla: cuántos coches llegan, con qué
+// recursos y de qué categorías
+type CasoComparacion struct {
+	Coches    int
+	Plazas    int
+	Mecanicos int
+	Mezcla    MezclaCategorias
+}
+
+// String sirve también de nombre de sub-benchmark
+func (c CasoComparacion) String() string {
+	return fmt.Sprintf("coches=%d/plazas=%d/mecanicos=%d/mezcla=%s", c.Coches, c.Plazas, c.Mecanicos, c.Mezcla.Nombre)
+}
+
+// escenario monta el escenario del caso para un motor. Todos los coches
+// llegan a la vez y la semilla es fija, así que cada motor recibe siempre
+// los mismos coches.
+func (c CasoComparacion) escenario(motor MotorSimulacion) (*Escenario, error) {
+	archivo := archivoEscenario{
+		Nombre:    c.String(),
+		Motor:     motor,
+		Semilla:   1,
+		Plazas:    c.Plazas,
+		Mecanicos: c.Mecanicos,
+		Vehiculos: c.Coches,
+	}
+	for _, tipo := range []TipoIncidencia{Mecanica, Electrica, Carroceria} {
+		if proporcion := c.Mezcla.Proporciones[tipo]; proporcion > 0 {
+			archivo.Categorias = append(archivo.Categorias, archivoCategoria{Incidencia: string(tipo), Proporcion: proporcion})
+		}
+	}
+	return archivo.escenario()
+}
+
+// RejillaComparacion combina todos los números de coches, recursos (plazas
+// y mecánicos) y mezclas
+func RejillaComparacion(coches []int, recursos [][2]int, mezclas []MezclaCategorias) []CasoComparacion {
+	var casos []CasoComparacion
+	for _, n := range coches {
+		for _, r := range recursos {
+			for _, mezcla := range mezclas {
+				casos = append(casos, CasoComparacion{Coches: n, Plazas: r[0], Mecanicos: r[1], Mezcla: mezcla})
+			}
+		}
+	}
+	return casos
+}
+
+// MotoresComparacion son el motor de eventos y todas las estrategias
+func MotoresComparacion() []MotorSimulacion {
+	motores := []MotorSimulacion{MotorEventos}
+	for _, estrategia := range Estrategias() {
+		motores = append(motores, MotorSimulacion(estrategia.Nombre()))
+	}
+	return motores
+}
+
+// FilaComparacion es la media de varias ejecuciones de un motor en un caso.
+// Rendimiento y estancias son de tiempo simulado; el resto, de lo que cuesta
+// correr la simulación.
+type FilaComparacion struct {
+	Caso             CasoComparacion
+	Motor            MotorSimulacion
+	Repeticiones     int
+	VehiculosPorHora float64
+	Estancia         Percentiles
+	// TiempoReal es lo que tarda en correr una ejecución
+	TiempoReal time.Duration
+	// Goroutines es el máximo que hubo a la vez además de las de antes de
+	// empezar, mirando cada milisegundo
+	Goroutines int
+	// Asignaciones y Bytes son los del montón en una ejecución
+	Asignaciones uint64
+	Bytes        uint64
+}
+
+// CompararMotores corre cada motor repeticiones veces en cada caso
+func CompararMotores(casos []CasoComparacion, motores []MotorSimulacion, repeticiones int) ([]FilaComparacion, error) {
+	if repeticiones < 1 {
+		repeticiones = 1
+	}
+	var filas []FilaComparacion
+	for _, caso := range casos {
+		for _, motor := range motores {
+			escenario, err := caso.escenario(motor)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", caso, err)
+			}
+			fila := FilaComparacion{Caso: caso, Motor: motor, Repeticiones: repeticiones}
+			for i := 0; i < repeticiones; i++ {
+				vehiculos, err := escenario.GenerarVehiculos()
+				if err != nil {
+					return nil, fmt.Errorf("%s: %w", caso, err)
+				}
+				resultado, m, err := medirSimulacion(escenario, escenario.Pipeline(), vehiculos)
+				if err != nil {
+					return nil, fmt.Errorf("%s, motor %s: %w", caso, motor, err)
+				}
+				fila.VehiculosPorHora += resultado.VehiculosPorHora
+				fila.Estancia.P50 += resultado.Global.Estancia.P50
+				fila.Estancia.P90 += resultado.Global.Estancia.P90
+				fila.Estancia.P95 += resultado.Global.Estancia.P95
+				fila.Estancia.P99 += resultado.Global.Estancia.P99
+				fila.TiempoReal += m.tiempo
+				fila.Asignaciones += m.asignaciones
+				fila.Bytes += m.bytes
+				if m.goroutines > fila.Goroutines {
+					fila.Goroutines = m.goroutines
+				}
+			}
+			n := Duracion(repeticiones)
+			fila.VehiculosPorHora /= float64(repeticiones)
+			fila.Estancia = Percentiles{fila.Estancia.P50 / n, fila.Estancia.P90 / n, fila.Estancia.P95 / n, fila.Estancia.P99 / n}
+			fila.TiempoReal /= time.Duration(repeticiones)
+			fila.Asignaciones /= uint64(repeticiones)
+			fila.Bytes /= uint64(repeticiones)
+			filas = append(filas, fila)
+		}
+	}
+	return filas, nil
+}
+
+// medicionSimulacion es lo que cuesta una ejecución
+type medicionSimulacion struct {
+	tiempo       time.Duration
+	goroutines   int
+	asignaciones uint64
+	bytes        uint64
+}
+
+// medirSimulacion corre el escenario con esos coches, sin registro, y mide
+// cuánto tarda, cuánta memoria pide y cuántas goroutines llega a tener
+func medirSimulacion(escenario *Escenario, pipeline *Pipeline, vehiculos []*Vehiculo) (*ResultadoSimulacion, medicionSimulacion, error) {
+	antes := runtime.NumGoroutine()
+	maximo := make(chan int)
+	parar := make(chan struct{})
+	go func() {
+		n := 0
+		ticker := time.NewTicker(time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				// La goroutine que mira no cuenta
+				if actuales := runtime.NumGoroutine() - antes - 1; actuales > n {
+					n = actuales
+				}
+			case <-parar:
+				maximo <- n
+				return
+			}
+		}
+	}()
+
+	var memoriaAntes, memoriaDespues runtime.MemStats
+	runtime.ReadMemStats(&memoriaAntes)
+	inicio := time.Now()
+	resultado, err := escenario.simular(pipeline, vehiculos, nil)
+	m := medicionSimulacion{tiempo: time.Since(inicio)}
+	runtime.ReadMemStats(&memoriaDespues)
+	close(parar)
+
+	m.goroutines = <-maximo
+	m.asignaciones = memoriaDespues.Mallocs - memoriaAntes.Mallocs
+	m.bytes = memoriaDespues.TotalAlloc - memoriaAntes.TotalAlloc
+	return resultado, m, err
+}
+
+// ImprimirComparacion escribe una tabla por caso con una fila por motor
+func ImprimirComparacion(w io.Writer, filas []FilaComparacion) {
+	var caso CasoComparacion
+	for i, f := range filas {
+		if i == 0 || f.Caso.String() != caso.String() {
+			caso = f.Caso
+			fmt.Fprintf(w, "\n%s (repeticiones: %d)\n", caso, f.Repeticiones)
+			fmt.Fprintf(w, "  %-10s %10s %10s %10s %10s %12s %10s %12s %12s\n",
+				"Motor", "Coches/h", "Est. p50", "Est. p95", "Est. p99", "Tiempo real", "Goroutines", "Asignac.", "Bytes")
+		}
+		fmt.Fprintf(w, "  %-10s %10.1f %10v %10v %10v %12v %10d %12d %12d\n",
+			f.Motor, f.VehiculosPorHora,
+			time.Duration(f.Estancia.P50).Round(time.Second),
+			time.Duration(f.Estancia.P95).Round(time.Second),
+			time.Duration(f.Estancia.P99).Round(time.Second),
+			f.TiempoReal.Round(time.Microsecond), f.Goroutines, f.Asignaciones, f.Bytes)
+	}
+}
+
+// EscribirComparacionCSV escribe una fila por motor y caso; los tiempos van
+// en segundos
+func EscribirComparacionCSV(w io.Writer, filas []FilaComparacion) error {
+	escritor := csv.NewWriter(w)
+	escritor.Write([]string{"coches", "plazas", "mecanicos", "mezcla", "motor", "repeticiones", "vehiculos_por_hora",
+		"estancia_p50_s", "estancia_p90_s", "estancia_p95_s", "estancia_p99_s", "tiempo_real_s", "goroutines", "asignaciones", "bytes"})
+	for _, f := range filas {
+		escritor.Write([]string{
+			strconv.Itoa(f.Caso.Coches),
+			strconv.Itoa(f.Caso.Plazas),
+			strconv.Itoa(f.Caso.Mecanicos),
+			f.Caso.Mezcla.Nombre,
+			string(f.Motor),
+			strconv.Itoa(f.Repeticiones),
+			strconv.FormatFloat(f.VehiculosPorHora, 'f', 3, 64),
+			segundos(f.Estancia.P50),
+			segundos(f.Estancia.P90),
+			segundos(f.Estancia.P95),
+			segundos(f.Estancia.P99),
+			strconv.FormatFloat(f.TiempoReal.Seconds(), 'f', 6, 64),
+			strconv.Itoa(f.Goroutines),
+			strconv.FormatUint(f.Asignaciones, 10),
+			strconv.FormatUint(f.Bytes, 10),
+		})
+	}
+	escritor.Flush()
+	return escritor.Error()
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestCompararMotores compara unos cuantos motores en una rejilla pequeña
+func TestCompararMotores(t *testing.T) {
+	casos, motores, err := rejillaDesdeOpciones("6", "2x1,3x2", "equilibrada", "eventos,pool,cond")
+	if err != nil {
+		t.Fatal(err)
+	}
+	filas, err := CompararMotores(casos, motores, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(filas) != 6 {
+		t.Fatalf("se esperaban 6 filas y hay %d", len(filas))
+	}
+	for _, f := range filas {
+		if f.VehiculosPorHora <= 0 || f.Estancia.P50 <= 0 || f.Estancia.P50 > f.Estancia.P99 || f.TiempoReal <= 0 {
+			t.Errorf("%s %s: fila inesperada: %+v", f.Caso, f.Motor, f)
+		}
+	}
+	// El motor de eventos no usa goroutines y siempre da lo mismo
+	if filas[0].Motor != MotorEventos || filas[0].Goroutines != 0 {
+		t.Errorf("fila de eventos inesperada: %+v", filas[0])
+	}
+
+	var tabla, datos bytes.Buffer
+	ImprimirComparacion(&tabla, filas)
+	if !strings.Contains(tabla.String(), "coches=6/plazas=3/mecanicos=2/mezcla=equilibrada") {
+		t.Errorf("falta un caso en la tabla:\n%s", tabla.String())
+	}
+	if err := EscribirComparacionCSV(&datos, filas); err != nil {
+		t.Fatal(err)
+	}
+	registros, err := csv.NewReader(&datos).ReadAll()
+	if err != nil || len(registros) != 7 || registros[1][4] != "eventos" {
+		t.Fatalf("CSV inesperado (%v): %v", err, registros)
+	}
+}
+
+func TestRejillaNoValida(t *testing.T) {
+	casos := [][4]string{
+		{"0", "5x3", "equilibrada", ""},
+		{"10", "5", "equilibrada", ""},
+		{"10", "5x3", "pintura", ""},
+		{"10", "5x3", "equilibrada", "hilos"},
+	}
+	for _, c := range casos {
+		if _, _, err := rejillaDesdeOpciones(c[0], c[1], c[2], c[3]); err == nil {
+			t.Errorf("%v: se esperaba un error", c)
+		}
+	}
+}
+
+// BenchmarkMotores corre cada motor en cada punto de la rejilla. Además de
+// tiempo y asignaciones informa del rendimiento simulado, el p95 de la
+// estancia y el máximo de goroutines:
+//
+//	go test -run '^$' -bench Motores -benchmem
+//	go test -run '^$' -bench 'Motores/cond/coches=100' -count 5
+func BenchmarkMotores(b *testing.B) {
+	casos := RejillaComparacion([]int{20, 100}, [][2]int{{5, 3}, {10, 6}}, MezclasComparacion)
+	for _, motor := range MotoresComparacion() {
+		for _, caso := range casos {
+			b.Run(fmt.Sprintf("%s/%s", motor, caso), func(b *testing.B) {
+				escenario, err := caso.escenario(motor)
+				if err != nil {
+					b.Fatal(err)
+				}
+				pipeline := escenario.Pipeline()
+				b.ReportAllocs()
+
+				var porHora float64
+				var p95 time.Duration
+				goroutines := 0
+				for i := 0; i < b.N; i++ {
+					b.StopTimer()
+					vehiculos, err := escenario.GenerarVehiculos()
+					if err != nil {
+						b.Fatal(err)
+					}
+					b.StartTimer()
+
+					resultado, m, err := medirSimulacion(escenario, pipeline, vehiculos)
+					if err != nil {
+						b.Fatal(err)
+					}
+					porHora += resultado.VehiculosPorHora
+					p95 += time.Duration(resultado.Global.Estancia.P95)
+					if m.goroutines > goroutines {
+						goroutines = m.goroutines
+					}
+				}
+				b.ReportMetric(porHora/float64(b.N), "coches/h")
+				b.ReportMetric(p95.Seconds()/float64(b.N), "p95-estancia-s")
+				b.ReportMetric(float64(goroutines), "goroutines")
+			})
+		}
+	}
+}
@@ -0,0 +1,543 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ============================================================================
+// ESCENARIOS DE SIMULACIÓN
+// ============================================================================
+
+// MotorSimulacion es la implementación con la que se corre un escenario:
+// eventos o el nombre de cualquiera de las Estrategias
+type MotorSimulacion string
+
+const (
+	MotorEventos   MotorSimulacion = "eventos"
+	MotorRWMutex   MotorSimulacion = "rwmutex"
+	MotorWaitGroup MotorSimulacion = "waitgroup"
+)
+
+// TipoLlegadas dice cómo se reparten las llegadas de los coches
+type TipoLlegadas string
+
+const (
+	// LlegadasJuntas: todos llegan a la vez, en el orden de las categorías
+	LlegadasJuntas TipoLlegadas = "juntas"
+	// LlegadasFijas: uno cada Intervalo, con las categorías mezcladas
+	LlegadasFijas TipoLlegadas = "fijas"
+	// LlegadasPoisson: proceso de Poisson con Intervalo de media entre
+	// llegadas, con las categorías mezcladas
+	LlegadasPoisson TipoLlegadas = "poisson"
+	// LlegadasPerfil: Poisson con el ritmo de cada tramo del Perfil (por
+	// defecto PerfilTallerPorDefecto), con las categorías mezcladas
+	LlegadasPerfil TipoLlegadas = "perfil"
+	// LlegadasRafagas: grupos de Rafaga coches de media, uno cada
+	// Separacion, con Intervalo de media entre grupos
+	LlegadasRafagas TipoLlegadas = "rafagas"
+	// LlegadasRegistro: las de Instantes o, si no se dan, las del Archivo
+	// (CargarRegistroLlegadas), con las categorías mezcladas
+	LlegadasRegistro TipoLlegadas = "registro"
+)
+
+// ProcesoLlegadas describe cómo llegan los coches al taller
+type ProcesoLlegadas struct {
+	Tipo       TipoLlegadas  `json:"tipo" yaml:"tipo"`
+	Intervalo  Duracion      `json:"intervalo" yaml:"intervalo"`
+	Perfil     []TramoPerfil `json:"perfil" yaml:"perfil"`
+	Rafaga     int           `json:"rafaga" yaml:"rafaga"`
+	Separacion Duracion      `json:"separacion" yaml:"separacion"`
+	// Archivo es relativo al del escenario
+	Archivo   string     `json:"archivo" yaml:"archivo"`
+	Instantes []Duracion `json:"instantes" yaml:"instantes"`
+}
+
+// Generador devuelve el generador de estas llegadas
+func (p ProcesoLlegadas) Generador() (GeneradorLlegadas, error) {
+	switch p.Tipo {
+	case LlegadasJuntas:
+		return GeneradorJuntas{}, nil
+	case LlegadasFijas, LlegadasPoisson, LlegadasRafagas:
+		if p.Intervalo <= 0 {
+			return nil, fmt.Errorf("las llegadas '%s' necesitan un intervalo positivo", p.Tipo)
+		}
+	}
+	switch p.Tipo {
+	case LlegadasFijas:
+		return GeneradorFijo{Intervalo: time.Duration(p.Intervalo)}, nil
+	case LlegadasPoisson:
+		return GeneradorPoisson{Intervalo: time.Duration(p.Intervalo)}, nil
+	case LlegadasPerfil:
+		generador := GeneradorPerfil{Tramos: p.Perfil}
+		if generador.Tramos == nil {
+			generador.Tramos = PerfilTallerPorDefecto()
+		}
+		return generador, generador.Validar()
+	case LlegadasRafagas:
+		if p.Rafaga < 1 || p.Separacion < 0 {
+			return nil, fmt.Errorf("las ráfagas necesitan al menos un coche y una separación no negativa")
+		}
+		return GeneradorRafagas{Intervalo: time.Duration(p.Intervalo), Tamano: p.Rafaga, Separacion: time.Duration(p.Separacion)}, nil
+	case LlegadasRegistro:
+		if p.Instantes == nil {
+			if p.Archivo == "" {
+				return nil, fmt.Errorf("las llegadas del registro necesitan instantes o un archivo")
+			}
+			return CargarRegistroLlegadas(p.Archivo)
+		}
+		generador := &GeneradorRegistro{}
+		for _, instante := range p.Instantes {
+			generador.Instantes = append(generador.Instantes, time.Duration(instante))
+		}
+		return generador, nil
+	}
+	return nil, fmt.Errorf("tipo de llegadas '%s' no válido", p.Tipo)
+}
+
+// CategoriaEscenario es cuántos coches de una categoría llegan
+type CategoriaEscenario struct {
+	Incidencia TipoIncidencia
+	Vehiculos  int
+}
+
+// Escenario es todo lo que hace falta para repetir una simulación: los
+// recursos del taller, qué coches llegan y cuándo, y cuánto tardan
+type Escenario struct {
+	Nombre     string
+	Motor      MotorSimulacion
+	Plazas     int
+	Mecanicos  int
+	Llegadas   ProcesoLlegadas
+	Categorias []CategoriaEscenario
+	Tiempos    *ModeloTiempos
+	// Recursos son los que hay además de plazas y mecánicos
+	Recursos map[string]int
+	// Fases del pipeline; sin fases, las del taller de siempre
+	Fases []Fase
+	// Semilla de las llegadas y, si el modelo de tiempos no tiene una
+	// propia, de los tiempos. Con 0 se elige una al cargar y se guarda aquí.
+	Semilla int64
+	// Especialistas y Polivalentes, si hay alguno, sustituyen a Mecanicos
+	// por mecánicos separados por especialidad (Pipeline.ConEspecialistas)
+	Especialistas map[Especialidad]int
+	Polivalentes  int
+
+	// semillaTiempos dice si el modelo de tiempos trae su propia semilla
+	semillaTiempos bool
+}
+
+// archivoEscenario es el formato de los archivos de escenario, en YAML o en
+// JSON. Las categorías se dan con su número de coches o con su proporción
+// sobre el total de vehiculos.
+type archivoEscenario struct {
+	Nombre     string             `json:"nombre" yaml:"nombre"`
+	Motor      MotorSimulacion    `json:"motor" yaml:"motor"`
+	Semilla    int64              `json:"semilla" yaml:"semilla"`
+	Plazas     int                `json:"plazas" yaml:"plazas"`
+	Mecanicos  int                `json:"mecanicos" yaml:"mecanicos"`
+	Vehiculos  int                `json:"vehiculos" yaml:"vehiculos"`
+	Llegadas   ProcesoLlegadas    `json:"llegadas" yaml:"llegadas"`
+	Categorias []archivoCategoria `json:"categorias" yaml:"categorias"`
+	Recursos   map[string]int     `json:"recursos" yaml:"recursos"`
+	Fases      []archivoFase      `json:"fases" yaml:"fases"`
+	Tiempos    archivoTiempos     `json:"tiempos" yaml:"tiempos"`
+
+	// Especialistas son los mecánicos de cada especialidad
+	// ("mecanica", "electrica", "carroceria")
+	Especialistas map[string]int `json:"especialistas" yaml:"especialistas"`
+	Polivalentes  int            `json:"polivalentes" yaml:"polivalentes"`
+}
+
+// archivoFase es una Fase en el archivo. Dura Duracion si se da y, si no,
+// Factor veces lo que el coche tarda en ella (1 si no se da).
+type archivoFase struct {
+	Nombre       string    `json:"nombre" yaml:"nombre"`
+	Adquiere     []string  `json:"adquiere" yaml:"adquiere"`
+	Libera       []string  `json:"libera" yaml:"libera"`
+	Trabajadores int       `json:"trabajadores" yaml:"trabajadores"`
+	Duracion     *Duracion `json:"duracion" yaml:"duracion"`
+	Factor       float64   `json:"factor" yaml:"factor"`
+}
+
+func (a archivoFase) fase() (Fase, error) {
+	fase := Fase{Nombre: a.Nombre, Adquiere: a.Adquiere, Libera: a.Libera, Trabajadores: a.Trabajadores}
+	switch {
+	case a.Duracion != nil:
+		if *a.Duracion < 0 {
+			return fase, fmt.Errorf("%s: la duración no puede ser negativa", a.Nombre)
+		}
+		duracion := time.Duration(*a.Duracion)
+		fase.Tiempo = func(*Vehiculo) time.Duration { return duracion }
+	case a.Factor < 0:
+		return fase, fmt.Errorf("%s: el factor no puede ser negativo", a.Nombre)
+	case a.Factor != 0 && a.Factor != 1:
+		factor, nombre := a.Factor, a.Nombre
+		fase.Tiempo = func(v *Vehiculo) time.Duration { return time.Duration(factor * float64(v.TiempoEn(nombre))) }
+	}
+	return fase, nil
+}
+
+type archivoCategoria struct {
+	Incidencia string  `json:"incidencia" yaml:"incidencia"`
+	Vehiculos  int     `json:"vehiculos" yaml:"vehiculos"`
+	Proporcion float64 `json:"proporcion" yaml:"proporcion"`
+}
+
+// CargarEscenario lee un escenario de un archivo YAML o, si acaba en .json,
+// JSON. Por ejemplo:
+//
+//	nombre: Día normal
+//	plazas: 5
+//	mecanicos: 3
+//	vehiculos: 200
+//	llegadas: {tipo: poisson, intervalo: 3m}
+//	categorias:
+//	  - {incidencia: mecanica, proporcion: 0.5}
+//	  - {incidencia: electrica, proporcion: 0.3}
+//	  - {incidencia: carroceria, proporcion: 0.2}
+//	tiempos:
+//	  fase: {mecanica: 10m, electrica: 6m, carroceria: 2m}
+//	  distribucion: {tipo: lognormal, sigma: 0.4}
+//
+// Cada categoría puede tener su propio tiempo en algunas fases, y su propia
+// distribución; las fases que no aparecen duran lo de fase:
+//
+//	tiempos:
+//	  fase: {mecanica: 10m, electrica: 6m, carroceria: 2m}
+//	  fases:
+//	    carroceria: {Reparación: 45m, Limpieza: 5m}
+//	  distribuciones:
+//	    carroceria: {tipo: exponencial}
+//
+// En lugar de mecanicos se pueden dar los mecánicos de cada especialidad y
+// los polivalentes, que saben de todo y solo reparan cuando no hay un
+// especialista libre:
+//
+//	especialistas: {mecanica: 2, electrica: 1, carroceria: 1}
+//	polivalentes: 1
+//
+// Sin lista de fases se usan las del taller de siempre (PipelineTaller). Si
+// se da, las fases pueden coger y soltar plazas, mecanicos o cualquier otro
+// recurso declarado en recursos:
+//
+//	recursos: {elevadores: 2}
+//	fases:
+//	  - {nombre: Entrada, adquiere: [plazas]}
+//	  - {nombre: Diagnóstico, adquiere: [elevadores], libera: [elevadores], factor: 0.5}
+//	  - {nombre: Reparación, adquiere: [mecanicos], libera: [mecanicos]}
+//	  - {nombre: Secado, duracion: 20m}
+//	  - {nombre: Prueba en carretera, trabajadores: 1, duracion: 10m, libera: [plazas]}
+func CargarEscenario(ruta string) (*Escenario, error) {
+	datos, err := os.ReadFile(ruta)
+	if err != nil {
+		return nil, err
+	}
+	var archivo archivoEscenario
+	if strings.EqualFold(filepath.Ext(ruta), ".json") {
+		err = json.Unmarshal(datos, &archivo)
+	} else {
+		err = yaml.Unmarshal(datos, &archivo)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", ruta, err)
+	}
+
+	if a := archivo.Llegadas.Archivo; a != "" && !filepath.IsAbs(a) {
+		archivo.Llegadas.Archivo = filepath.Join(filepath.Dir(ruta), a)
+	}
+	escenario, err := archivo.escenario()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", ruta, err)
+	}
+	if escenario.Nombre == "" {
+		escenario.Nombre = strings.TrimSuffix(filepath.Base(ruta), filepath.Ext(ruta))
+	}
+	return escenario, nil
+}
+
+func (a archivoEscenario) escenario() (*Escenario, error) {
+	e := &Escenario{
+		Nombre:    a.Nombre,
+		Motor:     a.Motor,
+		Plazas:    a.Plazas,
+		Mecanicos: a.Mecanicos,
+		Llegadas:  a.Llegadas,
+		Semilla:   a.Semilla,
+	}
+	if e.Motor == "" {
+		e.Motor = MotorEventos
+	}
+	if e.Motor != MotorEventos {
+		if _, err := BuscarEstrategia(string(e.Motor)); err != nil {
+			return nil, fmt.Errorf("motor '%s' no válido", e.Motor)
+		}
+	}
+	for nombre, n := range a.Especialistas {
+		especialidad, ok := parsearEspecialidad(nombre)
+		if !ok || n < 0 {
+			return nil, fmt.Errorf("especialistas: '%s: %d' no válido", nombre, n)
+		}
+		if e.Especialistas == nil {
+			e.Especialistas = make(map[Especialidad]int)
+		}
+		e.Especialistas[especialidad] = n
+	}
+	e.Polivalentes = a.Polivalentes
+	if e.conEspecialistas() {
+		if e.Mecanicos != 0 {
+			return nil, fmt.Errorf("se dan mecanicos y además especialistas o polivalentes")
+		}
+		if e.Polivalentes < 0 || e.totalEspecialistas() == 0 {
+			return nil, fmt.Errorf("hace falta al menos un especialista o un polivalente")
+		}
+	} else if e.Mecanicos <= 0 {
+		return nil, fmt.Errorf("hace falta al menos una plaza y un mecánico")
+	}
+	if e.Plazas <= 0 {
+		return nil, fmt.Errorf("hace falta al menos una plaza y un mecánico")
+	}
+
+	if e.Llegadas.Tipo == "" {
+		e.Llegadas.Tipo = LlegadasJuntas
+	}
+	generador, err := e.Llegadas.Generador()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(a.Categorias) == 0 {
+		return nil, fmt.Errorf("el escenario no tiene categorías")
+	}
+	asignados := 0
+	for _, c := range a.Categorias {
+		tipo, ok := parsearTipoIncidencia(c.Incidencia)
+		if !ok {
+			return nil, fmt.Errorf("tipo de incidencia '%s' no válido", c.Incidencia)
+		}
+		vehiculos := c.Vehiculos
+		if c.Proporcion != 0 {
+			if a.Vehiculos <= 0 || c.Proporcion < 0 {
+				return nil, fmt.Errorf("%s: la proporción necesita un total de vehiculos y no puede ser negativa", c.Incidencia)
+			}
+			vehiculos = int(c.Proporcion * float64(a.Vehiculos))
+		}
+		if vehiculos < 0 {
+			return nil, fmt.Errorf("%s: el número de vehículos no puede ser negativo", c.Incidencia)
+		}
+		asignados += vehiculos
+		e.Categorias = append(e.Categorias, CategoriaEscenario{Incidencia: tipo, Vehiculos: vehiculos})
+	}
+	// Lo que se pierde al redondear las proporciones va a las primeras
+	for i := 0; asignados < a.Vehiculos && a.Categorias[i%len(a.Categorias)].Proporcion > 0; i++ {
+		e.Categorias[i%len(e.Categorias)].Vehiculos++
+		asignados++
+	}
+
+	if registro, ok := generador.(*GeneradorRegistro); ok && len(registro.Instantes) < e.totalVehiculos() {
+		return nil, fmt.Errorf("el registro solo tiene %d llegadas y hacen falta %d", len(registro.Instantes), e.totalVehiculos())
+	}
+
+	for nombre, capacidad := range a.Recursos {
+		if nombre == RecursoPlazas || strings.HasPrefix(nombre, RecursoMecanicos) {
+			return nil, fmt.Errorf("'%s' se indica fuera de recursos", nombre)
+		}
+		if e.Recursos == nil {
+			e.Recursos = make(map[string]int)
+		}
+		e.Recursos[nombre] = capacidad
+	}
+	for _, archivoFase := range a.Fases {
+		fase, err := archivoFase.fase()
+		if err != nil {
+			return nil, err
+		}
+		e.Fases = append(e.Fases, fase)
+	}
+	pipeline := e.Pipeline()
+	if err := pipeline.Validar(); err != nil {
+		return nil, err
+	}
+	for _, c := range e.Categorias {
+		if c.Vehiculos == 0 {
+			continue
+		}
+		if err := pipeline.Atender(c.Incidencia); err != nil {
+			return nil, err
+		}
+	}
+
+	if e.Semilla == 0 {
+		e.Semilla = time.Now().UnixNano()
+	}
+	e.Tiempos = ModeloTiemposPorDefecto()
+	if err := e.Tiempos.aplicar(a.Tiempos); err != nil {
+		return nil, err
+	}
+	if err := comprobarFasesTiempos(e.Tiempos, pipeline); err != nil {
+		return nil, err
+	}
+	e.semillaTiempos = e.Tiempos.Semilla != 0
+	if !e.semillaTiempos {
+		e.Tiempos.Semilla = e.Semilla
+	}
+	return e, nil
+}
+
+// comprobarFasesTiempos comprueba que las fases con tiempo propio existen
+func comprobarFasesTiempos(tiempos *ModeloTiempos, pipeline *Pipeline) error {
+	existe := make(map[string]bool, len(pipeline.Fases))
+	for _, fase := range pipeline.Fases {
+		existe[fase.Nombre] = true
+	}
+	for tipo, fases := range tiempos.Fases {
+		for nombre := range fases {
+			if !existe[nombre] {
+				return fmt.Errorf("tiempos de %s: la fase '%s' no existe", tipo, nombre)
+			}
+		}
+	}
+	return nil
+}
+
+// FijarSemilla cambia la semilla del escenario, por ejemplo para repetir una
+// ejecución anterior. Los tiempos la siguen salvo que tengan una propia.
+func (e *Escenario) FijarSemilla(semilla int64) {
+	e.Semilla = semilla
+	if !e.semillaTiempos {
+		e.Tiempos.Semilla = semilla
+	}
+}
+
+// GenerarVehiculos crea los coches del escenario con sus llegadas. Con la
+// misma semilla salen siempre los mismos.
+func (e *Escenario) GenerarVehiculos() ([]*Vehiculo, error) {
+	generador, err := e.Llegadas.Generador()
+	if err != nil {
+		return nil, err
+	}
+
+	e.Tiempos.reiniciar()
+	var vehiculos []*Vehiculo
+	for _, c := range e.Categorias {
+		for i := 0; i < c.Vehiculos; i++ {
+			vehiculos = append(vehiculos, NewVehiculoConTiempos(len(vehiculos)+1, c.Incidencia, e.Tiempos))
+		}
+	}
+
+	rng := rand.New(rand.NewSource(e.Semilla))
+	if e.Llegadas.Tipo != LlegadasJuntas {
+		rng.Shuffle(len(vehiculos), func(i, j int) { vehiculos[i], vehiculos[j] = vehiculos[j], vehiculos[i] })
+	}
+	instantes, err := generador.Generar(len(vehiculos), rng)
+	if err != nil {
+		return nil, err
+	}
+
+	// Un instante fijo para que las llegadas no dependan de la hora
+	inicio := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+	for i, v := range vehiculos {
+		v.TiempoLlegada = inicio.Add(instantes[i])
+	}
+	return vehiculos, nil
+}
+
+// conEspecialistas dice si los mecánicos van por especialidad
+func (e *Escenario) conEspecialistas() bool {
+	return len(e.Especialistas) > 0 || e.Polivalentes != 0
+}
+
+func (e *Escenario) totalEspecialistas() int {
+	total := e.Polivalentes
+	for _, n := range e.Especialistas {
+		total += n
+	}
+	return total
+}
+
+// DescribirMecanicos dice cuántos mecánicos hay: "3 mecánicos" o, por
+// especialidad, "mecánicos mecanica=2 electrica=1 carroceria=0 polivalentes=1"
+func (e *Escenario) DescribirMecanicos() string {
+	if !e.conEspecialistas() {
+		return fmt.Sprintf("%d mecánicos", e.Mecanicos)
+	}
+	texto := "mecánicos"
+	for _, especialidad := range Especialidades() {
+		texto += fmt.Sprintf(" %s=%d", especialidad, e.Especialistas[especialidad])
+	}
+	return texto + fmt.Sprintf(" polivalentes=%d", e.Polivalentes)
+}
+
+// totalVehiculos es la suma de los de todas las categorías
+func (e *Escenario) totalVehiculos() int {
+	total := 0
+	for _, c := range e.Categorias {
+		total += c.Vehiculos
+	}
+	return total
+}
+
+// Ejecutar corre el escenario con su motor y devuelve las métricas, y
+// escribe el registro en salida (nil para no escribirlo). Las estrategias con
+// goroutines corren con un reloj virtual; si se bloquean devuelven un
+// *ErrorBloqueo.
+func (e *Escenario) Ejecutar(salida io.Writer) (*ResultadoSimulacion, error) {
+	vehiculos, err := e.GenerarVehiculos()
+	if err != nil {
+		return nil, err
+	}
+	return e.simular(e.Pipeline(), vehiculos, salida)
+}
+
+// simular es Ejecutar con los coches ya generados
+func (e *Escenario) simular(pipeline *Pipeline, vehiculos []*Vehiculo, salida io.Writer) (*ResultadoSimulacion, error) {
+	if e.Motor == MotorEventos {
+		resultado, err := SimularPipelineEventos(pipeline, vehiculos, salida)
+		if err != nil {
+			return nil, err
+		}
+		resultado.Semilla = e.Semilla
+		return resultado, nil
+	}
+	estrategia, _ := BuscarEstrategia(string(e.Motor))
+	reloj := NewRelojVirtual(time.Now())
+	parar := reloj.Automatico(50 * time.Microsecond)
+	defer parar()
+	resultado, err := estrategia.Simular(context.Background(), pipeline, vehiculos, reloj, salida)
+	if err != nil {
+		return nil, err
+	}
+	resultado.Semilla = e.Semilla
+	return resultado, nil
+}
+
+// Pipeline monta las fases del escenario con sus recursos
+func (e *Escenario) Pipeline() *Pipeline {
+	var pipeline *Pipeline
+	if e.Fases == nil {
+		pipeline = PipelineTaller(e.Plazas, e.Mecanicos)
+	} else {
+		pipeline = NewPipeline().
+			ConRecurso(RecursoPlazas, e.Plazas).
+			ConRecurso(RecursoMecanicos, e.Mecanicos)
+		for _, fase := range e.Fases {
+			pipeline.ConFase(fase)
+		}
+	}
+	if e.conEspecialistas() {
+		pipeline.ConEspecialistas(e.Especialistas, e.Polivalentes)
+	}
+	for recurso, capacidad := range e.Recursos {
+		pipeline.ConRecurso(recurso, capacidad)
+	}
+	return pipeline
+}
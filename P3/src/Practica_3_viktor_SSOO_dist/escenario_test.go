@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestEscenariosDeEjemplo carga y corre todos los escenarios de la carpeta
+// escenarios
+func TestEscenariosDeEjemplo(t *testing.T) {
+	rutas, _ := filepath.Glob(filepath.Join("escenarios", "*.yaml"))
+	json, _ := filepath.Glob(filepath.Join("escenarios", "*.json"))
+	rutas = append(rutas, json...)
+	if len(rutas) == 0 {
+		t.Fatal("no hay escenarios de ejemplo")
+	}
+	for _, ruta := range rutas {
+		escenario, err := CargarEscenario(ruta)
+		if err != nil {
+			t.Errorf("%s: %v", ruta, err)
+			continue
+		}
+		total := 0
+		for _, c := range escenario.Categorias {
+			total += c.Vehiculos
+		}
+		if escenario.Motor != MotorEventos {
+			continue
+		}
+		resultado, err := escenario.Ejecutar(nil)
+		if err != nil {
+			t.Errorf("%s: %v", ruta, err)
+			continue
+		}
+		if resultado.Global.Vehiculos != total || resultado.Duracion <= 0 {
+			t.Errorf("%s: se simularon %d de %d coches en %v", ruta, resultado.Global.Vehiculos, total, resultado.Duracion)
+		}
+	}
+}
+
+// TestEscenarioCaso1 comprueba que el escenario del Test Case 1 da lo mismo
+// que los coches de generarVehiculos
+func TestEscenarioCaso1(t *testing.T) {
+	escenario, err := CargarEscenario(filepath.Join("escenarios", "caso1.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if escenario.Plazas != 5 || escenario.Mecanicos != 3 || len(escenario.Categorias) != 3 {
+		t.Fatalf("escenario inesperado: %+v", escenario)
+	}
+
+	var desdeEscenario, aMano bytes.Buffer
+	escenario.Ejecutar(&desdeEscenario)
+	if _, err := SimularTallerEventos(generarVehiculos(10, 10, 10), 5, 3, &aMano); err != nil {
+		t.Fatal(err)
+	}
+	if desdeEscenario.String() != aMano.String() {
+		t.Fatal("el escenario no simula lo mismo que el Test Case 1")
+	}
+}
+
+// TestEscenarioReproducible comprueba que con la misma semilla salen los
+// mismos coches, llegadas y tiempos
+func TestEscenarioReproducible(t *testing.T) {
+	escenario, err := CargarEscenario(filepath.Join("escenarios", "dia_normal.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	otro, _ := CargarEscenario(filepath.Join("escenarios", "dia_normal.yaml"))
+
+	var primera, segunda, tercera bytes.Buffer
+	escenario.Ejecutar(&primera)
+	escenario.Ejecutar(&segunda)
+	otro.Ejecutar(&tercera)
+	if primera.String() != segunda.String() || primera.String() != tercera.String() {
+		t.Fatal("el mismo escenario dio simulaciones distintas")
+	}
+	if vehiculos, _ := escenario.GenerarVehiculos(); len(vehiculos) != 160 {
+		t.Fatal("las proporciones no suman el total de vehículos")
+	}
+}
+
+// TestEscenarioSemilla comprueba que con la semilla de una ejecución se
+// repiten sus coches aunque el escenario no fije ninguna
+func TestEscenarioSemilla(t *testing.T) {
+	ruta := filepath.Join(t.TempDir(), "azar.yaml")
+	os.WriteFile(ruta, []byte(`motor: cond
+plazas: 2
+mecanicos: 1
+llegadas: {tipo: poisson, intervalo: 5m}
+categorias: [{incidencia: mecanica, vehiculos: 4}, {incidencia: carroceria, vehiculos: 4}]
+tiempos: {distribucion: {tipo: exponencial}}
+`), 0644)
+	escenario, err := CargarEscenario(ruta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resultado, err := escenario.Ejecutar(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resultado.Semilla == 0 || resultado.Semilla != escenario.Semilla {
+		t.Fatalf("el resultado no apunta la semilla: %d", resultado.Semilla)
+	}
+
+	otro, _ := CargarEscenario(ruta)
+	otro.FijarSemilla(resultado.Semilla)
+	a, _ := escenario.GenerarVehiculos()
+	b, _ := otro.GenerarVehiculos()
+	for i := range a {
+		if !reflect.DeepEqual(a[i], b[i]) {
+			t.Fatalf("con la misma semilla salió otro coche: %+v y %+v", a[i], b[i])
+		}
+	}
+}
+
+func TestEscenarioNoValido(t *testing.T) {
+	casos := map[string]string{
+		"motor":         "plazas: 1\nmecanicos: 1\nmotor: hilos\ncategorias: [{incidencia: mecanica, vehiculos: 1}]",
+		"recursos":      "plazas: 0\nmecanicos: 1\ncategorias: [{incidencia: mecanica, vehiculos: 1}]",
+		"incidencia":    "plazas: 1\nmecanicos: 1\ncategorias: [{incidencia: pintura, vehiculos: 1}]",
+		"llegadas":      "plazas: 1\nmecanicos: 1\nllegadas: {tipo: poisson}\ncategorias: [{incidencia: mecanica, vehiculos: 1}]",
+		"fases":         "plazas: 1\nmecanicos: 1\nfases: [Entrada, Pintura]\ncategorias: [{incidencia: mecanica, vehiculos: 1}]",
+		"duracion":      "plazas: 1\nmecanicos: 1\ncategorias: [{incidencia: mecanica, vehiculos: 1}]\ntiempos: {fase: {mecanica: mucho}}",
+		"especialistas": "plazas: 1\nmecanicos: 1\nespecialistas: {mecanica: 1}\ncategorias: [{incidencia: mecanica, vehiculos: 1}]",
+		"especialidad":  "plazas: 1\nespecialistas: {mecanica: 1}\ncategorias: [{incidencia: carroceria, vehiculos: 1}]",
+		"tiempos":       "plazas: 1\nmecanicos: 1\ncategorias: [{incidencia: mecanica, vehiculos: 1}]\ntiempos: {fases: {mecanica: {Pintura: 1m}}}",
+	}
+	dir := t.TempDir()
+	for nombre, contenido := range casos {
+		ruta := filepath.Join(dir, nombre+".yaml")
+		if err := os.WriteFile(ruta, []byte(contenido), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := CargarEscenario(ruta); err == nil {
+			t.Errorf("%s: se esperaba un error", nombre)
+		} else if !strings.Contains(err.Error(), ruta) {
+			t.Errorf("%s: el error no dice el archivo: %v", nombre, err)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// ============================================================================
+// ESTADO DEL TALLER
+// ============================================================================
+
+// TrabajoEnCola resume un trabajo pendiente, en la cola principal o en la
+// cola personal de un mecánico
+type TrabajoEnCola struct {
+	VehiculoID   int            `json:"vehiculo_id"`
+	Matricula    string         `json:"matricula"`
+	IncidenciaID int            `json:"incidencia_id"`
+	Tipo         TipoIncidencia `json:"tipo"`
+	Prioridad    Prioridad      `json:"prioridad"`
+}
+
+// TrabajoEnCurso es un trabajo que un mecánico ya ha empezado
+type TrabajoEnCurso struct {
+	TrabajoEnCola
+	Inicio       time.Time     `json:"inicio"`
+	Transcurrido time.Duration `json:"transcurrido"`
+}
+
+// EstadoMecanico es la carga de un mecánico en un instante
+type EstadoMecanico struct {
+	ID           int             `json:"id"`
+	Nombre       string          `json:"nombre"`
+	Especialidad Especialidad    `json:"especialidad"`
+	Experiencia  int             `json:"experiencia"`
+	Activo       bool            `json:"activo"`
+	Ocupacion    int             `json:"ocupacion"`
+	Capacidad    int             `json:"capacidad"`
+	Cola         []TrabajoEnCola `json:"cola"`
+	EnCurso      *TrabajoEnCurso `json:"en_curso,omitempty"`
+}
+
+// EstadoTaller es una foto del taller: la cola principal y la carga de cada
+// mecánico (ordenados por ID)
+type EstadoTaller struct {
+	Instante  time.Time        `json:"instante"`
+	Cola      []TrabajoEnCola  `json:"cola"`
+	Mecanicos []EstadoMecanico `json:"mecanicos"`
+}
+
+func nuevoTrabajoEnCola(tr *TrabajoMecanico) TrabajoEnCola {
+	return TrabajoEnCola{
+		VehiculoID:   tr.Vehiculo.ID,
+		Matricula:    tr.Vehiculo.Matricula,
+		IncidenciaID: tr.Incidencia.ID,
+		Tipo:         tr.Incidencia.Tipo,
+		Prioridad:    tr.Incidencia.Prioridad,
+	}
+}
+
+// nuevoEstadoMecanico toma la foto de un mecánico en el instante ahora
+func nuevoEstadoMecanico(m *Mecanico, ahora time.Time) EstadoMecanico {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	estado := EstadoMecanico{
+		ID:           m.ID,
+		Nombre:       m.Nombre,
+		Especialidad: m.Especialidad,
+		Experiencia:  m.Experiencia,
+		Activo:       m.Activo,
+		Ocupacion:    len(m.ColaPersonal),
+		Capacidad:    PlazasPorMecanico,
+		Cola:         make([]TrabajoEnCola, 0, len(m.ColaPersonal)),
+	}
+	for _, tr := range m.ColaPersonal {
+		if !tr.Inicio.IsZero() {
+			estado.EnCurso = &TrabajoEnCurso{
+				TrabajoEnCola: nuevoTrabajoEnCola(tr),
+				Inicio:        tr.Inicio,
+				Transcurrido:  ahora.Sub(tr.Inicio),
+			}
+			continue
+		}
+		estado.Cola = append(estado.Cola, nuevoTrabajoEnCola(tr))
+	}
+	return estado
+}
+
+// Estado devuelve la foto actual del taller
+func (t *Taller) Estado() EstadoTaller {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	ahora := time.Now()
+	estado := EstadoTaller{
+		Instante: ahora,
+		Cola:     make([]TrabajoEnCola, 0, len(t.ColaTrabajo)),
+	}
+	for _, tr := range t.ColaTrabajo {
+		estado.Cola = append(estado.Cola, nuevoTrabajoEnCola(tr))
+	}
+
+	mecanicos := t.MecanicoManager.ListarMecanicos()
+	sort.Slice(mecanicos, func(i, j int) bool { return mecanicos[i].ID < mecanicos[j].ID })
+	estado.Mecanicos = make([]EstadoMecanico, 0, len(mecanicos))
+	for _, m := range mecanicos {
+		estado.Mecanicos = append(estado.Mecanicos, nuevoEstadoMecanico(m, ahora))
+	}
+	return estado
+}
+
+// ============================================================================
+// RENDERIZADO DEL ESTADO
+// ============================================================================
+
+// FormatoEstado indica cómo se muestra el estado del taller
+type FormatoEstado string
+
+const (
+	FormatoTexto FormatoEstado = "texto"
+	FormatoTabla FormatoEstado = "tabla"
+	FormatoJSON  FormatoEstado = "json"
+)
+
+// RenderizarEstado escribe el estado en w con el formato indicado
+func RenderizarEstado(w io.Writer, estado EstadoTaller, formato FormatoEstado) error {
+	switch formato {
+	case FormatoTexto:
+		return renderizarTexto(w, estado)
+	case FormatoTabla:
+		return renderizarTabla(w, estado)
+	case FormatoJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(estado)
+	}
+	return fmt.Errorf("formato de estado '%s' no válido", formato)
+}
+
+func textoActivo(activo bool) string {
+	if activo {
+		return "Activo"
+	}
+	return "Inactivo"
+}
+
+func renderizarTexto(w io.Writer, estado EstadoTaller) error {
+	fmt.Fprintln(w, "\n╔════════════════════════════════════════════════════╗")
+	fmt.Fprintln(w, "║           ESTADO ACTUAL DEL TALLER                 ║")
+	fmt.Fprintln(w, "╚════════════════════════════════════════════════════╝")
+
+	fmt.Fprintf(w, "\nTrabajos en cola principal: %d\n", len(estado.Cola))
+	for i, trabajo := range estado.Cola {
+		fmt.Fprintf(w, "  %d. Vehículo: %s | Incidencia: %s | Prioridad: %d\n",
+			i+1, trabajo.Matricula, trabajo.Tipo, trabajo.Prioridad)
+	}
+
+	fmt.Fprintln(w, "\nEstado de mecánicos:")
+	for _, mec := range estado.Mecanicos {
+		fmt.Fprintf(w, "  • %s (%s) - %s - Cola: %d/%d\n",
+			mec.Nombre, mec.Especialidad, textoActivo(mec.Activo), mec.Ocupacion, mec.Capacidad)
+
+		j := 1
+		if mec.EnCurso != nil {
+			fmt.Fprintf(w, "      %d. %s (%s) - en curso desde hace %v\n", j,
+				mec.EnCurso.Matricula, mec.EnCurso.Tipo, mec.EnCurso.Transcurrido.Round(time.Second))
+			j++
+		}
+		for _, tr := range mec.Cola {
+			fmt.Fprintf(w, "      %d. %s (%s)\n", j, tr.Matricula, tr.Tipo)
+			j++
+		}
+	}
+
+	_, err := fmt.Fprintln(w, "\n════════════════════════════════════════════════════════")
+	return err
+}
+
+func renderizarTabla(w io.Writer, estado EstadoTaller) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "COLA PRINCIPAL (%d)\n", len(estado.Cola))
+	fmt.Fprintln(tw, "#\tVEHÍCULO\tMATRÍCULA\tINCIDENCIA\tTIPO\tPRIORIDAD")
+	for i, tr := range estado.Cola {
+		fmt.Fprintf(tw, "%d\t%d\t%s\t%d\t%s\t%d\n",
+			i+1, tr.VehiculoID, tr.Matricula, tr.IncidenciaID, tr.Tipo, tr.Prioridad)
+	}
+
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "MECÁNICOS")
+	fmt.Fprintln(tw, "ID\tNOMBRE\tESPECIALIDAD\tESTADO\tOCUPACIÓN\tEN CURSO\tTRANSCURRIDO\tEN COLA")
+	for _, mec := range estado.Mecanicos {
+		enCurso, transcurrido := "-", "-"
+		if mec.EnCurso != nil {
+			enCurso = mec.EnCurso.Matricula
+			transcurrido = mec.EnCurso.Transcurrido.Round(time.Second).String()
+		}
+		enCola := "-"
+		for i, tr := range mec.Cola {
+			if i == 0 {
+				enCola = tr.Matricula
+			} else {
+				enCola += ", " + tr.Matricula
+			}
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%d/%d\t%s\t%s\t%s\n",
+			mec.ID, mec.Nombre, mec.Especialidad, textoActivo(mec.Activo),
+			mec.Ocupacion, mec.Capacidad, enCurso, transcurrido, enCola)
+	}
+	return tw.Flush()
+}
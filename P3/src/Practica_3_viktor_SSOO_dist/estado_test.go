@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestEstadoTaller comprueba la foto del taller y sus tres formatos de salida
+func TestEstadoTaller(t *testing.T) {
+	mm := NewMecanicoManager()
+	taller := NewTaller(mm, NewVehiculoManager(), NewIncidenciaManager())
+
+	mec := mm.CrearMecanico("Luis", EspMecanica, 5)
+	mm.CrearMecanico("Marta", EspElectrica, 2)
+
+	enCurso := &TrabajoMecanico{
+		Vehiculo:   VehiculoCompleto{ID: 1, Matricula: "1111AAA"},
+		Incidencia: IncidenciaCompleta{ID: 1, Tipo: Mecanica, Prioridad: PrioridadAlta},
+		Inicio:     time.Now().Add(-3 * time.Second),
+	}
+	enEspera := &TrabajoMecanico{
+		Vehiculo:   VehiculoCompleto{ID: 2, Matricula: "2222BBB"},
+		Incidencia: IncidenciaCompleta{ID: 2, Tipo: Mecanica, Prioridad: PrioridadBaja},
+	}
+	mec.ColaPersonal = append(mec.ColaPersonal, enCurso, enEspera)
+	taller.ColaTrabajo = append(taller.ColaTrabajo, &TrabajoMecanico{
+		Vehiculo:   VehiculoCompleto{ID: 3, Matricula: "3333CCC"},
+		Incidencia: IncidenciaCompleta{ID: 3, Tipo: Mecanica, Prioridad: PrioridadMedia},
+	})
+
+	estado := taller.Estado()
+	if len(estado.Cola) != 1 || estado.Cola[0].Matricula != "3333CCC" {
+		t.Fatalf("cola principal incorrecta: %+v", estado.Cola)
+	}
+	if len(estado.Mecanicos) != 2 || estado.Mecanicos[0].ID != 1 {
+		t.Fatalf("mecánicos incorrectos: %+v", estado.Mecanicos)
+	}
+	luis := estado.Mecanicos[0]
+	if luis.Ocupacion != 2 || luis.Capacidad != PlazasPorMecanico {
+		t.Fatalf("ocupación incorrecta: %d/%d", luis.Ocupacion, luis.Capacidad)
+	}
+	if luis.EnCurso == nil || luis.EnCurso.Matricula != "1111AAA" || luis.EnCurso.Transcurrido < 3*time.Second {
+		t.Fatalf("trabajo en curso incorrecto: %+v", luis.EnCurso)
+	}
+	if len(luis.Cola) != 1 || luis.Cola[0].Matricula != "2222BBB" {
+		t.Fatalf("cola personal incorrecta: %+v", luis.Cola)
+	}
+
+	for _, formato := range []FormatoEstado{FormatoTexto, FormatoTabla, FormatoJSON} {
+		var salida bytes.Buffer
+		if err := RenderizarEstado(&salida, estado, formato); err != nil {
+			t.Fatalf("%s: %v", formato, err)
+		}
+		for _, matricula := range []string{"1111AAA", "2222BBB", "3333CCC"} {
+			if !strings.Contains(salida.String(), matricula) {
+				t.Errorf("%s: falta %s en la salida:\n%s", formato, matricula, salida.String())
+			}
+		}
+		if formato == FormatoJSON {
+			var leido EstadoTaller
+			if err := json.Unmarshal(salida.Bytes(), &leido); err != nil {
+				t.Fatalf("JSON no válido: %v", err)
+			}
+		}
+	}
+
+	if err := RenderizarEstado(&bytes.Buffer{}, estado, "xml"); err == nil {
+		t.Fatal("se esperaba error con un formato desconocido")
+	}
+}
@@ -0,0 +1,636 @@
+package main
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// ============================================================================
+// ESTRATEGIAS DE CONCURRENCIA
+// ============================================================================
+
+// EstrategiaConcurrencia es una forma de organizar las goroutines que
+// recorren un pipeline. Todas reciben los mismos coches, los meten de la
+// misma manera y miden lo mismo, así que se pueden comparar entre sí.
+//
+// Simular termina cuando han salido todos los coches o cuando se cancela
+// ctx, y en ese caso devuelve el error de ctx. Si deja de avanzar durante
+// PlazoBloqueo aborta con un *ErrorBloqueo. Si salida no es nil se escribe
+// en ella el mismo registro que LogEstado.
+type EstrategiaConcurrencia interface {
+	Nombre() string
+	Simular(ctx context.Context, pipeline *Pipeline, vehiculos []*Vehiculo, reloj Reloj, salida io.Writer) (*ResultadoSimulacion, error)
+}
+
+// Estrategias devuelve todas las estrategias, ordenadas por nombre
+func Estrategias() []EstrategiaConcurrencia {
+	estrategias := []EstrategiaConcurrencia{
+		EstrategiaRWMutex{},
+		EstrategiaWaitGroup{},
+		EstrategiaCanales{},
+		EstrategiaCond{},
+		EstrategiaPool{},
+		EstrategiaErrgroup{},
+	}
+	sort.Slice(estrategias, func(i, j int) bool { return estrategias[i].Nombre() < estrategias[j].Nombre() })
+	return estrategias
+}
+
+// BuscarEstrategia devuelve la estrategia con ese nombre
+func BuscarEstrategia(nombre string) (EstrategiaConcurrencia, error) {
+	for _, estrategia := range Estrategias() {
+		if estrategia.Nombre() == nombre {
+			return estrategia, nil
+		}
+	}
+	return nil, fmt.Errorf("estrategia '%s' no encontrada", nombre)
+}
+
+// entornoSimulacion es lo que comparten todas las estrategias: los recursos
+// del taller, el registro de métricas, el reloj y dónde se escribe el
+// estado de los coches
+type entornoSimulacion struct {
+	pipeline *Pipeline
+	taller   *TallerSimulacion
+	registro *registroSimulacion
+	reloj    Reloj
+	inicio   time.Time
+
+	// mutex para que las líneas de varias goroutines no se mezclen
+	mutex  sync.Mutex
+	salida io.Writer
+
+	// total son los coches de la simulación y pendientes los que aún no han
+	// salido; al salir el último se apunta la duración y se cierra fin
+	mutexFin         sync.Mutex
+	total            int
+	pendientes       int
+	duracion         time.Duration
+	fin              chan struct{}
+	esperandoLlegada bool
+
+	// parada para al vigilante
+	parada chan struct{}
+}
+
+// nuevoEntornoSimulacion prepara el entorno y pone a vigilar que la
+// simulación avance. Las estrategias tienen que usar el contexto que
+// devuelve, que se cancela con un *ErrorBloqueo si se bloquea, y llamar a
+// parar al acabar.
+func nuevoEntornoSimulacion(ctx context.Context, pipeline *Pipeline, reloj Reloj, salida io.Writer) (*entornoSimulacion, context.Context) {
+	e := &entornoSimulacion{
+		pipeline: pipeline,
+		taller:   NewTallerSimulacionConRecursos(pipeline.Recursos),
+		registro: nuevoRegistroSimulacion(pipeline),
+		reloj:    reloj,
+		inicio:   reloj.Ahora(),
+		salida:   salida,
+		fin:      make(chan struct{}),
+		parada:   make(chan struct{}),
+	}
+	ctx, cancelar := context.WithCancelCause(ctx)
+	go e.vigilar(ctx, cancelar, e.parada)
+	return e, ctx
+}
+
+// parar deja de vigilar la simulación
+func (e *entornoSimulacion) parar() {
+	close(e.parada)
+}
+
+// ahora es el tiempo que lleva la simulación
+func (e *entornoSimulacion) ahora() time.Duration {
+	return e.reloj.Desde(e.inicio)
+}
+
+// ultimaFase dice si fase es la última del pipeline
+func (e *entornoSimulacion) ultimaFase(fase int) bool {
+	return fase == len(e.pipeline.Fases)-1
+}
+
+// introducir mete cada coche cuando llega según su TiempoLlegada, contando
+// desde el primero (los que llegan a la vez, en el orden de la lista); meter
+// se llama con cada uno después de apuntar su llegada. Para antes si se
+// cancela ctx.
+func (e *entornoSimulacion) introducir(ctx context.Context, vehiculos []*Vehiculo, meter func(*Vehiculo)) {
+	e.mutexFin.Lock()
+	e.total = len(vehiculos)
+	e.pendientes = len(vehiculos)
+	if e.pendientes == 0 {
+		close(e.fin)
+	}
+	e.mutexFin.Unlock()
+
+	llegadas, primero := llegadasEnOrden(vehiculos)
+	for _, v := range llegadas {
+		if espera := v.TiempoLlegada.Sub(primero) - e.ahora(); espera > 0 {
+			e.esperarLlegada(true)
+			select {
+			case <-e.reloj.Despues(espera):
+			case <-ctx.Done():
+			}
+			e.esperarLlegada(false)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		e.registro.llegar(v, e.ahora())
+		meter(v)
+	}
+}
+
+// esperarLlegada apunta si se está esperando al siguiente coche, para que el
+// vigilante no tome por un bloqueo las horas en que no llega nadie
+func (e *entornoSimulacion) esperarLlegada(esperando bool) {
+	e.mutexFin.Lock()
+	e.esperandoLlegada = esperando
+	e.mutexFin.Unlock()
+}
+
+// salir apunta que un coche ha terminado la última fase
+func (e *entornoSimulacion) salir() {
+	e.mutexFin.Lock()
+	defer e.mutexFin.Unlock()
+	e.pendientes--
+	if e.pendientes == 0 {
+		e.duracion = e.ahora()
+		close(e.fin)
+	}
+}
+
+// esperarFin espera a que salgan todos los coches y devuelve lo que duró la
+// simulación, o por qué se canceló ctx si se cancela antes
+func (e *entornoSimulacion) esperarFin(ctx context.Context) (time.Duration, error) {
+	select {
+	case <-e.fin:
+		e.mutexFin.Lock()
+		defer e.mutexFin.Unlock()
+		return e.duracion, nil
+	case <-ctx.Done():
+		return 0, context.Cause(ctx)
+	}
+}
+
+// procesar pasa v por la fase: coge sus recursos, trabaja, los suelta y, si
+// queda otra fase, apunta que el coche pasa a esperarla. Solo falla si se
+// cancela ctx mientras espera los recursos.
+func (e *entornoSimulacion) procesar(ctx context.Context, v *Vehiculo, fase int) error {
+	cogidos, err := e.taller.AdquirirAlguno(ctx, e.pipeline.alternativasFase(fase, v))
+	if err != nil {
+		return err
+	}
+	e.taller.Liberar(e.trabajar(v, fase, cogidos))
+	if !e.ultimaFase(fase) {
+		e.registro.encolar(v, fase+1, e.ahora())
+	}
+	return nil
+}
+
+// trabajar es la parte de procesar que no toca los recursos: apunta que v
+// tiene cogidos los recursos, lo hace esperar lo que dura la fase y devuelve
+// los recursos que suelta
+func (e *entornoSimulacion) trabajar(v *Vehiculo, fase int, cogidos []string) []string {
+	f := e.pipeline.Fases[fase]
+	e.registro.empezar(v, fase, cogidos, e.ahora())
+	e.registrar(v, fase, "Esperando")
+	e.registrar(v, fase, "En Proceso")
+	e.reloj.Dormir(f.duracion(v))
+	e.registrar(v, fase, "Completado")
+	return e.registro.terminar(v, fase, e.ahora())
+}
+
+func (e *entornoSimulacion) registrar(v *Vehiculo, fase int, estado string) {
+	if e.salida == nil {
+		return
+	}
+	e.mutex.Lock()
+	v.EscribirEstado(e.salida, e.pipeline.Fases[fase].Nombre, estado, e.ahora())
+	e.mutex.Unlock()
+}
+
+// ============================================
+// IMPLEMENTACIÓN CON CANALES
+// ============================================
+
+// EstrategiaCanales no comparte memoria: cada fase tiene un repartidor que
+// recibe los coches por un canal, los ordena por prioridad y se los pasa a
+// los trabajadores de la fase por otro. Cuando se cierra la entrada de una
+// fase y acaban sus trabajadores se cierra la entrada de la siguiente, así
+// que el fin de la simulación baja por el pipeline como en un pipeline de
+// canales cualquiera.
+type EstrategiaCanales struct{}
+
+func (EstrategiaCanales) Nombre() string { return "canales" }
+
+func (EstrategiaCanales) Simular(ctx context.Context, pipeline *Pipeline, vehiculos []*Vehiculo, reloj Reloj, salida io.Writer) (*ResultadoSimulacion, error) {
+	entorno, ctx := nuevoEntornoSimulacion(ctx, pipeline, reloj, salida)
+	defer entorno.parar()
+
+	entradas := make([]chan *Vehiculo, len(pipeline.Fases)+1)
+	for i := range entradas {
+		entradas[i] = make(chan *Vehiculo)
+	}
+	terminados := entradas[len(pipeline.Fases)]
+
+	for i, fase := range pipeline.Fases {
+		var wg sync.WaitGroup
+		listos := make(chan *Vehiculo)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			enrutarPorPrioridad(ctx, entradas[i], listos)
+		}()
+
+		// Pasa v a la fase siguiente; false si se ha cancelado
+		pasar := func(v *Vehiculo) bool {
+			if entorno.procesar(ctx, v, i) != nil {
+				return false
+			}
+			select {
+			case entradas[i+1] <- v:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		if fase.Trabajadores > 0 {
+			for j := 0; j < fase.Trabajadores; j++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for v := range listos {
+						if !pasar(v) {
+							return
+						}
+					}
+				}()
+			}
+		} else {
+			// Sin límite, una goroutine por coche
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for v := range listos {
+					wg.Add(1)
+					go func(v *Vehiculo) {
+						defer wg.Done()
+						pasar(v)
+					}(v)
+				}
+			}()
+		}
+
+		go func() {
+			wg.Wait()
+			close(entradas[i+1])
+		}()
+	}
+
+	go func() {
+		entorno.introducir(ctx, vehiculos, func(v *Vehiculo) {
+			select {
+			case entradas[0] <- v:
+			case <-ctx.Done():
+			}
+		})
+		close(entradas[0])
+	}()
+
+	var duracion time.Duration
+	completados := 0
+	for range terminados {
+		completados++
+		if completados == len(vehiculos) {
+			duracion = entorno.ahora()
+		}
+	}
+	if ctx.Err() != nil {
+		return nil, context.Cause(ctx)
+	}
+	return entorno.registro.resultado(duracion), nil
+}
+
+// enrutarPorPrioridad pasa los coches de entrada a salida atendiendo antes a
+// los de más prioridad de entre los que esperan. Cierra salida cuando se
+// cierra entrada y ya no queda nadie, o en cuanto se cancela ctx.
+func enrutarPorPrioridad(ctx context.Context, entrada <-chan *Vehiculo, salida chan<- *Vehiculo) {
+	defer close(salida)
+	var cola ColaPrioridad
+	for entrada != nil || cola.Len() > 0 {
+		// Con la cola vacía siguiente es nil y el select no intenta enviar
+		var siguiente chan<- *Vehiculo
+		var primero *Vehiculo
+		if cola.Len() > 0 {
+			siguiente = salida
+			primero = cola[0]
+		}
+		select {
+		case v, ok := <-entrada:
+			if !ok {
+				entrada = nil
+				continue
+			}
+			heap.Push(&cola, v)
+		case siguiente <- primero:
+			heap.Pop(&cola)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// ============================================
+// IMPLEMENTACIÓN CON VARIABLES DE CONDICIÓN
+// ============================================
+
+// EstrategiaCond usa variables de condición para todo: los trabajadores
+// duermen en la ColaBloqueante de su fase y la simulación duerme en otra
+// hasta que sale el último coche.
+type EstrategiaCond struct{}
+
+func (EstrategiaCond) Nombre() string { return "cond" }
+
+func (EstrategiaCond) Simular(ctx context.Context, pipeline *Pipeline, vehiculos []*Vehiculo, reloj Reloj, salida io.Writer) (*ResultadoSimulacion, error) {
+	entorno, ctx := nuevoEntornoSimulacion(ctx, pipeline, reloj, salida)
+	defer entorno.parar()
+
+	colas := make([]*ColaBloqueante, len(pipeline.Fases))
+	for i := range colas {
+		colas[i] = NewColaBloqueante()
+	}
+
+	var mutex sync.Mutex
+	fin := sync.NewCond(&mutex)
+	completados := 0
+	var duracion time.Duration
+
+	// Al cancelar se despierta a la simulación, que espera al final
+	parar := context.AfterFunc(ctx, func() {
+		mutex.Lock()
+		fin.Broadcast()
+		mutex.Unlock()
+	})
+	defer parar()
+
+	var wg sync.WaitGroup
+	for i, fase := range pipeline.Fases {
+		pasar := func(v *Vehiculo) {
+			if entorno.procesar(ctx, v, i) != nil {
+				return
+			}
+			if !entorno.ultimaFase(i) {
+				colas[i+1].Meter(v)
+				return
+			}
+			mutex.Lock()
+			completados++
+			if completados == len(vehiculos) {
+				duracion = entorno.ahora()
+				fin.Signal()
+			}
+			mutex.Unlock()
+		}
+
+		if fase.Trabajadores > 0 {
+			for j := 0; j < fase.Trabajadores; j++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for v, err := colas[i].Sacar(ctx); err == nil; v, err = colas[i].Sacar(ctx) {
+						pasar(v)
+					}
+				}()
+			}
+		} else {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for v, err := colas[i].Sacar(ctx); err == nil; v, err = colas[i].Sacar(ctx) {
+					wg.Add(1)
+					go func(v *Vehiculo) {
+						defer wg.Done()
+						pasar(v)
+					}(v)
+				}
+			}()
+		}
+	}
+
+	entorno.introducir(ctx, vehiculos, func(v *Vehiculo) { colas[0].Meter(v) })
+
+	mutex.Lock()
+	for completados < len(vehiculos) && ctx.Err() == nil {
+		fin.Wait()
+	}
+	mutex.Unlock()
+
+	for _, cola := range colas {
+		cola.Cerrar()
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		return nil, context.Cause(ctx)
+	}
+	return entorno.registro.resultado(duracion), nil
+}
+
+// ============================================
+// IMPLEMENTACIÓN CON POOL DE TRABAJADORES
+// ============================================
+
+// EstrategiaPool tiene un número fijo de trabajadores para todas las fases.
+// Una sola goroutine lleva las colas y los recursos (el mismo planificador
+// que la simulación por eventos) y reparte el trabajo por un canal, así que
+// nadie espera nunca en un semáforo. Tamano es el número de trabajadores; con
+// 0 son tantos como unidades de recursos tiene el pipeline, o uno por fase si
+// no tiene recursos. Con menos trabajadores que coches que pueden estar a la
+// vez en el taller, los coches esperan también a que haya un trabajador.
+type EstrategiaPool struct {
+	Tamano int
+}
+
+func (EstrategiaPool) Nombre() string { return "pool" }
+
+func (e EstrategiaPool) Simular(ctx context.Context, pipeline *Pipeline, vehiculos []*Vehiculo, reloj Reloj, salida io.Writer) (*ResultadoSimulacion, error) {
+	entorno, ctx := nuevoEntornoSimulacion(ctx, pipeline, reloj, salida)
+	defer entorno.parar()
+	tamano := e.tamano(pipeline)
+
+	type tarea struct {
+		vehiculo *Vehiculo
+		fase     int
+		// recursos son los cogidos al empezar y, hecha la tarea, los que
+		// se sueltan
+		recursos []string
+	}
+	llegadas := make(chan *Vehiculo)
+	// Caben todas las tareas en curso, así que ni el planificador ni los
+	// trabajadores se bloquean nunca al enviar
+	tareas := make(chan tarea, tamano)
+	hechas := make(chan tarea, tamano)
+
+	var wg sync.WaitGroup
+	for i := 0; i < tamano; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t := range tareas {
+				t.recursos = entorno.trabajar(t.vehiculo, t.fase, t.recursos)
+				hechas <- t
+			}
+		}()
+	}
+
+	introducidos := make(chan struct{})
+	go func() {
+		defer close(introducidos)
+		entorno.introducir(ctx, vehiculos, func(v *Vehiculo) {
+			select {
+			case llegadas <- v:
+			case <-ctx.Done():
+			}
+		})
+	}()
+
+	plan := nuevoPlanificador(pipeline)
+	ocupados := 0
+	completados := 0
+	var duracion time.Duration
+	for completados < len(vehiculos) && ctx.Err() == nil {
+		select {
+		case v := <-llegadas:
+			plan.encolar(v, 0)
+		case t := <-hechas:
+			ocupados--
+			plan.soltar(t.fase, t.recursos)
+			if entorno.ultimaFase(t.fase) {
+				completados++
+				duracion = entorno.ahora()
+			} else {
+				entorno.registro.encolar(t.vehiculo, t.fase+1, entorno.ahora())
+				plan.encolar(t.vehiculo, t.fase+1)
+			}
+		case <-ctx.Done():
+		}
+
+		for ocupados < tamano {
+			v, fase, cogidos, ok := plan.siguiente()
+			if !ok {
+				break
+			}
+			ocupados++
+			tareas <- tarea{v, fase, cogidos}
+		}
+	}
+
+	<-introducidos
+	close(tareas)
+	wg.Wait()
+	if ctx.Err() != nil {
+		return nil, context.Cause(ctx)
+	}
+	return entorno.registro.resultado(duracion), nil
+}
+
+func (e EstrategiaPool) tamano(pipeline *Pipeline) int {
+	if e.Tamano > 0 {
+		return e.Tamano
+	}
+	tamano := 0
+	for _, capacidad := range pipeline.Recursos {
+		tamano += capacidad
+	}
+	if tamano == 0 {
+		tamano = len(pipeline.Fases)
+	}
+	return tamano
+}
+
+// ============================================
+// IMPLEMENTACIÓN CON ERRGROUP
+// ============================================
+
+// EstrategiaErrgroup monta el mismo pipeline de canales que EstrategiaCanales
+// pero con errgroup: cada fase lanza una goroutine por coche con un límite
+// igual a sus trabajadores, y el primer error (una cancelación) para todas
+// las fases.
+type EstrategiaErrgroup struct{}
+
+func (EstrategiaErrgroup) Nombre() string { return "errgroup" }
+
+func (EstrategiaErrgroup) Simular(ctx context.Context, pipeline *Pipeline, vehiculos []*Vehiculo, reloj Reloj, salida io.Writer) (*ResultadoSimulacion, error) {
+	entorno, ctx := nuevoEntornoSimulacion(ctx, pipeline, reloj, salida)
+	defer entorno.parar()
+	g, gctx := errgroup.WithContext(ctx)
+
+	entradas := make([]chan *Vehiculo, len(pipeline.Fases)+1)
+	for i := range entradas {
+		entradas[i] = make(chan *Vehiculo)
+	}
+	terminados := entradas[len(pipeline.Fases)]
+
+	for i, fase := range pipeline.Fases {
+		listos := make(chan *Vehiculo)
+		g.Go(func() error {
+			enrutarPorPrioridad(gctx, entradas[i], listos)
+			return nil
+		})
+		g.Go(func() error {
+			defer close(entradas[i+1])
+			var fg errgroup.Group
+			if fase.Trabajadores > 0 {
+				fg.SetLimit(fase.Trabajadores)
+			}
+			for v := range listos {
+				fg.Go(func() error {
+					if err := entorno.procesar(gctx, v, i); err != nil {
+						return err
+					}
+					select {
+					case entradas[i+1] <- v:
+						return nil
+					case <-gctx.Done():
+						return gctx.Err()
+					}
+				})
+			}
+			return fg.Wait()
+		})
+	}
+
+	g.Go(func() error {
+		defer close(entradas[0])
+		entorno.introducir(gctx, vehiculos, func(v *Vehiculo) {
+			select {
+			case entradas[0] <- v:
+			case <-gctx.Done():
+			}
+		})
+		return gctx.Err()
+	})
+
+	var duracion time.Duration
+	completados := 0
+	for range terminados {
+		completados++
+		if completados == len(vehiculos) {
+			duracion = entorno.ahora()
+		}
+	}
+	err := g.Wait()
+	if ctx.Err() != nil {
+		return nil, context.Cause(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return entorno.registro.resultado(duracion), nil
+}
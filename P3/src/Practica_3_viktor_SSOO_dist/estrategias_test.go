@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestEstrategias corre todas las estrategias sobre el mismo pipeline, con
+// una fase de un solo trabajador, y comprueba que pasan todos los coches por
+// todas las fases
+func TestEstrategias(t *testing.T) {
+	pipeline := PipelineTaller(3, 2).ConRecurso("elevadores", 1)
+	pipeline.Fases = append(pipeline.Fases[:1], append([]Fase{
+		{Nombre: "Diagnóstico", Adquiere: []string{"elevadores"}, Libera: []string{"elevadores"}, Trabajadores: 1},
+	}, pipeline.Fases[1:]...)...)
+
+	for _, estrategia := range Estrategias() {
+		t.Run(estrategia.Nombre(), func(t *testing.T) {
+			reloj := NewRelojVirtual(time.Now())
+			parar := reloj.Automatico(50 * time.Microsecond)
+			defer parar()
+
+			resultado, err := estrategia.Simular(context.Background(), pipeline, generarVehiculos(3, 3, 3), reloj, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resultado.Global.Vehiculos != 9 || resultado.Duracion <= 0 {
+				t.Fatalf("resultado inesperado: %+v", resultado)
+			}
+			for _, fase := range resultado.Fases {
+				if fase.Atendidos != 9 {
+					t.Errorf("la fase %s atendió %d coches", fase.Fase, fase.Atendidos)
+				}
+			}
+			// Nunca hay más coches dentro que plazas
+			if resultado.Utilizacion[RecursoPlazas] > 1 {
+				t.Errorf("utilización de plazas %.2f", resultado.Utilizacion[RecursoPlazas])
+			}
+		})
+	}
+}
+
+// TestEstrategiasFaseSinLimite comprueba que en una fase sin límite de
+// trabajadores todas las estrategias atienden a la vez a los coches que
+// caben
+func TestEstrategiasFaseSinLimite(t *testing.T) {
+	pipeline := NewPipeline().
+		ConRecurso(RecursoPlazas, 3).
+		ConFase(Fase{Nombre: "Lavado", Adquiere: []string{RecursoPlazas}, Libera: []string{RecursoPlazas}})
+	vehiculos := generarVehiculos(3, 0, 0)
+	for _, v := range vehiculos {
+		v.TiempoLlegada = vehiculos[0].TiempoLlegada
+	}
+
+	for _, estrategia := range Estrategias() {
+		t.Run(estrategia.Nombre(), func(t *testing.T) {
+			reloj := NewRelojVirtual(time.Now())
+			parar := reloj.Automatico(50 * time.Microsecond)
+			defer parar()
+
+			resultado, err := estrategia.Simular(context.Background(), pipeline, vehiculos, reloj, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Uno detrás de otro serían 15s
+			if duracion := time.Duration(resultado.Duracion); duracion >= 10*time.Second {
+				t.Errorf("la simulación duró %v, se esperaban unos 5s", duracion)
+			}
+		})
+	}
+}
+
+// TestEstrategiasEspecialistas comprueba que todas las estrategias reparten
+// los coches entre los mecánicos de su especialidad y los polivalentes
+func TestEstrategiasEspecialistas(t *testing.T) {
+	pipeline := PipelineTallerEspecialistas(4, map[Especialidad]int{EspMecanica: 1, EspElectrica: 1}, 1)
+
+	for _, estrategia := range Estrategias() {
+		t.Run(estrategia.Nombre(), func(t *testing.T) {
+			reloj := NewRelojVirtual(time.Now())
+			parar := reloj.Automatico(50 * time.Microsecond)
+			defer parar()
+
+			resultado, err := estrategia.Simular(context.Background(), pipeline, generarVehiculos(3, 3, 3), reloj, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resultado.Global.Vehiculos != 9 {
+				t.Fatalf("resultado inesperado: %+v", resultado)
+			}
+			// La carrocería solo la sabe reparar el polivalente
+			if resultado.Utilizacion[RecursoPolivalentes] <= 0 {
+				t.Errorf("el polivalente no trabajó: %v", resultado.Utilizacion)
+			}
+			for recurso, utilizacion := range resultado.Utilizacion {
+				if utilizacion > 1 {
+					t.Errorf("utilización de %s %.2f", recurso, utilizacion)
+				}
+			}
+		})
+	}
+}
+
+// TestEstrategiasCanceladas comprueba que todas paran y devuelven el error
+// del contexto si se cancela a mitad
+func TestEstrategiasCanceladas(t *testing.T) {
+	for _, estrategia := range Estrategias() {
+		t.Run(estrategia.Nombre(), func(t *testing.T) {
+			reloj := NewRelojVirtual(time.Now())
+			parar := reloj.Automatico(50 * time.Microsecond)
+			defer parar()
+
+			ctx, cancelar := context.WithCancel(context.Background())
+			go func() {
+				reloj.Dormir(time.Minute)
+				cancelar()
+			}()
+			_, err := estrategia.Simular(ctx, PipelineTaller(1, 1), generarVehiculos(10, 10, 10), reloj, nil)
+			if err != context.Canceled {
+				t.Fatalf("se esperaba context.Canceled y se obtuvo %v", err)
+			}
+		})
+	}
+}
+
+func TestBuscarEstrategia(t *testing.T) {
+	for _, estrategia := range Estrategias() {
+		if encontrada, err := BuscarEstrategia(estrategia.Nombre()); err != nil || encontrada.Nombre() != estrategia.Nombre() {
+			t.Errorf("%s: %v", estrategia.Nombre(), err)
+		}
+	}
+	if _, err := BuscarEstrategia("hilos"); err == nil {
+		t.Error("se esperaba un error")
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestBusEventos comprueba el filtrado por tipo, el orden de entrega y que
+// Cerrar espera a que se procesen los eventos pendientes
+func TestBusEventos(t *testing.T) {
+	bus := NewBusEventos()
+
+	var mutex sync.Mutex
+	var todos, terminados []Evento
+	bus.Suscribir(func(e Evento) {
+		time.Sleep(time.Millisecond) // suscriptor lento
+		mutex.Lock()
+		todos = append(todos, e)
+		mutex.Unlock()
+	})
+	bus.Suscribir(func(e Evento) {
+		mutex.Lock()
+		terminados = append(terminados, e)
+		mutex.Unlock()
+	}, TrabajoTerminado)
+	cancelada := 0
+	cancelar := bus.Suscribir(func(e Evento) { cancelada++ })
+	cancelar()
+	bus.Suscribir(func(e Evento) { panic("fallo") })
+
+	for i := 1; i <= 20; i++ {
+		tipo := TrabajoIniciado
+		if i%2 == 0 {
+			tipo = TrabajoTerminado
+		}
+		bus.Publicar(Evento{Tipo: tipo, IncidenciaID: i})
+	}
+	bus.Cerrar()
+	bus.Publicar(Evento{Tipo: TrabajoTerminado, IncidenciaID: 99})
+
+	if len(todos) != 20 {
+		t.Fatalf("se esperaban 20 eventos, llegaron %d", len(todos))
+	}
+	for i, e := range todos {
+		if e.IncidenciaID != i+1 || e.Instante.IsZero() {
+			t.Fatalf("evento %d fuera de orden: %+v", i, e)
+		}
+	}
+	if len(terminados) != 10 {
+		t.Fatalf("el filtro por tipo dejó pasar %d eventos", len(terminados))
+	}
+	if cancelada != 0 {
+		t.Fatalf("una suscripción cancelada recibió %d eventos", cancelada)
+	}
+}
+
+// TestEventosTaller comprueba que los managers y el taller publican en el bus
+func TestEventosTaller(t *testing.T) {
+	mm := NewMecanicoManager()
+	vm := NewVehiculoManager()
+	im := NewIncidenciaManager()
+	taller := NewTaller(mm, vm, im)
+
+	var auditoria bytes.Buffer
+	RegistrarAuditoria(taller.Eventos(), &auditoria)
+
+	mec := mm.CrearMecanico("Luis", EspMecanica, 5)
+	mm.CambiarEstadoActivo(mec.ID, false)
+	mm.CambiarEstadoActivo(mec.ID, true)
+	incidencia := im.CrearIncidencia(Mecanica, PrioridadAlta, "Ruido", 1)
+	im.CambiarEstado(incidencia.ID, EnProceso)
+	taller.AgregarTrabajo(&VehiculoCompleto{ID: 1, Matricula: "1234ABC"}, incidencia)
+	taller.AsignarTrabajosAutomaticamente()
+	taller.Eventos().Cerrar()
+
+	esperados := []TipoEvento{MecanicoContratado, MecanicoDadoDeBaja, MecanicoDadoDeAlta,
+		IncidenciaCreada, IncidenciaEstadoCambiado, TrabajoEncolado, TrabajoAsignado}
+	decoder := json.NewDecoder(&auditoria)
+	for _, tipo := range esperados {
+		var e Evento
+		if err := decoder.Decode(&e); err != nil {
+			t.Fatalf("faltan eventos en la auditoría, se esperaba %s: %v", tipo, err)
+		}
+		if e.Tipo != tipo {
+			t.Fatalf("se esperaba %s y llegó %s", tipo, e.Tipo)
+		}
+		if tipo == TrabajoAsignado && (e.MecanicoID != mec.ID || e.IncidenciaID != incidencia.ID) {
+			t.Fatalf("asignación incorrecta: %+v", e)
+		}
+	}
+}
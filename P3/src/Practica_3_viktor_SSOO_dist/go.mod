@@ -1,3 +1,8 @@
 module P3
 
 go 1.22.2
+
+require (
+	golang.org/x/sync v0.9.0
+	gopkg.in/yaml.v3 v3.0.1
+)
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ============================================================================
+// LLEGADAS DE COCHES
+// ============================================================================
+
+// GeneradorLlegadas da los instantes en que llegan n coches, contados desde
+// el comienzo de la simulación y en orden. Lo aleatorio sale de rng, así que
+// con la misma semilla salen siempre las mismas llegadas.
+type GeneradorLlegadas interface {
+	Generar(n int, rng *rand.Rand) ([]time.Duration, error)
+}
+
+// GeneradorJuntas hace llegar a todos a la vez. Entre uno y otro hay un
+// nanosegundo para que las colas, que a igual prioridad atienden antes al
+// que llegó antes, respeten el orden.
+type GeneradorJuntas struct{}
+
+func (GeneradorJuntas) Generar(n int, rng *rand.Rand) ([]time.Duration, error) {
+	instantes := make([]time.Duration, n)
+	for i := range instantes {
+		instantes[i] = time.Duration(i) * time.Nanosecond
+	}
+	return instantes, nil
+}
+
+// GeneradorFijo hace llegar un coche cada Intervalo
+type GeneradorFijo struct {
+	Intervalo time.Duration
+}
+
+func (g GeneradorFijo) Generar(n int, rng *rand.Rand) ([]time.Duration, error) {
+	instantes := make([]time.Duration, n)
+	for i := range instantes {
+		instantes[i] = time.Duration(i) * g.Intervalo
+	}
+	return instantes, nil
+}
+
+// GeneradorPoisson es un proceso de Poisson con Intervalo de media entre
+// llegadas. El primero llega al empezar.
+type GeneradorPoisson struct {
+	Intervalo time.Duration
+}
+
+func (g GeneradorPoisson) Generar(n int, rng *rand.Rand) ([]time.Duration, error) {
+	instantes := make([]time.Duration, n)
+	var t time.Duration
+	for i := range instantes {
+		instantes[i] = t
+		t += time.Duration(rng.ExpFloat64() * float64(g.Intervalo))
+	}
+	return instantes, nil
+}
+
+// TramoPerfil es cuántos coches llegan por hora, de media, entre dos horas
+// del día
+type TramoPerfil struct {
+	Desde   Duracion `json:"desde" yaml:"desde"`
+	Hasta   Duracion `json:"hasta" yaml:"hasta"`
+	PorHora float64  `json:"por_hora" yaml:"por_hora"`
+}
+
+// PerfilTallerPorDefecto es un día de taller con la punta a primera hora,
+// cuando la gente deja el coche de camino al trabajo, y otra más pequeña por
+// la tarde
+func PerfilTallerPorDefecto() []TramoPerfil {
+	hora := func(h float64) Duracion { return Duracion(h * float64(time.Hour)) }
+	return []TramoPerfil{
+		{Desde: hora(8), Hasta: hora(10), PorHora: 10},
+		{Desde: hora(10), Hasta: hora(13), PorHora: 5},
+		{Desde: hora(13), Hasta: hora(15), PorHora: 2},
+		{Desde: hora(15), Hasta: hora(18), PorHora: 4},
+	}
+}
+
+// GeneradorPerfil es un proceso de Poisson cuyo ritmo cambia con la hora del
+// día según Tramos (ordenados y sin solaparse). Fuera de los tramos no llega
+// nadie; la simulación empieza cuando empieza el primero y, si hacen falta
+// más coches, sigue al día siguiente.
+type GeneradorPerfil struct {
+	Tramos []TramoPerfil
+}
+
+// Validar comprueba que los tramos caben en un día, van en orden y que en
+// alguno llega alguien
+func (g GeneradorPerfil) Validar() error {
+	alguien := false
+	for i, tramo := range g.Tramos {
+		if tramo.Desde < 0 || tramo.Hasta > Duracion(24*time.Hour) || tramo.Desde >= tramo.Hasta {
+			return fmt.Errorf("el tramo %v-%v no es válido", tramo.Desde, tramo.Hasta)
+		}
+		if i > 0 && tramo.Desde < g.Tramos[i-1].Hasta {
+			return fmt.Errorf("el tramo %v-%v se solapa con el anterior", tramo.Desde, tramo.Hasta)
+		}
+		if tramo.PorHora < 0 {
+			return fmt.Errorf("el tramo %v-%v no puede tener llegadas negativas", tramo.Desde, tramo.Hasta)
+		}
+		alguien = alguien || tramo.PorHora > 0
+	}
+	if !alguien {
+		return fmt.Errorf("el perfil no tiene llegadas")
+	}
+	return nil
+}
+
+func (g GeneradorPerfil) Generar(n int, rng *rand.Rand) ([]time.Duration, error) {
+	if err := g.Validar(); err != nil {
+		return nil, err
+	}
+	instantes := make([]time.Duration, 0, n)
+	apertura := time.Duration(g.Tramos[0].Desde)
+	for dia := time.Duration(0); len(instantes) < n; dia += 24 * time.Hour {
+		for _, tramo := range g.Tramos {
+			if tramo.PorHora == 0 {
+				continue
+			}
+			// Sin memoria: al cambiar de tramo se puede empezar de cero
+			media := float64(time.Hour) / tramo.PorHora
+			t := time.Duration(tramo.Desde) + time.Duration(rng.ExpFloat64()*media)
+			for t < time.Duration(tramo.Hasta) && len(instantes) < n {
+				instantes = append(instantes, dia+t-apertura)
+				t += time.Duration(rng.ExpFloat64() * media)
+			}
+		}
+	}
+	return instantes, nil
+}
+
+// GeneradorRafagas hace llegar a los coches en grupos: los grupos llegan como
+// un proceso de Poisson con Intervalo de media entre uno y otro, tienen de
+// media Tamano coches y dentro de cada grupo llega uno cada Separacion.
+type GeneradorRafagas struct {
+	Intervalo  time.Duration
+	Tamano     int
+	Separacion time.Duration
+}
+
+func (g GeneradorRafagas) Generar(n int, rng *rand.Rand) ([]time.Duration, error) {
+	if g.Tamano < 1 {
+		return nil, fmt.Errorf("las ráfagas necesitan al menos un coche")
+	}
+	instantes := make([]time.Duration, 0, n)
+	var inicio time.Duration
+	for len(instantes) < n {
+		// Entre 1 y 2*Tamano-1 coches, Tamano de media
+		tamano := 1 + rng.Intn(2*g.Tamano-1)
+		for i := 0; i < tamano && len(instantes) < n; i++ {
+			instantes = append(instantes, inicio+time.Duration(i)*g.Separacion)
+		}
+		// La siguiente ráfaga no empieza antes de que acabe esta
+		inicio += time.Duration(tamano-1)*g.Separacion + time.Duration(rng.ExpFloat64()*float64(g.Intervalo))
+	}
+	return instantes, nil
+}
+
+// GeneradorRegistro repite unas llegadas ya vistas, por ejemplo las de un día
+// real sacadas de un registro (CargarRegistroLlegadas)
+type GeneradorRegistro struct {
+	Instantes []time.Duration
+}
+
+func (g GeneradorRegistro) Generar(n int, rng *rand.Rand) ([]time.Duration, error) {
+	if n > len(g.Instantes) {
+		return nil, fmt.Errorf("el registro solo tiene %d llegadas y hacen falta %d", len(g.Instantes), n)
+	}
+	instantes := make([]time.Duration, n)
+	copy(instantes, g.Instantes)
+	sort.Slice(instantes, func(i, j int) bool { return instantes[i] < instantes[j] })
+	return instantes, nil
+}
+
+// CargarRegistroLlegadas lee las llegadas de un archivo y las cuenta desde la
+// primera. Vale el CSV por coche de una simulación anterior (columna
+// llegada_s) o un archivo con una llegada por línea, que puede ser una fecha
+// y hora ("2024-03-04 08:15:00" o RFC 3339), una hora del día ("08:15") o un
+// tiempo desde el comienzo ("1h30m" o segundos). De cada línea se mira lo que
+// hay antes de la primera coma, y se saltan las vacías y las que empiezan
+// por #.
+func CargarRegistroLlegadas(ruta string) (*GeneradorRegistro, error) {
+	archivo, err := os.Open(ruta)
+	if err != nil {
+		return nil, err
+	}
+	defer archivo.Close()
+
+	lector := bufio.NewReader(archivo)
+	primera, _ := lector.Peek(256)
+	var instantes []time.Duration
+	if cabecera, _, _ := strings.Cut(string(primera), "\n"); strings.Contains(cabecera, "llegada_s") {
+		instantes, err = leerLlegadasCSV(lector)
+	} else {
+		instantes, err = leerLlegadasLineas(lector)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", ruta, err)
+	}
+	if len(instantes) == 0 {
+		return nil, fmt.Errorf("%s: no hay llegadas", ruta)
+	}
+
+	sort.Slice(instantes, func(i, j int) bool { return instantes[i] < instantes[j] })
+	for i := len(instantes) - 1; i >= 0; i-- {
+		instantes[i] -= instantes[0]
+	}
+	return &GeneradorRegistro{Instantes: instantes}, nil
+}
+
+func leerLlegadasCSV(lector *bufio.Reader) ([]time.Duration, error) {
+	registros, err := csv.NewReader(lector).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	columna := -1
+	for i, nombre := range registros[0] {
+		if nombre == "llegada_s" {
+			columna = i
+		}
+	}
+	var instantes []time.Duration
+	for linea, registro := range registros[1:] {
+		segundos, err := strconv.ParseFloat(registro[columna], 64)
+		if err != nil {
+			return nil, fmt.Errorf("línea %d: llegada '%s' no válida", linea+2, registro[columna])
+		}
+		instantes = append(instantes, time.Duration(segundos*float64(time.Second)))
+	}
+	return instantes, nil
+}
+
+func leerLlegadasLineas(lector *bufio.Reader) ([]time.Duration, error) {
+	var instantes []time.Duration
+	escaner := bufio.NewScanner(lector)
+	for linea := 1; escaner.Scan(); linea++ {
+		texto, _, _ := strings.Cut(escaner.Text(), ",")
+		texto = strings.TrimSpace(texto)
+		if texto == "" || strings.HasPrefix(texto, "#") {
+			continue
+		}
+		instante, ok := parsearInstanteLlegada(texto)
+		if !ok {
+			return nil, fmt.Errorf("línea %d: llegada '%s' no válida", linea, texto)
+		}
+		instantes = append(instantes, instante)
+	}
+	return instantes, escaner.Err()
+}
+
+// parsearInstanteLlegada convierte una fecha, una hora o un tiempo en un
+// instante que solo sirve para compararlo con los del mismo archivo
+func parsearInstanteLlegada(texto string) (time.Duration, bool) {
+	origen := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, formato := range []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02 15:04"} {
+		if t, err := time.Parse(formato, texto); err == nil {
+			return t.Sub(origen), true
+		}
+	}
+	for _, formato := range []string{"15:04:05", "15:04"} {
+		if t, err := time.Parse(formato, texto); err == nil {
+			return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second, true
+		}
+	}
+	if d, err := time.ParseDuration(texto); err == nil {
+		return d, true
+	}
+	if segundos, err := strconv.ParseFloat(texto, 64); err == nil {
+		return time.Duration(segundos * float64(time.Second)), true
+	}
+	return 0, false
+}
+
+// llegadasEnOrden ordena los coches por TiempoLlegada (los que llegan a la
+// vez, en el orden de la lista) y dice cuándo llega el primero
+func llegadasEnOrden(vehiculos []*Vehiculo) ([]*Vehiculo, time.Time) {
+	llegadas := make([]*Vehiculo, len(vehiculos))
+	copy(llegadas, vehiculos)
+	sort.SliceStable(llegadas, func(i, j int) bool {
+		return llegadas[i].TiempoLlegada.Before(llegadas[j].TiempoLlegada)
+	})
+	if len(llegadas) == 0 {
+		return llegadas, time.Time{}
+	}
+	return llegadas, llegadas[0].TiempoLlegada
+}
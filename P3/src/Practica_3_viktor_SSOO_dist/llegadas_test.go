@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestGeneradorPoisson(t *testing.T) {
+	instantes, _ := GeneradorPoisson{Intervalo: time.Minute}.Generar(10000, rand.New(rand.NewSource(1)))
+	media := instantes[len(instantes)-1] / time.Duration(len(instantes)-1)
+	if media < 55*time.Second || media > 65*time.Second {
+		t.Fatalf("intervalo medio %v, se esperaba alrededor de 1m", media)
+	}
+}
+
+// TestGeneradorPerfil comprueba que fuera de los tramos no llega nadie y que
+// en la punta llegan más
+func TestGeneradorPerfil(t *testing.T) {
+	generador := GeneradorPerfil{Tramos: PerfilTallerPorDefecto()}
+	instantes, err := generador.Generar(2000, rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	porHora := make(map[int]int)
+	for i, instante := range instantes {
+		if i > 0 && instante < instantes[i-1] {
+			t.Fatalf("las llegadas no van en orden: %v antes de %v", instantes[i-1], instante)
+		}
+		// La simulación empieza a las 8
+		hora := int((instante+8*time.Hour)%(24*time.Hour)) / int(time.Hour)
+		porHora[hora]++
+	}
+	for hora := range porHora {
+		if hora < 8 || hora >= 18 {
+			t.Errorf("llegaron coches a las %d", hora)
+		}
+	}
+	if porHora[8] <= 2*porHora[13] {
+		t.Errorf("la punta de las 8 (%d) no se nota frente a las 13 (%d)", porHora[8], porHora[13])
+	}
+
+	solapados := GeneradorPerfil{Tramos: []TramoPerfil{
+		{Desde: Duracion(8 * time.Hour), Hasta: Duracion(12 * time.Hour), PorHora: 1},
+		{Desde: Duracion(11 * time.Hour), Hasta: Duracion(14 * time.Hour), PorHora: 1},
+	}}
+	if solapados.Validar() == nil {
+		t.Error("se esperaba un error con tramos solapados")
+	}
+}
+
+func TestGeneradorRafagas(t *testing.T) {
+	generador := GeneradorRafagas{Intervalo: time.Hour, Tamano: 3, Separacion: time.Minute}
+	instantes, _ := generador.Generar(3000, rand.New(rand.NewSource(1)))
+	rafagas := 1
+	for i := 1; i < len(instantes); i++ {
+		if instantes[i] < instantes[i-1] {
+			t.Fatalf("las llegadas no van en orden")
+		}
+		if instantes[i]-instantes[i-1] != time.Minute {
+			rafagas++
+		}
+	}
+	// Alguna ráfaga puede empezar justo un minuto después de la anterior,
+	// pero casi nunca
+	if media := float64(len(instantes)) / float64(rafagas); media < 2.8 || media > 3.2 {
+		t.Fatalf("%.2f coches por ráfaga de media, se esperaban 3", media)
+	}
+}
+
+// TestCargarRegistroLlegadas lee un archivo de horas y el CSV de una
+// simulación anterior
+func TestCargarRegistroLlegadas(t *testing.T) {
+	generador, err := CargarRegistroLlegadas(filepath.Join("escenarios", "registros", "lunes.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(generador.Instantes) != 15 || generador.Instantes[0] != 0 || generador.Instantes[1] != 3*time.Minute+31*time.Second {
+		t.Fatalf("llegadas inesperadas: %v", generador.Instantes)
+	}
+
+	dir := t.TempDir()
+	horas := filepath.Join(dir, "horas.txt")
+	os.WriteFile(horas, []byte("09:30\n08:00\n\n# comentario\n08:45:30\n"), 0644)
+	generador, err = CargarRegistroLlegadas(horas)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(generador.Instantes) != 3 || generador.Instantes[2] != 90*time.Minute {
+		t.Fatalf("llegadas inesperadas: %v", generador.Instantes)
+	}
+
+	var datos bytes.Buffer
+	anterior, err := SimularTallerEventos(generarVehiculos(2, 2, 2), 1, 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	anterior.EscribirCSV(&datos)
+	csv := filepath.Join(dir, "coches.csv")
+	os.WriteFile(csv, datos.Bytes(), 0644)
+	generador, err = CargarRegistroLlegadas(csv)
+	if err != nil || len(generador.Instantes) != 6 {
+		t.Fatalf("no se leyó el CSV de la simulación: %v %v", generador, err)
+	}
+	if _, err := generador.Generar(7, nil); err == nil {
+		t.Fatal("se esperaba un error al pedir más llegadas de las que hay")
+	}
+
+	malo := filepath.Join(dir, "malo.txt")
+	os.WriteFile(malo, []byte("08:00\nmañana\n"), 0644)
+	if _, err := CargarRegistroLlegadas(malo); err == nil {
+		t.Fatal("se esperaba un error con una llegada no válida")
+	}
+}
+
+// TestLlegadasConGoroutines comprueba que las estrategias con goroutines
+// meten a cada coche cuando llega
+func TestLlegadasConGoroutines(t *testing.T) {
+	vehiculos := generarVehiculos(2, 2, 2)
+	inicio := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+	for i, v := range vehiculos {
+		v.TiempoLlegada = inicio.Add(time.Duration(i) * 10 * time.Minute)
+	}
+
+	for _, estrategia := range Estrategias() {
+		reloj := NewRelojVirtual(time.Now())
+		parar := reloj.Automatico(50 * time.Microsecond)
+		resultado, err := estrategia.Simular(context.Background(), PipelineTaller(1, 1), vehiculos, reloj, nil)
+		parar()
+		if err != nil {
+			t.Fatalf("%s: %v", estrategia.Nombre(), err)
+		}
+		for i, v := range resultado.Vehiculos {
+			if llegada := time.Duration(v.Llegada).Round(time.Second); llegada != time.Duration(i)*10*time.Minute {
+				t.Errorf("%s: el coche %d llegó a los %v", estrategia.Nombre(), v.ID, llegada)
+			}
+		}
+		if resultado.Duracion < Duracion(50*time.Minute) {
+			t.Errorf("%s: duró %v", estrategia.Nombre(), resultado.Duracion)
+		}
+	}
+}
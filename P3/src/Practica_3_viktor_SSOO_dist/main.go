@@ -2,8 +2,14 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 )
 
@@ -19,6 +25,7 @@ func (cm *ClienteManager) CrearCliente(nombre, telefono, email string) *Cliente
 	}
 	cm.clientes[cm.nextID] = cliente
 	cm.nextID++
+	avisarErrorPersistencia(guardarEnRepositorio(cm.repo, cliente.ID, cliente))
 	return cliente
 }
 
@@ -51,7 +58,19 @@ func (cm *ClienteManager) ActualizarCliente(id int, nombre, telefono, email stri
 	if email != "" {
 		cliente.Email = email
 	}
-	return nil
+	return guardarEnRepositorio(cm.repo, id, cliente)
+}
+
+func (cm *ClienteManager) CambiarPreferencia(id int, canal CanalNotificacion) error {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+
+	cliente, existe := cm.clientes[id]
+	if !existe {
+		return fmt.Errorf("cliente no encontrado")
+	}
+	cliente.Notificar = canal
+	return guardarEnRepositorio(cm.repo, id, cliente)
 }
 
 func (cm *ClienteManager) EliminarCliente(id int) error {
@@ -62,7 +81,7 @@ func (cm *ClienteManager) EliminarCliente(id int) error {
 		return fmt.Errorf("cliente no encontrado")
 	}
 	delete(cm.clientes, id)
-	return nil
+	return cm.repo.Eliminar(id)
 }
 
 func (cm *ClienteManager) ExisteCliente(id int) bool {
@@ -72,6 +91,13 @@ func (cm *ClienteManager) ExisteCliente(id int) bool {
 	return existe
 }
 
+func (cm *ClienteManager) ObtenerCliente(id int) (*Cliente, bool) {
+	cm.mutex.RLock()
+	defer cm.mutex.RUnlock()
+	c, existe := cm.clientes[id]
+	return c, existe
+}
+
 func (vm *VehiculoManager) CrearVehiculo(matricula, marca, modelo string, clienteID int, cm *ClienteManager) (*VehiculoCompleto, error) {
 	if !cm.ExisteCliente(clienteID) {
 		return nil, fmt.Errorf("el cliente con ID %d no existe", clienteID)
@@ -87,6 +113,9 @@ func (vm *VehiculoManager) CrearVehiculo(matricula, marca, modelo string, client
 		Modelo:    modelo,
 		ClienteID: clienteID,
 	}
+	if err := guardarEnRepositorio(vm.repo, vehiculo.ID, vehiculo); err != nil {
+		return nil, err
+	}
 	vm.vehiculos[vm.nextID] = vehiculo
 	vm.nextID++
 	return vehiculo, nil
@@ -141,7 +170,7 @@ func (vm *VehiculoManager) ActualizarVehiculo(id int, matricula, marca, modelo s
 	if modelo != "" {
 		vehiculo.Modelo = modelo
 	}
-	return nil
+	return guardarEnRepositorio(vm.repo, id, vehiculo)
 }
 
 func (vm *VehiculoManager) EliminarVehiculo(id int) error {
@@ -152,7 +181,7 @@ func (vm *VehiculoManager) EliminarVehiculo(id int) error {
 		return fmt.Errorf("vehículo no encontrado")
 	}
 	delete(vm.vehiculos, id)
-	return nil
+	return vm.repo.Eliminar(id)
 }
 
 func (vm *VehiculoManager) AgregarTiempo(id int, tiempo float64) {
@@ -160,6 +189,7 @@ func (vm *VehiculoManager) AgregarTiempo(id int, tiempo float64) {
 	defer vm.mutex.Unlock()
 	if v, existe := vm.vehiculos[id]; existe {
 		v.TiempoAcumulado += tiempo
+		avisarErrorPersistencia(guardarEnRepositorio(vm.repo, id, v))
 	}
 }
 
@@ -177,6 +207,8 @@ func (im *IncidenciaManager) CrearIncidencia(tipo TipoIncidencia, prioridad Prio
 	}
 	im.incidencias[im.nextID] = incidencia
 	im.nextID++
+	avisarErrorPersistencia(guardarEnRepositorio(im.repo, incidencia.ID, incidencia))
+	im.eventos.Publicar(Evento{Tipo: IncidenciaCreada, IncidenciaID: incidencia.ID, VehiculoID: vehiculoID, Estado: Abierta})
 	return incidencia
 }
 
@@ -211,6 +243,27 @@ func (im *IncidenciaManager) ObtenerIncidenciasPorVehiculo(vehiculoID int) []*In
 	return incidencias
 }
 
+func (im *IncidenciaManager) ActualizarIncidencia(id int, tipo TipoIncidencia, prioridad Prioridad, descripcion string) error {
+	im.mutex.Lock()
+	defer im.mutex.Unlock()
+
+	incidencia, existe := im.incidencias[id]
+	if !existe {
+		return fmt.Errorf("incidencia no encontrada")
+	}
+
+	if tipo != "" {
+		incidencia.Tipo = tipo
+	}
+	if prioridad != 0 {
+		incidencia.Prioridad = prioridad
+	}
+	if descripcion != "" {
+		incidencia.Descripcion = descripcion
+	}
+	return guardarEnRepositorio(im.repo, id, incidencia)
+}
+
 func (im *IncidenciaManager) CambiarEstado(id int, estado EstadoIncidencia) error {
 	im.mutex.Lock()
 	defer im.mutex.Unlock()
@@ -219,7 +272,14 @@ func (im *IncidenciaManager) CambiarEstado(id int, estado EstadoIncidencia) erro
 	if !existe {
 		return fmt.Errorf("incidencia no encontrada")
 	}
+	anterior := incidencia.Estado
 	incidencia.Estado = estado
+	if err := guardarEnRepositorio(im.repo, id, incidencia); err != nil {
+		return err
+	}
+	if anterior != estado {
+		im.eventos.Publicar(Evento{Tipo: IncidenciaEstadoCambiado, IncidenciaID: id, VehiculoID: incidencia.VehiculoID, Estado: estado})
+	}
 	return nil
 }
 
@@ -231,7 +291,7 @@ func (im *IncidenciaManager) EliminarIncidencia(id int) error {
 		return fmt.Errorf("incidencia no encontrada")
 	}
 	delete(im.incidencias, id)
-	return nil
+	return im.repo.Eliminar(id)
 }
 
 func (im *IncidenciaManager) EliminarIncidenciasPorVehiculo(vehiculoID int) {
@@ -241,6 +301,7 @@ func (im *IncidenciaManager) EliminarIncidenciasPorVehiculo(vehiculoID int) {
 	for id, inc := range im.incidencias {
 		if inc.VehiculoID == vehiculoID {
 			delete(im.incidencias, id)
+			avisarErrorPersistencia(im.repo.Eliminar(id))
 		}
 	}
 }
@@ -256,21 +317,23 @@ func (im *IncidenciaManager) ContarTodasIncidencias() map[int]int {
 	return counts
 }
 
-func (mm *MecanicoManager) CrearMecanico(nombre string, especialidad Especialidad, experiencia int) Mecanico {
+func (mm *MecanicoManager) CrearMecanico(nombre string, especialidad Especialidad, experiencia int) *Mecanico {
 	mm.mutex.Lock()
 	defer mm.mutex.Unlock()
 
-	mecanico := Mecanico{
+	mecanico := &Mecanico{
 		ID:           mm.nextID,
 		Nombre:       nombre,
 		Especialidad: especialidad,
 		Experiencia:  experiencia,
 		Activo:       true,
-		ColaPersonal: make([]*TrabajoMecanico, 0, 2),
-		Canal:        make(chan *TrabajoMecanico, 2),
+		ColaPersonal: make([]*TrabajoMecanico, 0, PlazasPorMecanico),
+		Canal:        make(chan *TrabajoMecanico, PlazasPorMecanico),
 	}
-	mm.mecanicos[mm.nextID] = &mecanico
+	mm.mecanicos[mm.nextID] = mecanico
 	mm.nextID++
+	avisarErrorPersistencia(guardarEnRepositorio(mm.repo, mecanico.ID, mecanico.guardado()))
+	mm.eventos.Publicar(Evento{Tipo: MecanicoContratado, MecanicoID: mecanico.ID})
 	return mecanico
 }
 
@@ -300,23 +363,66 @@ func (mm *MecanicoManager) CambiarEstadoActivo(id int, activo bool) error {
 	if !existe {
 		return fmt.Errorf("mecánico no encontrado")
 	}
+	anterior := mecanico.Activo
 	mecanico.Activo = activo
+	if err := guardarEnRepositorio(mm.repo, id, mecanico.guardado()); err != nil {
+		return err
+	}
+	if anterior != activo {
+		tipo := MecanicoDadoDeBaja
+		if activo {
+			tipo = MecanicoDadoDeAlta
+		}
+		mm.eventos.Publicar(Evento{Tipo: tipo, MecanicoID: id})
+	}
 	return nil
 }
 
-func (t *Taller) AgregarTrabajo(vehiculo *VehiculoCompleto, incidencia *IncidenciaCompleta) {
+// ErrTrabajoEncolado indica que la incidencia ya está en el taller, en la
+// cola general o en la de algún mecánico
+var ErrTrabajoEncolado = errors.New("la incidencia ya está en el taller")
+
+func (t *Taller) AgregarTrabajo(vehiculo *VehiculoCompleto, incidencia *IncidenciaCompleta) error {
 	t.mutex.Lock()
 	defer t.mutex.Unlock()
 
+	if t.encolada(incidencia.ID) {
+		return ErrTrabajoEncolado
+	}
 	trabajo := &TrabajoMecanico{
 		Vehiculo:   *vehiculo,
 		Incidencia: *incidencia,
+		Llegada:    time.Now(),
 	}
 	t.ColaTrabajo = append(t.ColaTrabajo, trabajo)
+	t.eventos.Publicar(Evento{Tipo: TrabajoEncolado, IncidenciaID: incidencia.ID, VehiculoID: vehiculo.ID})
 	fmt.Printf("Trabajo agregado: Vehículo %s - Incidencia %s\n", vehiculo.Matricula, incidencia.Tipo)
 
 	// Intentar asignar inmediatamente
 	go t.AsignarTrabajosAutomaticamente()
+	return nil
+}
+
+// encolada dice si la incidencia espera en la cola general o está en la de
+// algún mecánico. Se llama con t.mutex cogido: AsignarTrabajosAutomaticamente
+// mueve los trabajos de una cola a otra con ese mismo cerrojo.
+func (t *Taller) encolada(incidenciaID int) bool {
+	for _, tr := range t.ColaTrabajo {
+		if tr.Incidencia.ID == incidenciaID {
+			return true
+		}
+	}
+	for _, mec := range t.MecanicoManager.ListarMecanicos() {
+		mec.mutex.Lock()
+		for _, tr := range mec.ColaPersonal {
+			if tr.Incidencia.ID == incidenciaID {
+				mec.mutex.Unlock()
+				return true
+			}
+		}
+		mec.mutex.Unlock()
+	}
+	return false
 }
 
 func (t *Taller) AsignarTrabajosAutomaticamente() {
@@ -329,6 +435,9 @@ func (t *Taller) AsignarTrabajosAutomaticamente() {
 
 	mecanicos := t.MecanicoManager.ListarMecanicos()
 
+	// Primero los más prioritarios; a igualdad, los que llevan más esperando
+	ordenarCola(t.ColaTrabajo, t.Envejecimiento)
+
 	for i := 0; i < len(t.ColaTrabajo); i++ {
 		trabajo := t.ColaTrabajo[i]
 		asignado := false
@@ -356,9 +465,15 @@ func (t *Taller) AsignarTrabajosAutomaticamente() {
 
 			// Verificar si tiene espacio en su cola
 			mec.mutex.Lock()
-			if len(mec.ColaPersonal) < 2 {
+			if len(mec.ColaPersonal) < PlazasPorMecanico {
 				mec.ColaPersonal = append(mec.ColaPersonal, trabajo)
 				fmt.Printf("Asignado a %s (especialidad: %s)\n", mec.Nombre, mec.Especialidad)
+				t.eventos.Publicar(Evento{
+					Tipo:         TrabajoAsignado,
+					IncidenciaID: trabajo.Incidencia.ID,
+					VehiculoID:   trabajo.Vehiculo.ID,
+					MecanicoID:   mec.ID,
+				})
 
 				// Enviar por canal
 				select {
@@ -378,7 +493,7 @@ func (t *Taller) AsignarTrabajosAutomaticamente() {
 		}
 
 		if !asignado {
-			break // No hay mecánicos disponibles, dejamos el resto en cola
+			continue // No hay hueco en su especialidad, puede haberlo en otra
 		}
 	}
 }
@@ -394,14 +509,22 @@ func (t *Taller) ArrancarRutinaMecanico(m *Mecanico) {
 
 		select {
 		case trabajo := <-m.Canal:
+			m.mutex.Lock()
+			trabajo.Inicio = time.Now()
+			m.mutex.Unlock()
+			t.eventos.Publicar(Evento{
+				Tipo:         TrabajoIniciado,
+				Instante:     trabajo.Inicio,
+				IncidenciaID: trabajo.Incidencia.ID,
+				VehiculoID:   trabajo.Vehiculo.ID,
+				MecanicoID:   m.ID,
+			})
+
 			fmt.Printf("%s comienza a trabajar en %s (%s)\n",
 				m.Nombre, trabajo.Vehiculo.Matricula, trabajo.Incidencia.Tipo)
 
-			// Simular trabajo (3-8 segundos según experiencia)
-			tiempoTrabajo := time.Duration(10-m.Experiencia) * time.Second
-			if tiempoTrabajo < 3*time.Second {
-				tiempoTrabajo = 3 * time.Second
-			}
+			// Simular trabajo según el tipo y la experiencia
+			tiempoTrabajo := t.Tiempos.TiempoReparacion(trabajo.Incidencia.Tipo, m.Experiencia)
 			time.Sleep(tiempoTrabajo)
 
 			// Actualizar tiempo acumulado del vehículo
@@ -421,6 +544,13 @@ func (t *Taller) ArrancarRutinaMecanico(m *Mecanico) {
 			m.mutex.Unlock()
 
 			fmt.Printf("%s terminó trabajo en %s\n", m.Nombre, trabajo.Vehiculo.Matricula)
+			t.eventos.Publicar(Evento{
+				Tipo:         TrabajoTerminado,
+				IncidenciaID: trabajo.Incidencia.ID,
+				VehiculoID:   trabajo.Vehiculo.ID,
+				MecanicoID:   m.ID,
+				Duracion:     tiempoTrabajo,
+			})
 
 			// Intentar asignar más trabajos
 			go t.AsignarTrabajosAutomaticamente()
@@ -432,60 +562,211 @@ func (t *Taller) ArrancarRutinaMecanico(m *Mecanico) {
 	}
 }
 
+// esperarSenalYApagar bloquea hasta recibir SIGINT o SIGTERM y después cierra
+// el servidor dejando terminar las peticiones en curso
+func esperarSenalYApagar(servidor *http.Server) {
+	fmt.Println("\nSin entrada estándar; la API REST sigue atendiendo hasta recibir SIGINT o SIGTERM")
+	senales := make(chan os.Signal, 1)
+	signal.Notify(senales, os.Interrupt, syscall.SIGTERM)
+	<-senales
+
+	ctx, cancelar := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelar()
+	if err := servidor.Shutdown(ctx); err != nil {
+		fmt.Printf("Error al cerrar la API REST: %v\n", err)
+	}
+}
+
+// ObtenerEstadoTaller muestra por pantalla el estado actual del taller
 func (t *Taller) ObtenerEstadoTaller() {
-	t.mutex.Lock()
-	defer t.mutex.Unlock()
+	RenderizarEstado(os.Stdout, t.Estado(), FormatoTexto)
+}
 
-	fmt.Println("\n╔════════════════════════════════════════════════════╗")
-	fmt.Println("║           ESTADO ACTUAL DEL TALLER                 ║")
-	fmt.Println("╚════════════════════════════════════════════════════╝")
+// abrirManagers crea los managers guardando sus datos en dir. Si dir está
+// vacío los datos solo se guardan en memoria.
+func abrirManagers(dir string) (*ClienteManager, *VehiculoManager, *IncidenciaManager, *MecanicoManager, []Repositorio, error) {
+	if dir == "" {
+		return NewClienteManager(), NewVehiculoManager(), NewIncidenciaManager(), NewMecanicoManager(), nil, nil
+	}
 
-	fmt.Printf("\nTrabajos en cola principal: %d\n", len(t.ColaTrabajo))
-	if len(t.ColaTrabajo) > 0 {
-		for i, trabajo := range t.ColaTrabajo {
-			fmt.Printf("  %d. Vehículo: %s | Incidencia: %s | Prioridad: %d\n",
-				i+1, trabajo.Vehiculo.Matricula, trabajo.Incidencia.Tipo, trabajo.Incidencia.Prioridad)
+	repos := make([]Repositorio, 0, 4)
+	abrir := func(nombre string) (Repositorio, error) {
+		repo, err := NewRepositorioArchivo(dir, nombre)
+		if err != nil {
+			return nil, err
 		}
+		repos = append(repos, repo)
+		return repo, nil
 	}
-
-	fmt.Println("\nEstado de mecánicos:")
-	mecanicos := t.MecanicoManager.ListarMecanicos()
-	for _, mec := range mecanicos {
-		estado := "Activo"
-		if !mec.Activo {
-			estado = "Inactivo"
+	fallo := func(err error) (*ClienteManager, *VehiculoManager, *IncidenciaManager, *MecanicoManager, []Repositorio, error) {
+		for _, repo := range repos {
+			repo.Cerrar()
 		}
-		fmt.Printf("  • %s (%s) - %s - Cola: %d/2\n",
-			mec.Nombre, mec.Especialidad, estado, len(mec.ColaPersonal))
+		return nil, nil, nil, nil, nil, err
+	}
 
-		if len(mec.ColaPersonal) > 0 {
-			for j, tr := range mec.ColaPersonal {
-				fmt.Printf("      %d. %s (%s)\n", j+1, tr.Vehiculo.Matricula, tr.Incidencia.Tipo)
-			}
-		}
+	repoClientes, err := abrir("clientes")
+	if err != nil {
+		return fallo(err)
+	}
+	cm, err := NewClienteManagerConRepositorio(repoClientes)
+	if err != nil {
+		return fallo(err)
 	}
 
-	fmt.Println("\n════════════════════════════════════════════════════════")
+	repoVehiculos, err := abrir("vehiculos")
+	if err != nil {
+		return fallo(err)
+	}
+	vm, err := NewVehiculoManagerConRepositorio(repoVehiculos)
+	if err != nil {
+		return fallo(err)
+	}
+
+	repoIncidencias, err := abrir("incidencias")
+	if err != nil {
+		return fallo(err)
+	}
+	im, err := NewIncidenciaManagerConRepositorio(repoIncidencias)
+	if err != nil {
+		return fallo(err)
+	}
+
+	repoMecanicos, err := abrir("mecanicos")
+	if err != nil {
+		return fallo(err)
+	}
+	mm, err := NewMecanicoManagerConRepositorio(repoMecanicos)
+	if err != nil {
+		return fallo(err)
+	}
+
+	return cm, vm, im, mm, repos, nil
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "simular":
+			os.Exit(comandoSimular(os.Args[2:]))
+		case "comparar":
+			os.Exit(comandoComparar(os.Args[2:]))
+		case "optimizar":
+			os.Exit(comandoOptimizar(os.Args[2:]))
+		}
+	}
+
+	dirDatos := flag.String("datos", "datos", "directorio donde se guardan los datos del taller (vacío para no guardarlos)")
+	dirHTTP := flag.String("http", "", "dirección donde servir la API REST, por ejemplo :8080 (vacío para no servirla)")
+	servidorSMTP := flag.String("smtp", "", "servidor SMTP (host:puerto) para avisar a los clientes por email")
+	remitente := flag.String("remitente", "taller@localhost", "dirección desde la que se envían los emails")
+	pasarelaSMS := flag.String("sms", "", "URL de la pasarela para avisar a los clientes por SMS")
+	tokenSMS := flag.String("sms-token", "", "token para la pasarela de SMS")
+	archivoBuzon := flag.String("buzon", "", "archivo donde dejar los avisos que no se pueden enviar por otro canal")
+	envejecimiento := flag.Duration("envejecimiento", EnvejecimientoDefecto, "espera con la que un trabajo sube un nivel de prioridad (0 para no envejecer)")
+	archivoAuditoria := flag.String("auditoria", "", "archivo donde registrar los eventos del taller (vacío para no registrarlos)")
+	archivoTiempos := flag.String("tiempos", "", "archivo JSON con el modelo de tiempos de reparación (vacío para los de siempre)")
+	semilla := flag.Int64("semilla", 0, "semilla de los tiempos aleatorios, para repetir una ejecución (0 para la del archivo o una nueva)")
+	flag.Int64Var(semilla, "seed", 0, "igual que -semilla")
+	flag.Parse()
+
 	scanner := bufio.NewScanner(os.Stdin)
 
 	// Inicializar managers
-	clienteManager := NewClienteManager()
-	vehiculoManager := NewVehiculoManager()
-	incidenciaManager := NewIncidenciaManager()
-	mecanicoManager := NewMecanicoManager()
+	clienteManager, vehiculoManager, incidenciaManager, mecanicoManager, repos, err := abrirManagers(*dirDatos)
+	if err != nil {
+		fmt.Printf("Error al cargar los datos: %v\n", err)
+		os.Exit(1)
+	}
+	defer func() {
+		for _, repo := range repos {
+			if err := repo.Cerrar(); err != nil {
+				fmt.Printf("Error al guardar los datos: %v\n", err)
+			}
+		}
+	}()
 	taller := NewTaller(mecanicoManager, vehiculoManager, incidenciaManager)
+	taller.Envejecimiento = *envejecimiento
+	if *archivoTiempos != "" {
+		tiempos, err := CargarModeloTiempos(*archivoTiempos)
+		if err != nil {
+			fmt.Printf("Error al cargar el modelo de tiempos: %v\n", err)
+			os.Exit(1)
+		}
+		taller.Tiempos = tiempos
+	}
+	if *semilla != 0 {
+		taller.Tiempos.Semilla = *semilla
+	}
+	fmt.Printf("Semilla de los tiempos: %d (para repetir: -semilla %[1]d)\n", taller.Tiempos.ElegirSemilla())
+
+	if *archivoAuditoria != "" {
+		archivo, err := os.OpenFile(*archivoAuditoria, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			fmt.Printf("Error al abrir el registro de auditoría: %v\n", err)
+			os.Exit(1)
+		}
+		defer archivo.Close()
+		RegistrarAuditoria(taller.Eventos(), archivo)
+	}
+	canales := map[CanalNotificacion]Notificador{}
+	if *servidorSMTP != "" {
+		canales[CanalEmail] = &NotificadorConReintentos{
+			Notificador: &NotificadorEmail{Servidor: *servidorSMTP, Remitente: *remitente},
+			Intentos:    3,
+			Espera:      time.Second,
+		}
+	}
+	if *pasarelaSMS != "" {
+		canales[CanalSMS] = &NotificadorConReintentos{
+			Notificador: &NotificadorSMS{URL: *pasarelaSMS, Token: *tokenSMS, Cliente: &http.Client{Timeout: 10 * time.Second}},
+			Intentos:    3,
+			Espera:      time.Second,
+		}
+	}
+	if *archivoBuzon != "" {
+		canales[CanalBuzon] = &NotificadorArchivo{Ruta: *archivoBuzon}
+	}
+	if len(canales) > 0 {
+		NewServicioNotificaciones(clienteManager, vehiculoManager, incidenciaManager, canales).Suscribir(taller.Eventos())
+	}
+
+	// Se cierra antes que el registro para que no se pierda ningún evento
+	defer taller.Eventos().Cerrar()
 
 	fmt.Println("╔═══════════════════════════════════════════════════╗")
 	fmt.Println("║     SISTEMA DE GESTIÓN DE TALLER MECÁNICO         ║")
 	fmt.Println("╚═══════════════════════════════════════════════════╝")
 
+	// Los mecánicos recuperados vuelven a trabajar
+	for _, mec := range mecanicoManager.ListarMecanicos() {
+		go taller.ArrancarRutinaMecanico(mec)
+	}
+
+	var servidor *http.Server
+	if *dirHTTP != "" {
+		api := NewServidorAPI(clienteManager, vehiculoManager, incidenciaManager, mecanicoManager, taller)
+		servidor = &http.Server{Addr: *dirHTTP, Handler: api.Handler()}
+		go func() {
+			if err := servidor.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				fmt.Printf("Error en la API REST: %v\n", err)
+			}
+		}()
+		defer servidor.Close()
+		fmt.Printf("API REST escuchando en %s\n", *dirHTTP)
+	}
+
 	for {
 		mostrarMenuPrincipal()
 		fmt.Print("Seleccione una opción: ")
-		scanner.Scan()
+		if !scanner.Scan() {
+			// Sin entrada (fin de fichero, proceso en segundo plano...) solo
+			// queda la API, si la hay, hasta que nos pidan parar
+			if servidor != nil {
+				esperarSenalYApagar(servidor)
+			}
+			return
+		}
 		opcion := scanner.Text()
 
 		switch opcion {
@@ -3,6 +3,7 @@ package main
 import (
 	"bufio"
 	"fmt"
+	"os"
 	"strconv"
 	"strings"
 )
@@ -27,6 +28,7 @@ func menuClientes(scanner *bufio.Scanner, cm *ClienteManager, vm *VehiculoManage
 		fmt.Println("2. Listar Clientes")
 		fmt.Println("3. Actualizar Cliente")
 		fmt.Println("4. Eliminar Cliente")
+		fmt.Println("5. Preferencia de Aviso")
 		fmt.Println("0. Volver")
 		fmt.Print("Opción: ")
 		scanner.Scan()
@@ -92,6 +94,23 @@ func menuClientes(scanner *bufio.Scanner, cm *ClienteManager, vm *VehiculoManage
 			} else {
 				fmt.Println("Cliente eliminado")
 			}
+		} else if opcion == "5" {
+			fmt.Print("ID del cliente: ")
+			scanner.Scan()
+			id, _ := strconv.Atoi(scanner.Text())
+			fmt.Print("Avisar por (email/sms/ninguno, vacío para automático): ")
+			scanner.Scan()
+			canal, valido := parsearCanalNotificacion(scanner.Text())
+			if !valido {
+				fmt.Println("Canal no válido")
+				continue
+			}
+			err := cm.CambiarPreferencia(id, canal)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+			} else {
+				fmt.Println("Preferencia actualizada")
+			}
 		} else if opcion == "0" {
 			break
 		}
@@ -366,8 +385,7 @@ func menuMecanicos(scanner *bufio.Scanner, mm *MecanicoManager, taller *Taller)
 			mecanico := mm.CrearMecanico(nombre, especialidad, exp)
 			fmt.Printf("Mecánico creado con ID: %d\n", mecanico.ID)
 
-			// Arrancamos la rutina pasando la copia local
-			go taller.ArrancarRutinaMecanico(&mecanico)
+			go taller.ArrancarRutinaMecanico(mecanico)
 
 		} else if opcion == "2" {
 			mecanicos := mm.ListarMecanicos()
@@ -446,6 +464,8 @@ func menuTaller(scanner *bufio.Scanner, t *Taller, vm *VehiculoManager, im *Inci
 		fmt.Println("\n===== ESTADO DEL TALLER =====")
 		fmt.Println("1. Ver Estado")
 		fmt.Println("2. Enviar Vehículo(s) al Taller")
+		fmt.Println("3. Ver Estado (tabla)")
+		fmt.Println("4. Ver Estado (JSON)")
 		fmt.Println("0. Volver")
 		fmt.Print("Opción: ")
 		scanner.Scan()
@@ -453,6 +473,10 @@ func menuTaller(scanner *bufio.Scanner, t *Taller, vm *VehiculoManager, im *Inci
 
 		if opcion == "1" {
 			t.ObtenerEstadoTaller()
+		} else if opcion == "3" {
+			RenderizarEstado(os.Stdout, t.Estado(), FormatoTabla)
+		} else if opcion == "4" {
+			RenderizarEstado(os.Stdout, t.Estado(), FormatoJSON)
 		} else if opcion == "2" {
 			fmt.Print("IDs de los vehículos a enviar (separados por coma): ")
 			scanner.Scan()
@@ -483,7 +507,9 @@ func menuTaller(scanner *bufio.Scanner, t *Taller, vm *VehiculoManager, im *Inci
 
 				for _, inc := range incidencias {
 					incCopia := inc
-					t.AgregarTrabajo(vehiculo, incCopia)
+					if err := t.AgregarTrabajo(vehiculo, incCopia); err != nil {
+						fmt.Printf("Incidencia %d: %v. Omitiendo.\n", inc.ID, err)
+					}
 				}
 			}
 
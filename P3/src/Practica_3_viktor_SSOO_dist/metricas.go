@@ -0,0 +1,432 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// MÉTRICAS DE LA SIMULACIÓN
+// ============================================================================
+
+// ResultadoSimulacion es lo que mide una simulación del taller. Todos los
+// instantes se cuentan desde el inicio de la simulación.
+type ResultadoSimulacion struct {
+	Duracion Duracion `json:"duracion"`
+	// Semilla es la del escenario simulado, para poder repetirlo (0 si no
+	// salió de un escenario)
+	Semilla int64 `json:"semilla,omitempty"`
+	// Recursos es la capacidad de cada recurso (plazas, mecanicos...)
+	Recursos map[string]int `json:"recursos"`
+	// VehiculosPorHora es el rendimiento: coches terminados por hora simulada
+	VehiculosPorHora float64 `json:"vehiculos_por_hora"`
+	// Utilizacion es, por recurso, la fracción del tiempo que han estado
+	// ocupadas sus unidades, entre 0 y 1
+	Utilizacion map[string]float64 `json:"utilizacion"`
+
+	Global     MetricasCategoria   `json:"global"`
+	Categorias []MetricasCategoria `json:"categorias"`
+	Fases      []MetricasFase      `json:"fases"`
+	Vehiculos  []MetricasVehiculo  `json:"vehiculos"`
+}
+
+// MetricasVehiculo resume el paso de un coche por el taller. La espera es lo
+// que pasa en las colas y el servicio lo que pasa dentro de las fases; la
+// estancia va de la llegada a la salida.
+type MetricasVehiculo struct {
+	ID         int            `json:"id"`
+	Incidencia TipoIncidencia `json:"incidencia"`
+	Prioridad  Prioridad      `json:"prioridad"`
+	Llegada    Duracion       `json:"llegada"`
+	Salida     Duracion       `json:"salida"`
+	Espera     Duracion       `json:"espera"`
+	Servicio   Duracion       `json:"servicio"`
+	Estancia   Duracion       `json:"estancia"`
+}
+
+// MetricasCategoria agrega los coches de una categoría (o todos, en Global)
+type MetricasCategoria struct {
+	Incidencia    TipoIncidencia `json:"incidencia,omitempty"`
+	Prioridad     Prioridad      `json:"prioridad,omitempty"`
+	Vehiculos     int            `json:"vehiculos"`
+	EsperaMedia   Duracion       `json:"espera_media"`
+	ServicioMedio Duracion       `json:"servicio_medio"`
+	EstanciaMedia Duracion       `json:"estancia_media"`
+	Espera        Percentiles    `json:"espera"`
+	Estancia      Percentiles    `json:"estancia"`
+}
+
+// Percentiles de una duración, por el método del rango más cercano
+type Percentiles struct {
+	P50 Duracion `json:"p50"`
+	P90 Duracion `json:"p90"`
+	P95 Duracion `json:"p95"`
+	P99 Duracion `json:"p99"`
+}
+
+// MetricasFase describe una fase: cuánto se esperó para entrar, cuánto duró
+// y cómo evolucionó su cola
+type MetricasFase struct {
+	Fase          string   `json:"fase"`
+	Atendidos     int      `json:"atendidos"`
+	EsperaMedia   Duracion `json:"espera_media"`
+	ServicioMedio Duracion `json:"servicio_medio"`
+	// LongitudMedia es la longitud de la cola ponderada por el tiempo
+	LongitudMedia  float64     `json:"longitud_media"`
+	LongitudMaxima int         `json:"longitud_maxima"`
+	Cola           []PuntoCola `json:"cola"`
+}
+
+// PuntoCola dice que a partir de Instante la cola tuvo Longitud coches
+type PuntoCola struct {
+	Instante Duracion `json:"instante"`
+	Longitud int      `json:"longitud"`
+}
+
+// Imprimir escribe un resumen legible del resultado
+func (r *ResultadoSimulacion) Imprimir(w io.Writer) {
+	fmt.Fprintf(w, "Duración %v, %d coches, %.1f coches/hora",
+		time.Duration(r.Duracion).Round(time.Millisecond), r.Global.Vehiculos, r.VehiculosPorHora)
+	if r.Semilla != 0 {
+		fmt.Fprintf(w, ", semilla %d", r.Semilla)
+	}
+	fmt.Fprintln(w)
+	recursos := make([]string, 0, len(r.Utilizacion))
+	for recurso := range r.Utilizacion {
+		recursos = append(recursos, recurso)
+	}
+	sort.Strings(recursos)
+	fmt.Fprint(w, "Utilización:")
+	for i, recurso := range recursos {
+		if i > 0 {
+			fmt.Fprint(w, ",")
+		}
+		fmt.Fprintf(w, " %s %.0f%% (%d)", recurso, 100*r.Utilizacion[recurso], r.Recursos[recurso])
+	}
+	fmt.Fprintln(w)
+	for _, c := range r.categoriasYTotal() {
+		fmt.Fprintf(w, "  %-11s espera media %v (p95 %v), estancia media %v (p95 %v)\n", c.nombre(),
+			time.Duration(c.EsperaMedia).Round(time.Millisecond), time.Duration(c.Espera.P95).Round(time.Millisecond),
+			time.Duration(c.EstanciaMedia).Round(time.Millisecond), time.Duration(c.Estancia.P95).Round(time.Millisecond))
+	}
+	for _, f := range r.Fases {
+		fmt.Fprintf(w, "  Fase %-20s cola media %.2f (máx %d), espera media %v\n",
+			f.Fase, f.LongitudMedia, f.LongitudMaxima, time.Duration(f.EsperaMedia).Round(time.Millisecond))
+	}
+}
+
+// EscribirJSON escribe el resultado completo en JSON
+func (r *ResultadoSimulacion) EscribirJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// EscribirCSV escribe una fila por coche. Los tiempos van en segundos para
+// que se puedan usar directamente en una hoja de cálculo.
+func (r *ResultadoSimulacion) EscribirCSV(w io.Writer) error {
+	escritor := csv.NewWriter(w)
+	escritor.Write([]string{"id", "incidencia", "prioridad", "llegada_s", "salida_s", "espera_s", "servicio_s", "estancia_s"})
+	for _, v := range r.Vehiculos {
+		escritor.Write([]string{
+			strconv.Itoa(v.ID),
+			string(v.Incidencia),
+			strconv.Itoa(int(v.Prioridad)),
+			segundos(v.Llegada),
+			segundos(v.Salida),
+			segundos(v.Espera),
+			segundos(v.Servicio),
+			segundos(v.Estancia),
+		})
+	}
+	escritor.Flush()
+	return escritor.Error()
+}
+
+// EscribirCategoriasCSV escribe una fila por categoría y otra con el total,
+// para comparar escenarios
+func (r *ResultadoSimulacion) EscribirCategoriasCSV(w io.Writer) error {
+	escritor := csv.NewWriter(w)
+	escritor.Write([]string{"incidencia", "vehiculos", "espera_media_s", "servicio_medio_s", "estancia_media_s",
+		"espera_p50_s", "espera_p90_s", "espera_p95_s", "espera_p99_s",
+		"estancia_p50_s", "estancia_p90_s", "estancia_p95_s", "estancia_p99_s"})
+	for _, c := range r.categoriasYTotal() {
+		escritor.Write([]string{
+			c.nombre(),
+			strconv.Itoa(c.Vehiculos),
+			segundos(c.EsperaMedia),
+			segundos(c.ServicioMedio),
+			segundos(c.EstanciaMedia),
+			segundos(c.Espera.P50), segundos(c.Espera.P90), segundos(c.Espera.P95), segundos(c.Espera.P99),
+			segundos(c.Estancia.P50), segundos(c.Estancia.P90), segundos(c.Estancia.P95), segundos(c.Estancia.P99),
+		})
+	}
+	escritor.Flush()
+	return escritor.Error()
+}
+
+func (r *ResultadoSimulacion) categoriasYTotal() []MetricasCategoria {
+	return append(append([]MetricasCategoria{}, r.Categorias...), r.Global)
+}
+
+func (c MetricasCategoria) nombre() string {
+	if c.Incidencia == "" {
+		return "Total"
+	}
+	return string(c.Incidencia)
+}
+
+func segundos(d Duracion) string {
+	return strconv.FormatFloat(time.Duration(d).Seconds(), 'f', 3, 64)
+}
+
+// ============================================================================
+// REGISTRO DURANTE LA SIMULACIÓN
+// ============================================================================
+
+// registroSimulacion va apuntando lo que pasa en una simulación para sacar
+// después el ResultadoSimulacion. Las simulaciones con goroutines lo llaman
+// desde varias a la vez.
+type registroSimulacion struct {
+	mutex    sync.Mutex
+	pipeline *Pipeline
+
+	orden     []*Vehiculo
+	vehiculos map[*Vehiculo]*seguimientoVehiculo
+	fases     []seguimientoFase
+	// ocupacion es, por recurso, la suma del tiempo que lo ha tenido cada
+	// coche
+	ocupacion map[string]time.Duration
+	// progreso cuenta llegadas, entradas y salidas de fase, y salidos los
+	// coches que han terminado todas las fases
+	progreso int
+	salidos  int
+}
+
+type seguimientoVehiculo struct {
+	llegada, salida  time.Duration
+	espera, servicio time.Duration
+	encolado, inicio time.Duration
+	// recursos dice, por cada recurso que pidió una fase y el coche aún
+	// tiene, de dónde lo cogió y cuándo
+	recursos map[string]recursoCogido
+}
+
+// recursoCogido es una unidad de Recurso que tiene un coche. Recurso es el
+// pedido salvo en los recursos repartidos, donde es el grupo.
+type recursoCogido struct {
+	recurso string
+	desde   time.Duration
+}
+
+type seguimientoFase struct {
+	longitud, maxima int
+	enProceso        int
+	ultimoCambio     time.Duration
+	area             float64
+	cola             []PuntoCola
+	atendidos        int
+	espera, servicio time.Duration
+}
+
+func nuevoRegistroSimulacion(pipeline *Pipeline) *registroSimulacion {
+	return &registroSimulacion{
+		pipeline:  pipeline,
+		vehiculos: make(map[*Vehiculo]*seguimientoVehiculo),
+		fases:     make([]seguimientoFase, len(pipeline.Fases)),
+		ocupacion: make(map[string]time.Duration),
+	}
+}
+
+// llegar apunta que v llega al taller y se pone en la cola de la primera
+// fase
+func (r *registroSimulacion) llegar(v *Vehiculo, ahora time.Duration) {
+	r.mutex.Lock()
+	r.orden = append(r.orden, v)
+	r.vehiculos[v] = &seguimientoVehiculo{llegada: ahora, recursos: make(map[string]recursoCogido)}
+	r.progreso++
+	r.mutex.Unlock()
+	r.encolar(v, 0, ahora)
+}
+
+// encolar apunta que v empieza a esperar para entrar en la fase
+func (r *registroSimulacion) encolar(v *Vehiculo, fase int, ahora time.Duration) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.vehiculos[v].encolado = ahora
+	r.cambiarCola(fase, 1, ahora)
+}
+
+// empezar apunta que v ya tiene lo que necesita para la fase y empieza a
+// trabajarse en ella. Cogidos son los recursos que ha cogido, uno por cada
+// uno de los que pide la fase.
+func (r *registroSimulacion) empezar(v *Vehiculo, fase int, cogidos []string, ahora time.Duration) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	s := r.vehiculos[v]
+	s.espera += ahora - s.encolado
+	s.inicio = ahora
+	for i, recurso := range r.pipeline.Fases[fase].Adquiere {
+		s.recursos[recurso] = recursoCogido{recurso: cogidos[i], desde: ahora}
+	}
+	r.fases[fase].espera += ahora - s.encolado
+	r.fases[fase].enProceso++
+	r.progreso++
+	r.cambiarCola(fase, -1, ahora)
+}
+
+// terminar apunta que v ha acabado la fase y devuelve los recursos que
+// suelta
+func (r *registroSimulacion) terminar(v *Vehiculo, fase int, ahora time.Duration) []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	s := r.vehiculos[v]
+	s.servicio += ahora - s.inicio
+	r.fases[fase].servicio += ahora - s.inicio
+	r.fases[fase].atendidos++
+	r.fases[fase].enProceso--
+	r.progreso++
+	var soltados []string
+	for _, recurso := range r.pipeline.Fases[fase].Libera {
+		cogido := s.recursos[recurso]
+		r.ocupacion[cogido.recurso] += ahora - cogido.desde
+		soltados = append(soltados, cogido.recurso)
+		delete(s.recursos, recurso)
+	}
+	if fase == len(r.fases)-1 {
+		s.salida = ahora
+		r.salidos++
+	}
+	return soltados
+}
+
+// cambiarCola se llama con el mutex cogido
+func (r *registroSimulacion) cambiarCola(fase, cambio int, ahora time.Duration) {
+	f := &r.fases[fase]
+	f.area += float64(f.longitud) * float64(ahora-f.ultimoCambio)
+	f.ultimoCambio = ahora
+	f.longitud += cambio
+	if f.longitud > f.maxima {
+		f.maxima = f.longitud
+	}
+	f.cola = append(f.cola, PuntoCola{Instante: Duracion(ahora), Longitud: f.longitud})
+}
+
+// resultado calcula las métricas de una simulación que ha durado duracion
+func (r *registroSimulacion) resultado(duracion time.Duration) *ResultadoSimulacion {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	resultado := &ResultadoSimulacion{
+		Duracion:    Duracion(duracion),
+		Recursos:    make(map[string]int),
+		Utilizacion: make(map[string]float64),
+	}
+	for recurso, capacidad := range r.pipeline.Recursos {
+		resultado.Recursos[recurso] = capacidad
+		if duracion > 0 {
+			resultado.Utilizacion[recurso] = float64(r.ocupacion[recurso]) / (float64(capacidad) * float64(duracion))
+		}
+	}
+
+	porCategoria := make(map[TipoIncidencia][]MetricasVehiculo)
+	for _, v := range r.orden {
+		s := r.vehiculos[v]
+		m := MetricasVehiculo{
+			ID:         v.ID,
+			Incidencia: v.Incidencia,
+			Prioridad:  v.Prioridad,
+			Llegada:    Duracion(s.llegada),
+			Salida:     Duracion(s.salida),
+			Espera:     Duracion(s.espera),
+			Servicio:   Duracion(s.servicio),
+			Estancia:   Duracion(s.salida - s.llegada),
+		}
+		resultado.Vehiculos = append(resultado.Vehiculos, m)
+		porCategoria[v.Incidencia] = append(porCategoria[v.Incidencia], m)
+	}
+	sort.SliceStable(resultado.Vehiculos, func(i, j int) bool {
+		return resultado.Vehiculos[i].ID < resultado.Vehiculos[j].ID
+	})
+
+	resultado.Global = metricasCategoria(resultado.Vehiculos)
+	for incidencia, vehiculos := range porCategoria {
+		c := metricasCategoria(vehiculos)
+		c.Incidencia = incidencia
+		c.Prioridad = vehiculos[0].Prioridad
+		resultado.Categorias = append(resultado.Categorias, c)
+	}
+	sort.Slice(resultado.Categorias, func(i, j int) bool {
+		if resultado.Categorias[i].Prioridad != resultado.Categorias[j].Prioridad {
+			return resultado.Categorias[i].Prioridad > resultado.Categorias[j].Prioridad
+		}
+		return resultado.Categorias[i].Incidencia < resultado.Categorias[j].Incidencia
+	})
+
+	for fase, f := range r.fases {
+		metricas := MetricasFase{
+			Fase:           r.pipeline.Fases[fase].Nombre,
+			Atendidos:      f.atendidos,
+			LongitudMaxima: f.maxima,
+			Cola:           append([]PuntoCola{}, f.cola...),
+		}
+		if f.atendidos > 0 {
+			metricas.EsperaMedia = Duracion(f.espera / time.Duration(f.atendidos))
+			metricas.ServicioMedio = Duracion(f.servicio / time.Duration(f.atendidos))
+		}
+		if duracion > 0 {
+			area := f.area + float64(f.longitud)*float64(duracion-f.ultimoCambio)
+			metricas.LongitudMedia = area / float64(duracion)
+		}
+		resultado.Fases = append(resultado.Fases, metricas)
+	}
+
+	if duracion > 0 {
+		resultado.VehiculosPorHora = float64(len(resultado.Vehiculos)) / duracion.Hours()
+	}
+	return resultado
+}
+
+func metricasCategoria(vehiculos []MetricasVehiculo) MetricasCategoria {
+	c := MetricasCategoria{Vehiculos: len(vehiculos)}
+	if len(vehiculos) == 0 {
+		return c
+	}
+	var espera, servicio, estancia time.Duration
+	esperas := make([]Duracion, len(vehiculos))
+	estancias := make([]Duracion, len(vehiculos))
+	for i, v := range vehiculos {
+		espera += time.Duration(v.Espera)
+		servicio += time.Duration(v.Servicio)
+		estancia += time.Duration(v.Estancia)
+		esperas[i] = v.Espera
+		estancias[i] = v.Estancia
+	}
+	n := time.Duration(len(vehiculos))
+	c.EsperaMedia = Duracion(espera / n)
+	c.ServicioMedio = Duracion(servicio / n)
+	c.EstanciaMedia = Duracion(estancia / n)
+	c.Espera = calcularPercentiles(esperas)
+	c.Estancia = calcularPercentiles(estancias)
+	return c
+}
+
+// calcularPercentiles ordena valores (los modifica)
+func calcularPercentiles(valores []Duracion) Percentiles {
+	sort.Slice(valores, func(i, j int) bool { return valores[i] < valores[j] })
+	percentil := func(p float64) Duracion {
+		i := int(math.Ceil(p*float64(len(valores)))) - 1
+		if i < 0 {
+			i = 0
+		}
+		return valores[i]
+	}
+	return Percentiles{P50: percentil(0.50), P90: percentil(0.90), P95: percentil(0.95), P99: percentil(0.99)}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestResultadoSimulacion comprueba las métricas de la simulación de
+// TestSimularTallerEventos, calculadas a mano
+func TestResultadoSimulacion(t *testing.T) {
+	vehiculos := []*Vehiculo{
+		NewVehiculo(1, Carroceria),
+		NewVehiculo(2, Carroceria),
+		NewVehiculo(3, Mecanica),
+	}
+	for _, v := range vehiculos {
+		v.TiempoLlegada = vehiculos[0].TiempoLlegada
+	}
+	resultado, err := SimularTallerEventos(vehiculos, 1, 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// El 1 no espera, el 3 espera 4s y el 2 espera 24s para la plaza
+	esperas := map[int]time.Duration{1: 0, 2: 24 * time.Second, 3: 4 * time.Second}
+	for _, v := range resultado.Vehiculos {
+		if time.Duration(v.Espera) != esperas[v.ID] {
+			t.Errorf("el coche %d esperó %v, se esperaban %v", v.ID, v.Espera, esperas[v.ID])
+		}
+		if v.Estancia != v.Espera+v.Servicio {
+			t.Errorf("el coche %d tiene estancia %v, espera %v y servicio %v", v.ID, v.Estancia, v.Espera, v.Servicio)
+		}
+	}
+
+	if len(resultado.Categorias) != 2 || resultado.Categorias[0].Incidencia != Mecanica {
+		t.Fatalf("categorías inesperadas: %+v", resultado.Categorias)
+	}
+	carroceria := resultado.Categorias[1]
+	if carroceria.Vehiculos != 2 || carroceria.EsperaMedia != Duracion(12*time.Second) || carroceria.Espera.P50 != 0 || carroceria.Espera.P99 != Duracion(24*time.Second) {
+		t.Errorf("métricas de carrocería inesperadas: %+v", carroceria)
+	}
+
+	// La plaza está ocupada todo el rato y el mecánico 1+5+1 de 28 segundos
+	if math.Abs(resultado.Utilizacion[RecursoPlazas]-1) > 1e-9 {
+		t.Errorf("utilización de plazas %v, se esperaba 1", resultado.Utilizacion[RecursoPlazas])
+	}
+	if math.Abs(resultado.Utilizacion[RecursoMecanicos]-7.0/28) > 1e-9 {
+		t.Errorf("utilización de mecánicos %v, se esperaba 0.25", resultado.Utilizacion[RecursoMecanicos])
+	}
+
+	// En la cola de Entrada hay 2 coches de 0 a 4s y 1 de 4 a 24s
+	entrada := resultado.Fases[0]
+	if entrada.LongitudMaxima != 2 || math.Abs(entrada.LongitudMedia-28.0/28) > 1e-9 {
+		t.Errorf("cola de entrada inesperada: media %v, máxima %d", entrada.LongitudMedia, entrada.LongitudMaxima)
+	}
+}
+
+func TestResultadoSimulacionExportar(t *testing.T) {
+	resultado, err := SimularTallerEventos(generarVehiculos(2, 2, 2), 2, 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var csv bytes.Buffer
+	if err := resultado.EscribirCSV(&csv); err != nil {
+		t.Fatal(err)
+	}
+	lineas := strings.Split(strings.TrimSpace(csv.String()), "\n")
+	if len(lineas) != 7 || !strings.HasPrefix(lineas[0], "id,incidencia,") {
+		t.Fatalf("CSV inesperado:\n%s", csv.String())
+	}
+
+	var categorias bytes.Buffer
+	if err := resultado.EscribirCategoriasCSV(&categorias); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(categorias.String(), "\nTotal,6,") {
+		t.Fatalf("falta el total en el CSV de categorías:\n%s", categorias.String())
+	}
+
+	var datos bytes.Buffer
+	if err := resultado.EscribirJSON(&datos); err != nil {
+		t.Fatal(err)
+	}
+	var leido ResultadoSimulacion
+	if err := json.Unmarshal(datos.Bytes(), &leido); err != nil {
+		t.Fatal(err)
+	}
+	if leido.Duracion != resultado.Duracion || len(leido.Vehiculos) != 6 || leido.Global.Estancia != resultado.Global.Estancia {
+		t.Fatalf("el JSON no conserva el resultado: %+v", leido)
+	}
+}
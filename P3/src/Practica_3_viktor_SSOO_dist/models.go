@@ -1,7 +1,12 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"os"
+	"reflect"
 	"sync"
 	"time"
 )
@@ -40,6 +45,9 @@ const (
 // Especialidad del mecánico
 type Especialidad string
 
+// PlazasPorMecanico es el número de coches que un mecánico puede tener a la vez
+const PlazasPorMecanico = 2
+
 const (
 	EspMecanica   Especialidad = "mecanica"
 	EspElectrica  Especialidad = "electrica"
@@ -57,35 +65,59 @@ type Vehiculo struct {
 	Prioridad     Prioridad
 	TiempoFase    time.Duration
 	TiempoLlegada time.Time
+	// TiemposFase son las fases que duran distinto de TiempoFase, por nombre
+	TiemposFase map[string]time.Duration
 }
 
-// NewVehiculo crea un nuevo vehículo según su categoría
+// NewVehiculo crea un nuevo vehículo según su categoría, con los tiempos de
+// ModeloTiemposPorDefecto
 func NewVehiculo(id int, incidencia TipoIncidencia) *Vehiculo {
+	return NewVehiculoConTiempos(id, incidencia, modeloTiemposDefecto)
+}
+
+// modeloTiemposDefecto es fijo, así que se puede compartir
+var modeloTiemposDefecto = ModeloTiemposPorDefecto()
+
+// NewVehiculoConTiempos crea un vehículo sacando del modelo su tiempo por
+// fase y el de las fases que tienen uno propio
+func NewVehiculoConTiempos(id int, incidencia TipoIncidencia, tiempos *ModeloTiempos) *Vehiculo {
 	v := &Vehiculo{
 		ID:            id,
 		Incidencia:    incidencia,
 		TiempoLlegada: time.Now(),
+		TiempoFase:    tiempos.TiempoFase(incidencia),
+		TiemposFase:   tiempos.TiemposFases(incidencia),
 	}
 
-	// Asignar prioridad y tiempo según categoría
+	// Asignar prioridad según categoría
 	switch incidencia {
 	case Mecanica: // Categoría A
 		v.Prioridad = PrioridadAlta
-		v.TiempoFase = 5 * time.Second
 	case Electrica: // Categoría B
 		v.Prioridad = PrioridadMedia
-		v.TiempoFase = 3 * time.Second
 	case Carroceria: // Categoría C
 		v.Prioridad = PrioridadBaja
-		v.TiempoFase = 1 * time.Second
 	}
 
 	return v
 }
 
+// TiempoEn es lo que tarda el vehículo en la fase con ese nombre
+func (v *Vehiculo) TiempoEn(fase string) time.Duration {
+	if tiempo, ok := v.TiemposFase[fase]; ok {
+		return tiempo
+	}
+	return v.TiempoFase
+}
+
 // LogEstado imprime el estado del vehículo en una fase
 func (v *Vehiculo) LogEstado(fase string, estado string, tiempoEjecucion time.Duration) {
-	fmt.Printf("Tiempo %v Coche %d Incidencia %s Fase %s Estado %s\n",
+	v.EscribirEstado(os.Stdout, fase, estado, tiempoEjecucion)
+}
+
+// EscribirEstado es como LogEstado pero escribe en w
+func (v *Vehiculo) EscribirEstado(w io.Writer, fase string, estado string, tiempoEjecucion time.Duration) {
+	fmt.Fprintf(w, "Tiempo %v Coche %d Incidencia %s Fase %s Estado %s\n",
 		tiempoEjecucion.Round(time.Millisecond),
 		v.ID,
 		v.Incidencia,
@@ -93,21 +125,103 @@ func (v *Vehiculo) LogEstado(fase string, estado string, tiempoEjecucion time.Du
 		estado)
 }
 
-// TallerSimulacion representa el taller con sus recursos para la simulación
+// TallerSimulacion representa el taller con sus recursos para la simulación.
+// Cada recurso es un semáforo; PlazasSem y MecanicosSem son los de plazas y
+// mecánicos.
 type TallerSimulacion struct {
 	NumPlazas    int
 	NumMecanicos int
 	PlazasSem    chan struct{}
 	MecanicosSem chan struct{}
+	Recursos     map[string]chan struct{}
 }
 
 // NewTallerSimulacion crea un nuevo taller para simulación
 func NewTallerSimulacion(numPlazas, numMecanicos int) *TallerSimulacion {
-	return &TallerSimulacion{
-		NumPlazas:    numPlazas,
-		NumMecanicos: numMecanicos,
-		PlazasSem:    make(chan struct{}, numPlazas),
-		MecanicosSem: make(chan struct{}, numMecanicos),
+	return NewTallerSimulacionConRecursos(map[string]int{
+		RecursoPlazas:    numPlazas,
+		RecursoMecanicos: numMecanicos,
+	})
+}
+
+// NewTallerSimulacionConRecursos crea un taller con un semáforo por recurso
+// de la capacidad indicada
+func NewTallerSimulacionConRecursos(capacidades map[string]int) *TallerSimulacion {
+	t := &TallerSimulacion{
+		NumPlazas:    capacidades[RecursoPlazas],
+		NumMecanicos: capacidades[RecursoMecanicos],
+		Recursos:     make(map[string]chan struct{}),
+	}
+	for nombre, capacidad := range capacidades {
+		t.Recursos[nombre] = make(chan struct{}, capacidad)
+	}
+	t.PlazasSem = t.Recursos[RecursoPlazas]
+	t.MecanicosSem = t.Recursos[RecursoMecanicos]
+	return t
+}
+
+// Adquirir coge una unidad de cada recurso, en orden, esperando si no hay.
+// Si se cancela ctx mientras espera suelta lo que ya había cogido.
+func (t *TallerSimulacion) Adquirir(ctx context.Context, recursos []string) error {
+	alternativas := make([][]string, len(recursos))
+	for i, recurso := range recursos {
+		alternativas[i] = []string{recurso}
+	}
+	_, err := t.AdquirirAlguno(ctx, alternativas)
+	return err
+}
+
+// AdquirirAlguno es como Adquirir, pero de cada lista de alternativas coge
+// una unidad de la primera que tenga sitio o, si no hay ninguna, de la
+// primera que lo deje. Devuelve los recursos que ha cogido.
+func (t *TallerSimulacion) AdquirirAlguno(ctx context.Context, alternativas [][]string) ([]string, error) {
+	cogidos := make([]string, 0, len(alternativas))
+	for _, recursos := range alternativas {
+		recurso, err := t.adquirirUno(ctx, recursos)
+		if err != nil {
+			t.Liberar(cogidos)
+			return nil, err
+		}
+		cogidos = append(cogidos, recurso)
+	}
+	return cogidos, nil
+}
+
+func (t *TallerSimulacion) adquirirUno(ctx context.Context, recursos []string) (string, error) {
+	if len(recursos) == 1 {
+		select {
+		case t.Recursos[recursos[0]] <- struct{}{}:
+			return recursos[0], nil
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+	for _, recurso := range recursos {
+		select {
+		case t.Recursos[recurso] <- struct{}{}:
+			return recurso, nil
+		default:
+		}
+	}
+	// Ninguno tiene sitio: se espera a todos a la vez
+	casos := []reflect.SelectCase{{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())}}
+	for _, recurso := range recursos {
+		casos = append(casos, reflect.SelectCase{
+			Dir:  reflect.SelectSend,
+			Chan: reflect.ValueOf(t.Recursos[recurso]),
+			Send: reflect.ValueOf(struct{}{}),
+		})
+	}
+	if elegido, _, _ := reflect.Select(casos); elegido > 0 {
+		return recursos[elegido-1], nil
+	}
+	return "", ctx.Err()
+}
+
+// Liberar suelta una unidad de cada recurso
+func (t *TallerSimulacion) Liberar(recursos []string) {
+	for _, recurso := range recursos {
+		<-t.Recursos[recurso]
 	}
 }
 
@@ -117,30 +231,34 @@ func NewTallerSimulacion(numPlazas, numMecanicos int) *TallerSimulacion {
 
 // Cliente representa a un cliente del taller
 type Cliente struct {
-	ID       int
-	Nombre   string
-	Telefono string
-	Email    string
+	ID       int    `json:"id"`
+	Nombre   string `json:"nombre"`
+	Telefono string `json:"telefono"`
+	Email    string `json:"email"`
+
+	// Notificar es el canal por el que prefiere que le avisen; vacío para
+	// el que haya disponible
+	Notificar CanalNotificacion `json:"notificar,omitempty"`
 }
 
 // VehiculoCompleto representa un vehículo con toda su información
 type VehiculoCompleto struct {
-	ID              int
-	Matricula       string
-	Marca           string
-	Modelo          string
-	ClienteID       int
-	TiempoAcumulado float64
+	ID              int     `json:"id"`
+	Matricula       string  `json:"matricula"`
+	Marca           string  `json:"marca"`
+	Modelo          string  `json:"modelo"`
+	ClienteID       int     `json:"cliente_id"`
+	TiempoAcumulado float64 `json:"tiempo_acumulado"`
 }
 
 // IncidenciaCompleta representa una reparación pendiente con toda su información
 type IncidenciaCompleta struct {
-	ID          int
-	VehiculoID  int
-	Tipo        TipoIncidencia
-	Prioridad   Prioridad
-	Estado      EstadoIncidencia
-	Descripcion string
+	ID          int              `json:"id"`
+	VehiculoID  int              `json:"vehiculo_id"`
+	Tipo        TipoIncidencia   `json:"tipo"`
+	Prioridad   Prioridad        `json:"prioridad"`
+	Estado      EstadoIncidencia `json:"estado"`
+	Descripcion string           `json:"descripcion"`
 }
 
 // Mecanico representa a un mecánico del taller
@@ -159,14 +277,19 @@ type Mecanico struct {
 type TrabajoMecanico struct {
 	Vehiculo   VehiculoCompleto
 	Incidencia IncidenciaCompleta
+	Llegada    time.Time // cuándo entró en la cola del taller
+	Inicio     time.Time // cuándo empezó el mecánico; cero si aún espera
 }
 
 // Taller representa el sistema de gestión del taller (interactivo)
 type Taller struct {
 	ColaTrabajo       []*TrabajoMecanico
+	Envejecimiento    time.Duration // ver antesEnCola
+	Tiempos           *ModeloTiempos
 	MecanicoManager   *MecanicoManager
 	VehiculoManager   *VehiculoManager
 	IncidenciaManager *IncidenciaManager
+	eventos           *BusEventos
 	mutex             sync.Mutex
 }
 
@@ -178,6 +301,7 @@ type Taller struct {
 type ClienteManager struct {
 	clientes map[int]*Cliente
 	nextID   int
+	repo     Repositorio
 	mutex    sync.RWMutex
 }
 
@@ -185,6 +309,7 @@ type ClienteManager struct {
 type VehiculoManager struct {
 	vehiculos map[int]*VehiculoCompleto
 	nextID    int
+	repo      Repositorio
 	mutex     sync.RWMutex
 }
 
@@ -192,6 +317,8 @@ type VehiculoManager struct {
 type IncidenciaManager struct {
 	incidencias map[int]*IncidenciaCompleta
 	nextID      int
+	repo        Repositorio
+	eventos     *BusEventos
 	mutex       sync.RWMutex
 }
 
@@ -199,6 +326,8 @@ type IncidenciaManager struct {
 type MecanicoManager struct {
 	mecanicos map[int]*Mecanico
 	nextID    int
+	repo      Repositorio
+	eventos   *BusEventos
 	mutex     sync.RWMutex
 }
 
@@ -206,44 +335,153 @@ type MecanicoManager struct {
 // CONSTRUCTORES
 // ============================================================================
 
-// NewClienteManager crea un nuevo gestor de clientes
+// NewClienteManager crea un nuevo gestor de clientes en memoria
 func NewClienteManager() *ClienteManager {
 	return &ClienteManager{
 		clientes: make(map[int]*Cliente),
 		nextID:   1,
+		repo:     NewRepositorioMemoria(),
 	}
 }
 
-// NewVehiculoManager crea un nuevo gestor de vehículos
+// NewClienteManagerConRepositorio crea un gestor de clientes que guarda los
+// cambios en repo y recupera los clientes que ya contiene
+func NewClienteManagerConRepositorio(repo Repositorio) (*ClienteManager, error) {
+	cm := NewClienteManager()
+	cm.repo = repo
+
+	nextID, err := cargarDeRepositorio(repo, func(datos []byte) error {
+		var c Cliente
+		if err := json.Unmarshal(datos, &c); err != nil {
+			return err
+		}
+		cm.clientes[c.ID] = &c
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("no se pudieron cargar los clientes: %w", err)
+	}
+	cm.nextID = nextID
+	return cm, nil
+}
+
+// NewVehiculoManager crea un nuevo gestor de vehículos en memoria
 func NewVehiculoManager() *VehiculoManager {
 	return &VehiculoManager{
 		vehiculos: make(map[int]*VehiculoCompleto),
 		nextID:    1,
+		repo:      NewRepositorioMemoria(),
 	}
 }
 
-// NewIncidenciaManager crea un nuevo gestor de incidencias
+// NewVehiculoManagerConRepositorio crea un gestor de vehículos que guarda los
+// cambios en repo y recupera los vehículos que ya contiene
+func NewVehiculoManagerConRepositorio(repo Repositorio) (*VehiculoManager, error) {
+	vm := NewVehiculoManager()
+	vm.repo = repo
+
+	nextID, err := cargarDeRepositorio(repo, func(datos []byte) error {
+		var v VehiculoCompleto
+		if err := json.Unmarshal(datos, &v); err != nil {
+			return err
+		}
+		vm.vehiculos[v.ID] = &v
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("no se pudieron cargar los vehículos: %w", err)
+	}
+	vm.nextID = nextID
+	return vm, nil
+}
+
+// NewIncidenciaManager crea un nuevo gestor de incidencias en memoria
 func NewIncidenciaManager() *IncidenciaManager {
 	return &IncidenciaManager{
 		incidencias: make(map[int]*IncidenciaCompleta),
 		nextID:      1,
+		repo:        NewRepositorioMemoria(),
 	}
 }
 
-// NewMecanicoManager crea un nuevo gestor de mecánicos
+// NewIncidenciaManagerConRepositorio crea un gestor de incidencias que guarda
+// los cambios en repo y recupera las incidencias que ya contiene
+func NewIncidenciaManagerConRepositorio(repo Repositorio) (*IncidenciaManager, error) {
+	im := NewIncidenciaManager()
+	im.repo = repo
+
+	nextID, err := cargarDeRepositorio(repo, func(datos []byte) error {
+		var inc IncidenciaCompleta
+		if err := json.Unmarshal(datos, &inc); err != nil {
+			return err
+		}
+		im.incidencias[inc.ID] = &inc
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("no se pudieron cargar las incidencias: %w", err)
+	}
+	im.nextID = nextID
+	return im, nil
+}
+
+// NewMecanicoManager crea un nuevo gestor de mecánicos en memoria
 func NewMecanicoManager() *MecanicoManager {
 	return &MecanicoManager{
 		mecanicos: make(map[int]*Mecanico),
 		nextID:    1,
+		repo:      NewRepositorioMemoria(),
+	}
+}
+
+// NewMecanicoManagerConRepositorio crea un gestor de mecánicos que guarda los
+// cambios en repo y recupera los mecánicos que ya contiene (con la cola vacía)
+func NewMecanicoManagerConRepositorio(repo Repositorio) (*MecanicoManager, error) {
+	mm := NewMecanicoManager()
+	mm.repo = repo
+
+	nextID, err := cargarDeRepositorio(repo, func(datos []byte) error {
+		var g mecanicoGuardado
+		if err := json.Unmarshal(datos, &g); err != nil {
+			return err
+		}
+		mm.mecanicos[g.ID] = &Mecanico{
+			ID:           g.ID,
+			Nombre:       g.Nombre,
+			Especialidad: g.Especialidad,
+			Experiencia:  g.Experiencia,
+			Activo:       g.Activo,
+			ColaPersonal: make([]*TrabajoMecanico, 0, PlazasPorMecanico),
+			Canal:        make(chan *TrabajoMecanico, PlazasPorMecanico),
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("no se pudieron cargar los mecánicos: %w", err)
 	}
+	mm.nextID = nextID
+	return mm, nil
 }
 
 // NewTaller crea un nuevo taller para el sistema interactivo
 func NewTaller(mm *MecanicoManager, vm *VehiculoManager, im *IncidenciaManager) *Taller {
+	// Los managers publican en el mismo bus que el taller
+	eventos := NewBusEventos()
+	mm.eventos = eventos
+	im.eventos = eventos
+
 	return &Taller{
 		ColaTrabajo:       make([]*TrabajoMecanico, 0),
+		Envejecimiento:    EnvejecimientoDefecto,
+		Tiempos:           ModeloTiemposPorDefecto(),
 		MecanicoManager:   mm,
 		VehiculoManager:   vm,
 		IncidenciaManager: im,
+		eventos:           eventos,
 	}
 }
+
+// Eventos devuelve el bus donde el taller publica lo que va pasando
+func (t *Taller) Eventos() *BusEventos {
+	return t.eventos
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// NOTIFICACIONES A CLIENTES
+// ============================================================================
+
+// CanalNotificacion es la vía por la que un cliente quiere que le avisen
+type CanalNotificacion string
+
+const (
+	CanalEmail   CanalNotificacion = "email"
+	CanalSMS     CanalNotificacion = "sms"
+	CanalNinguno CanalNotificacion = "ninguno"
+
+	// CanalBuzon no lo elige el cliente: es el buzón de salida que recoge los
+	// avisos de los canales que no están configurados
+	CanalBuzon CanalNotificacion = "buzon"
+)
+
+// Notificacion es un aviso ya listo para enviar a un cliente
+type Notificacion struct {
+	ClienteID int               `json:"cliente_id"`
+	Canal     CanalNotificacion `json:"canal"`
+	Destino   string            `json:"destino"`
+	Asunto    string            `json:"asunto"`
+	Mensaje   string            `json:"mensaje"`
+}
+
+// Notificador envía notificaciones por un canal concreto
+type Notificador interface {
+	Enviar(n Notificacion) error
+}
+
+// NotificadorEmail envía las notificaciones por correo a través de un
+// servidor SMTP
+type NotificadorEmail struct {
+	Servidor  string // host:puerto
+	Remitente string
+	Auth      smtp.Auth // nil si el servidor no pide autenticación
+}
+
+// Enviar manda la notificación como un correo de texto plano
+func (e *NotificadorEmail) Enviar(n Notificacion) error {
+	var mensaje bytes.Buffer
+	fmt.Fprintf(&mensaje, "From: %s\r\n", e.Remitente)
+	fmt.Fprintf(&mensaje, "To: %s\r\n", n.Destino)
+	fmt.Fprintf(&mensaje, "Subject: %s\r\n", n.Asunto)
+	fmt.Fprintf(&mensaje, "Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	fmt.Fprintf(&mensaje, "%s\r\n", n.Mensaje)
+
+	if err := smtp.SendMail(e.Servidor, e.Auth, e.Remitente, []string{n.Destino}, mensaje.Bytes()); err != nil {
+		return fmt.Errorf("enviando correo a %s: %w", n.Destino, err)
+	}
+	return nil
+}
+
+// NotificadorSMS envía las notificaciones a una pasarela de SMS por HTTP
+type NotificadorSMS struct {
+	URL     string
+	Token   string // se manda como Bearer si no está vacío
+	Cliente *http.Client
+}
+
+// Enviar publica {"telefono", "mensaje"} en la pasarela
+func (s *NotificadorSMS) Enviar(n Notificacion) error {
+	cuerpo, err := json.Marshal(map[string]string{"telefono": n.Destino, "mensaje": n.Mensaje})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, s.URL, bytes.NewReader(cuerpo))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.Token)
+	}
+
+	cliente := s.Cliente
+	if cliente == nil {
+		cliente = http.DefaultClient
+	}
+	resp, err := cliente.Do(req)
+	if err != nil {
+		return fmt.Errorf("enviando SMS a %s: %w", n.Destino, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("enviando SMS a %s: la pasarela respondió %s", n.Destino, resp.Status)
+	}
+	return nil
+}
+
+// NotificadorArchivo deja las notificaciones en un buzón de salida, una por
+// línea en JSON, para que otro proceso las envíe o para revisarlas a mano
+type NotificadorArchivo struct {
+	Ruta  string
+	mutex sync.Mutex
+}
+
+// Enviar añade la notificación al final del buzón
+func (a *NotificadorArchivo) Enviar(n Notificacion) error {
+	linea, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	archivo, err := os.OpenFile(a.Ruta, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := archivo.Write(append(linea, '\n')); err != nil {
+		archivo.Close()
+		return err
+	}
+	return archivo.Close()
+}
+
+// NotificadorConReintentos repite los envíos fallidos, doblando la espera
+// entre intentos
+type NotificadorConReintentos struct {
+	Notificador Notificador
+	Intentos    int
+	Espera      time.Duration
+}
+
+// Enviar prueba hasta Intentos veces y devuelve el último error
+func (r *NotificadorConReintentos) Enviar(n Notificacion) error {
+	espera := r.Espera
+	var err error
+	for intento := 1; intento <= r.Intentos; intento++ {
+		if err = r.Notificador.Enviar(n); err == nil {
+			return nil
+		}
+		if intento < r.Intentos {
+			time.Sleep(espera)
+			espera *= 2
+		}
+	}
+	return fmt.Errorf("tras %d intentos: %w", r.Intentos, err)
+}
+
+// ServicioNotificaciones avisa a los clientes cuando se cierra una incidencia
+// de su vehículo, usando el canal que cada uno prefiera
+type ServicioNotificaciones struct {
+	clientes    *ClienteManager
+	vehiculos   *VehiculoManager
+	incidencias *IncidenciaManager
+	canales     map[CanalNotificacion]Notificador
+}
+
+// NewServicioNotificaciones crea el servicio con los canales disponibles.
+// Un canal sin notificador (nil) se trata como no configurado.
+func NewServicioNotificaciones(cm *ClienteManager, vm *VehiculoManager, im *IncidenciaManager, canales map[CanalNotificacion]Notificador) *ServicioNotificaciones {
+	s := &ServicioNotificaciones{
+		clientes:    cm,
+		vehiculos:   vm,
+		incidencias: im,
+		canales:     make(map[CanalNotificacion]Notificador),
+	}
+	for canal, notificador := range canales {
+		if notificador != nil {
+			s.canales[canal] = notificador
+		}
+	}
+	return s
+}
+
+// Suscribir empieza a escuchar los cierres de incidencias en el bus.
+// Devuelve la función para dejar de escuchar.
+func (s *ServicioNotificaciones) Suscribir(bus *BusEventos) func() {
+	return bus.Suscribir(func(e Evento) {
+		if e.Estado != Cerrada {
+			return
+		}
+		if err := s.AvisarIncidenciaCerrada(e.IncidenciaID, e.VehiculoID); err != nil {
+			fmt.Printf("AVISO: no se pudo notificar el cierre de la incidencia %d: %v\n", e.IncidenciaID, err)
+		}
+	}, IncidenciaEstadoCambiado)
+}
+
+// AvisarIncidenciaCerrada notifica al dueño del vehículo que la incidencia
+// está cerrada y, si no le queda ninguna abierta, que puede recogerlo
+func (s *ServicioNotificaciones) AvisarIncidenciaCerrada(incidenciaID, vehiculoID int) error {
+	vehiculo, existe := s.vehiculos.ObtenerVehiculo(vehiculoID)
+	if !existe {
+		return fmt.Errorf("vehículo %d no encontrado", vehiculoID)
+	}
+	cliente, existe := s.clientes.ObtenerCliente(vehiculo.ClienteID)
+	if !existe {
+		return fmt.Errorf("cliente %d no encontrado", vehiculo.ClienteID)
+	}
+
+	pendientes := 0
+	for _, inc := range s.incidencias.ListarIncidencias() {
+		if inc.VehiculoID == vehiculoID && inc.Estado != Cerrada {
+			pendientes++
+		}
+	}
+
+	n := Notificacion{ClienteID: cliente.ID}
+	if pendientes == 0 {
+		n.Asunto = fmt.Sprintf("Su vehículo %s está listo", vehiculo.Matricula)
+		n.Mensaje = fmt.Sprintf("Hola %s, hemos terminado la reparación de su %s %s (%s). Ya puede pasar a recogerlo.",
+			cliente.Nombre, vehiculo.Marca, vehiculo.Modelo, vehiculo.Matricula)
+	} else {
+		n.Asunto = fmt.Sprintf("Avance en la reparación de %s", vehiculo.Matricula)
+		n.Mensaje = fmt.Sprintf("Hola %s, hemos cerrado la incidencia %d de su vehículo %s. Quedan %d por resolver.",
+			cliente.Nombre, incidenciaID, vehiculo.Matricula, pendientes)
+	}
+	return s.Notificar(cliente, n)
+}
+
+// Notificar envía n al cliente por su canal preferido. Si no ha elegido
+// ninguno se usa el email y, si no tiene, el teléfono. Lo que no se puede
+// enviar se deja en el buzón, si está configurado.
+func (s *ServicioNotificaciones) Notificar(cliente *Cliente, n Notificacion) error {
+	canal := cliente.Notificar
+	if canal == "" {
+		canal = CanalEmail
+		if cliente.Email == "" {
+			canal = CanalSMS
+		}
+	}
+	if canal == CanalNinguno {
+		return nil
+	}
+
+	switch canal {
+	case CanalEmail:
+		n.Destino = cliente.Email
+	case CanalSMS:
+		n.Destino = cliente.Telefono
+	}
+	if n.Destino == "" {
+		return fmt.Errorf("el cliente %d no tiene %s", cliente.ID, canal)
+	}
+
+	buzon, hayBuzon := s.canales[CanalBuzon]
+	notificador, existe := s.canales[canal]
+	if !existe {
+		// Sin ese canal configurado el aviso se queda en el buzón, si lo hay
+		if !hayBuzon {
+			return fmt.Errorf("no hay ningún notificador para el canal %s", canal)
+		}
+		notificador = buzon
+	}
+	n.Canal = canal
+	err := notificador.Enviar(n)
+	if err != nil && existe && hayBuzon {
+		// Igual que si el canal no existiera: el aviso no se pierde
+		if errBuzon := buzon.Enviar(n); errBuzon != nil {
+			return fmt.Errorf("%v; tampoco se pudo dejar en el buzón: %v", err, errBuzon)
+		}
+		return nil
+	}
+	return err
+}
+
+func parsearCanalNotificacion(s string) (CanalNotificacion, bool) {
+	switch CanalNotificacion(strings.ToLower(s)) {
+	case "":
+		return "", true
+	case CanalEmail:
+		return CanalEmail, true
+	case CanalSMS:
+		return CanalSMS, true
+	case CanalNinguno:
+		return CanalNinguno, true
+	}
+	return "", false
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// servidorSMTPFalso acepta correos en 127.0.0.1 y manda por el canal el
+// contenido de cada uno
+func servidorSMTPFalso(t *testing.T) (string, <-chan string) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	correos := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go atenderSMTP(conn, correos)
+		}
+	}()
+	return ln.Addr().String(), correos
+}
+
+func atenderSMTP(conn net.Conn, correos chan<- string) {
+	defer conn.Close()
+	lector := bufio.NewReader(conn)
+	responder := func(linea string) { conn.Write([]byte(linea + "\r\n")) }
+
+	responder("220 localhost ESMTP")
+	for {
+		linea, err := lector.ReadString('\n')
+		if err != nil {
+			return
+		}
+		orden := strings.ToUpper(strings.TrimSpace(linea))
+		switch {
+		case strings.HasPrefix(orden, "EHLO"), strings.HasPrefix(orden, "HELO"):
+			responder("250 localhost")
+		case orden == "DATA":
+			responder("354 adelante")
+			var cuerpo strings.Builder
+			for {
+				l, err := lector.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				cuerpo.WriteString(l)
+			}
+			correos <- cuerpo.String()
+			responder("250 recibido")
+		case orden == "QUIT":
+			responder("221 adiós")
+			return
+		default:
+			responder("250 OK")
+		}
+	}
+}
+
+// notificadorFallido falla las primeras veces que se le llama
+type notificadorFallido struct {
+	fallos   int
+	llamadas int
+}
+
+func (f *notificadorFallido) Enviar(n Notificacion) error {
+	f.llamadas++
+	if f.llamadas <= f.fallos {
+		return errors.New("canal caído")
+	}
+	return nil
+}
+
+// TestNotificadores prueba cada canal por separado y los reintentos
+func TestNotificadores(t *testing.T) {
+	n := Notificacion{ClienteID: 1, Destino: "ana@test.com", Asunto: "Su vehículo está listo", Mensaje: "Puede recogerlo"}
+
+	servidor, correos := servidorSMTPFalso(t)
+	email := &NotificadorEmail{Servidor: servidor, Remitente: "taller@test.com"}
+	if err := email.Enviar(n); err != nil {
+		t.Fatalf("email: %v", err)
+	}
+	if correo := <-correos; !strings.Contains(correo, "Subject: Su vehículo está listo") || !strings.Contains(correo, "Puede recogerlo") {
+		t.Fatalf("correo incorrecto:\n%s", correo)
+	}
+
+	var recibido map[string]string
+	pasarela := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secreto" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&recibido)
+	}))
+	defer pasarela.Close()
+	sms := &NotificadorSMS{URL: pasarela.URL, Token: "secreto", Cliente: pasarela.Client()}
+	n.Destino = "600000001"
+	if err := sms.Enviar(n); err != nil {
+		t.Fatalf("sms: %v", err)
+	}
+	if recibido["telefono"] != "600000001" || recibido["mensaje"] != "Puede recogerlo" {
+		t.Fatalf("SMS incorrecto: %v", recibido)
+	}
+	sms.Token = "otro"
+	if err := sms.Enviar(n); err == nil {
+		t.Fatal("se esperaba error si la pasarela rechaza el envío")
+	}
+
+	buzon := &NotificadorArchivo{Ruta: filepath.Join(t.TempDir(), "buzon.jsonl")}
+	buzon.Enviar(n)
+	buzon.Enviar(n)
+	datos, err := os.ReadFile(buzon.Ruta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lineas := strings.Split(strings.TrimSpace(string(datos)), "\n"); len(lineas) != 2 {
+		t.Fatalf("el buzón debería tener 2 avisos, tiene %d", len(lineas))
+	}
+
+	fallido := &notificadorFallido{fallos: 2}
+	if err := (&NotificadorConReintentos{Notificador: fallido, Intentos: 3}).Enviar(n); err != nil || fallido.llamadas != 3 {
+		t.Fatalf("reintentos: err=%v llamadas=%d", err, fallido.llamadas)
+	}
+	fallido = &notificadorFallido{fallos: 5}
+	if err := (&NotificadorConReintentos{Notificador: fallido, Intentos: 3}).Enviar(n); err == nil || fallido.llamadas != 3 {
+		t.Fatalf("se esperaba error tras 3 intentos: err=%v llamadas=%d", err, fallido.llamadas)
+	}
+}
+
+// TestServicioNotificaciones comprueba que al cerrar incidencias se avisa al
+// cliente por su canal preferido
+func TestServicioNotificaciones(t *testing.T) {
+	cm := NewClienteManager()
+	vm := NewVehiculoManager()
+	im := NewIncidenciaManager()
+	taller := NewTaller(NewMecanicoManager(), vm, im)
+
+	servidor, correos := servidorSMTPFalso(t)
+	buzon := &NotificadorArchivo{Ruta: filepath.Join(t.TempDir(), "buzon.jsonl")}
+	servicio := NewServicioNotificaciones(cm, vm, im, map[CanalNotificacion]Notificador{
+		CanalEmail: &NotificadorEmail{Servidor: servidor, Remitente: "taller@test.com"},
+		CanalBuzon: buzon,
+	})
+	servicio.Suscribir(taller.Eventos())
+
+	ana := cm.CrearCliente("Ana", "600000001", "ana@test.com")
+	luis := cm.CrearCliente("Luis", "600000002", "luis@test.com")
+	cm.CambiarPreferencia(luis.ID, CanalSMS)
+
+	cocheAna, _ := vm.CrearVehiculo("1111AAA", "Seat", "Ibiza", ana.ID, cm)
+	cocheLuis, _ := vm.CrearVehiculo("2222BBB", "Opel", "Corsa", luis.ID, cm)
+	inc1 := im.CrearIncidencia(Mecanica, PrioridadAlta, "Frenos", cocheAna.ID)
+	inc2 := im.CrearIncidencia(Electrica, PrioridadBaja, "Luces", cocheAna.ID)
+	inc3 := im.CrearIncidencia(Carroceria, PrioridadMedia, "Golpe", cocheLuis.ID)
+
+	im.CambiarEstado(inc1.ID, Cerrada)
+	im.CambiarEstado(inc2.ID, EnProceso)
+	if correo := <-correos; !strings.Contains(correo, "Quedan 1 por resolver") {
+		t.Fatalf("primer aviso incorrecto:\n%s", correo)
+	}
+
+	im.CambiarEstado(inc2.ID, Cerrada)
+	im.CambiarEstado(inc3.ID, Cerrada)
+	taller.Eventos().Cerrar()
+	if correo := <-correos; !strings.Contains(correo, "Ya puede pasar a recogerlo") {
+		t.Fatalf("segundo aviso incorrecto:\n%s", correo)
+	}
+
+	// Luis prefiere SMS, que no está configurado, así que acaba en el buzón
+	datos, err := os.ReadFile(buzon.Ruta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var aviso Notificacion
+	if err := json.Unmarshal(datos, &aviso); err != nil {
+		t.Fatal(err)
+	}
+	if aviso.ClienteID != luis.ID || aviso.Canal != CanalSMS || aviso.Destino != "600000002" {
+		t.Fatalf("aviso del buzón incorrecto: %+v", aviso)
+	}
+
+	// Si el email falla el aviso también acaba en el buzón
+	caido := &notificadorFallido{fallos: 1}
+	buzon.Ruta = filepath.Join(t.TempDir(), "buzon.jsonl")
+	servicio = NewServicioNotificaciones(cm, vm, im, map[CanalNotificacion]Notificador{
+		CanalEmail: caido,
+		CanalBuzon: buzon,
+	})
+	if err := servicio.Notificar(ana, Notificacion{ClienteID: ana.ID, Asunto: "Listo"}); err != nil || caido.llamadas != 1 {
+		t.Fatalf("err=%v llamadas=%d", err, caido.llamadas)
+	}
+	if datos, err = os.ReadFile(buzon.Ruta); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(datos, &aviso); err != nil || aviso.Canal != CanalEmail || aviso.Destino != "ana@test.com" {
+		t.Fatalf("aviso del buzón incorrecto: %+v %v", aviso, err)
+	}
+}
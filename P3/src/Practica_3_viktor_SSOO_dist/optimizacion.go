@@ -0,0 +1,321 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ============================================================================
+// PLANIFICACIÓN DE CAPACIDAD
+// ============================================================================
+
+// MaximoConfiguraciones es cuántas combinaciones de recursos se aceptan como
+// mucho en una búsqueda
+const MaximoConfiguraciones = 100000
+
+// ObjetivoEspera es el nivel de servicio que se quiere dar: que la espera de
+// los coches de Incidencia (de todos si está vacía) no pase de Maximo. La
+// espera se mide en el Percentil dado (50, 90, 95 o 99) o, con 0, en media.
+type ObjetivoEspera struct {
+	Incidencia TipoIncidencia
+	Percentil  int
+	Maximo     time.Duration
+}
+
+func (o ObjetivoEspera) String() string {
+	medida := "media"
+	if o.Percentil != 0 {
+		medida = fmt.Sprintf("p%d", o.Percentil)
+	}
+	quien := "todos"
+	if o.Incidencia != "" {
+		quien = string(o.Incidencia)
+	}
+	return fmt.Sprintf("espera %s de %s <= %v", medida, quien, o.Maximo)
+}
+
+func (o ObjetivoEspera) validar() error {
+	switch o.Percentil {
+	case 0, 50, 90, 95, 99:
+	default:
+		return fmt.Errorf("percentil %d no válido, se esperaba 50, 90, 95, 99 o 0 para la media", o.Percentil)
+	}
+	if o.Maximo <= 0 {
+		return fmt.Errorf("la espera máxima debe ser positiva")
+	}
+	return nil
+}
+
+// medir saca del resultado la espera a la que se refiere el objetivo
+func (o ObjetivoEspera) medir(r *ResultadoSimulacion) (time.Duration, error) {
+	metricas := r.Global
+	if o.Incidencia != "" {
+		encontrada := false
+		for _, c := range r.Categorias {
+			if c.Incidencia == o.Incidencia {
+				metricas, encontrada = c, true
+			}
+		}
+		if !encontrada || metricas.Vehiculos == 0 {
+			return 0, fmt.Errorf("el escenario no tiene coches de %s", o.Incidencia)
+		}
+	}
+	switch o.Percentil {
+	case 50:
+		return time.Duration(metricas.Espera.P50), nil
+	case 90:
+		return time.Duration(metricas.Espera.P90), nil
+	case 95:
+		return time.Duration(metricas.Espera.P95), nil
+	case 99:
+		return time.Duration(metricas.Espera.P99), nil
+	}
+	return time.Duration(metricas.EsperaMedia), nil
+}
+
+// RangoRecurso son las capacidades de un recurso que se prueban, de Minimo a
+// Maximo
+type RangoRecurso struct {
+	Recurso string
+	Minimo  int
+	Maximo  int
+}
+
+// ConfiguracionCapacidad es una combinación de recursos ya simulada: lo que
+// cuesta y la espera que se midió, de media entre las repeticiones
+type ConfiguracionCapacidad struct {
+	Recursos map[string]int
+	Coste    float64
+	Espera   time.Duration
+	Cumple   bool
+}
+
+// String lista los recursos por orden alfabético ("mecanicos=3 plazas=5")
+func (c ConfiguracionCapacidad) String() string {
+	partes := make([]string, 0, len(c.Recursos))
+	for _, recurso := range recursosOrdenados(c.Recursos) {
+		partes = append(partes, fmt.Sprintf("%s=%d", recurso, c.Recursos[recurso]))
+	}
+	return strings.Join(partes, " ")
+}
+
+// PlanCapacidad es lo que sale de OptimizarCapacidad
+type PlanCapacidad struct {
+	Objetivo     ObjetivoEspera
+	Repeticiones int
+	// Simuladas son las configuraciones que se simularon, de la más barata
+	// a la más cara
+	Simuladas []ConfiguracionCapacidad
+	// Mejor es la configuración más barata que cumple, o nil si ninguna
+	Mejor *ConfiguracionCapacidad
+}
+
+// OptimizarCapacidad busca la combinación más barata de recursos con la que
+// el escenario cumple el objetivo. Prueba todas las de los rangos de la más
+// barata a la más cara y corre cada una repeticiones veces, cambiando la
+// semilla, hasta dar con una que cumpla de media. Los recursos sin rango se
+// quedan como en el escenario. Antes prueba la más cara, con el máximo de
+// todo: si esa no cumple, ninguna cumple y no se sigue.
+func OptimizarCapacidad(escenario *Escenario, rangos []RangoRecurso, costes map[string]float64, objetivo ObjetivoEspera, repeticiones int) (*PlanCapacidad, error) {
+	if err := objetivo.validar(); err != nil {
+		return nil, err
+	}
+	if repeticiones < 1 {
+		repeticiones = 1
+	}
+	configuraciones, err := configuracionesCapacidad(escenario, rangos, costes)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &PlanCapacidad{Objetivo: objetivo, Repeticiones: repeticiones}
+	maxima, err := simularCapacidad(escenario, configuraciones[len(configuraciones)-1], objetivo, repeticiones)
+	if err != nil || !maxima.Cumple {
+		plan.Simuladas = append(plan.Simuladas, maxima)
+		return plan, err
+	}
+	for i, c := range configuraciones {
+		if i == len(configuraciones)-1 {
+			c = maxima
+		} else if c, err = simularCapacidad(escenario, c, objetivo, repeticiones); err != nil {
+			return nil, err
+		}
+		plan.Simuladas = append(plan.Simuladas, c)
+		if c.Cumple {
+			plan.Mejor = &plan.Simuladas[len(plan.Simuladas)-1]
+			break
+		}
+	}
+	return plan, nil
+}
+
+// simularCapacidad corre el escenario con los recursos de c y apunta en c la
+// espera media y si cumple el objetivo
+func simularCapacidad(escenario *Escenario, c ConfiguracionCapacidad, objetivo ObjetivoEspera, repeticiones int) (ConfiguracionCapacidad, error) {
+	variante := escenario.conRecursos(c.Recursos)
+	for i := 0; i < repeticiones; i++ {
+		variante.FijarSemilla(escenario.Semilla + int64(i))
+		resultado, err := variante.Ejecutar(nil)
+		if err != nil {
+			return c, fmt.Errorf("%s: %w", c, err)
+		}
+		espera, err := objetivo.medir(resultado)
+		if err != nil {
+			return c, err
+		}
+		c.Espera += espera
+	}
+	c.Espera /= time.Duration(repeticiones)
+	c.Cumple = c.Espera <= objetivo.Maximo
+	return c, nil
+}
+
+// configuracionesCapacidad combina los rangos y ordena las combinaciones por
+// coste; a igual coste va antes la de menos unidades, así que la última es
+// la del máximo de todo
+func configuracionesCapacidad(escenario *Escenario, rangos []RangoRecurso, costes map[string]float64) ([]ConfiguracionCapacidad, error) {
+	if len(rangos) == 0 {
+		return nil, fmt.Errorf("no hay recursos que variar")
+	}
+	existentes := escenario.Pipeline().Recursos
+	total := 1
+	vistos := make(map[string]bool)
+	for _, r := range rangos {
+		if _, ok := existentes[r.Recurso]; !ok {
+			return nil, fmt.Errorf("el escenario no tiene el recurso '%s'", r.Recurso)
+		}
+		if vistos[r.Recurso] {
+			return nil, fmt.Errorf("el recurso '%s' tiene dos rangos", r.Recurso)
+		}
+		vistos[r.Recurso] = true
+		if r.Minimo < 1 || r.Maximo < r.Minimo {
+			return nil, fmt.Errorf("el rango %d-%d de %s no es válido", r.Minimo, r.Maximo, r.Recurso)
+		}
+		if coste, ok := costes[r.Recurso]; !ok || coste < 0 {
+			return nil, fmt.Errorf("falta el coste de %s", r.Recurso)
+		}
+		if total *= r.Maximo - r.Minimo + 1; total > MaximoConfiguraciones {
+			return nil, fmt.Errorf("demasiadas combinaciones, como mucho se prueban %d", MaximoConfiguraciones)
+		}
+	}
+
+	configuraciones := []ConfiguracionCapacidad{{Recursos: map[string]int{}}}
+	for _, r := range rangos {
+		var siguientes []ConfiguracionCapacidad
+		for _, c := range configuraciones {
+			for n := r.Minimo; n <= r.Maximo; n++ {
+				recursos := make(map[string]int, len(c.Recursos)+1)
+				for recurso, capacidad := range c.Recursos {
+					recursos[recurso] = capacidad
+				}
+				recursos[r.Recurso] = n
+				siguientes = append(siguientes, ConfiguracionCapacidad{Recursos: recursos, Coste: c.Coste + float64(n)*costes[r.Recurso]})
+			}
+		}
+		configuraciones = siguientes
+	}
+
+	unidades := func(c ConfiguracionCapacidad) int {
+		n := 0
+		for _, capacidad := range c.Recursos {
+			n += capacidad
+		}
+		return n
+	}
+	sort.SliceStable(configuraciones, func(i, j int) bool {
+		a, b := configuraciones[i], configuraciones[j]
+		if a.Coste != b.Coste {
+			return a.Coste < b.Coste
+		}
+		return unidades(a) < unidades(b)
+	})
+	return configuraciones, nil
+}
+
+// conRecursos da una copia del escenario con otras capacidades y su propio
+// modelo de tiempos, para poder cambiarle la semilla
+func (e *Escenario) conRecursos(capacidades map[string]int) *Escenario {
+	copia := *e
+	copia.Tiempos = e.Tiempos.copiar()
+	copia.Recursos = make(map[string]int, len(e.Recursos))
+	for recurso, capacidad := range e.Recursos {
+		copia.Recursos[recurso] = capacidad
+	}
+	copia.Especialistas = make(map[Especialidad]int, len(e.Especialistas))
+	for especialidad, n := range e.Especialistas {
+		copia.Especialistas[especialidad] = n
+	}
+	for recurso, capacidad := range capacidades {
+		switch recurso {
+		case RecursoPlazas:
+			copia.Plazas = capacidad
+		case RecursoMecanicos:
+			copia.Mecanicos = capacidad
+		case RecursoPolivalentes:
+			copia.Polivalentes = capacidad
+		case RecursoEspecialidad(EspMecanica), RecursoEspecialidad(EspElectrica), RecursoEspecialidad(EspCarroceria):
+			copia.Especialistas[Especialidad(strings.TrimPrefix(recurso, RecursoMecanicos+"_"))] = capacidad
+		default:
+			copia.Recursos[recurso] = capacidad
+		}
+	}
+	return &copia
+}
+
+// ImprimirPlanCapacidad escribe las configuraciones simuladas y la elegida
+func ImprimirPlanCapacidad(w io.Writer, plan *PlanCapacidad) {
+	fmt.Fprintf(w, "Objetivo: %v (repeticiones: %d)\n", plan.Objetivo, plan.Repeticiones)
+	fmt.Fprintf(w, "  %-30s %10s %12s  %s\n", "Recursos", "Coste", "Espera", "Cumple")
+	for _, c := range plan.Simuladas {
+		cumple := "no"
+		if c.Cumple {
+			cumple = "sí"
+		}
+		fmt.Fprintf(w, "  %-30s %10.2f %12v  %s\n", c, c.Coste, c.Espera.Round(time.Second), cumple)
+	}
+	if plan.Mejor == nil {
+		fmt.Fprintln(w, "Ninguna configuración cumple el objetivo, ni siquiera con el máximo de todo")
+		return
+	}
+	fmt.Fprintf(w, "La más barata que cumple: %s (coste %.2f, espera %v)\n",
+		plan.Mejor, plan.Mejor.Coste, plan.Mejor.Espera.Round(time.Second))
+}
+
+// EscribirPlanCapacidadCSV escribe una fila por configuración simulada, con
+// una columna por recurso; la espera va en segundos
+func EscribirPlanCapacidadCSV(w io.Writer, plan *PlanCapacidad) error {
+	escritor := csv.NewWriter(w)
+	var recursos []string
+	if len(plan.Simuladas) > 0 {
+		recursos = recursosOrdenados(plan.Simuladas[0].Recursos)
+	}
+	escritor.Write(append(append([]string(nil), recursos...), "coste", "espera_s", "cumple"))
+	for _, c := range plan.Simuladas {
+		var fila []string
+		for _, recurso := range recursos {
+			fila = append(fila, strconv.Itoa(c.Recursos[recurso]))
+		}
+		fila = append(fila,
+			strconv.FormatFloat(c.Coste, 'f', 2, 64),
+			segundos(Duracion(c.Espera)),
+			strconv.FormatBool(c.Cumple),
+		)
+		escritor.Write(fila)
+	}
+	escritor.Flush()
+	return escritor.Error()
+}
+
+func recursosOrdenados(recursos map[string]int) []string {
+	nombres := make([]string, 0, len(recursos))
+	for recurso := range recursos {
+		nombres = append(nombres, recurso)
+	}
+	sort.Strings(nombres)
+	return nombres
+}
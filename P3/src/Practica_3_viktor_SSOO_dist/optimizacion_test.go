@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestOptimizarCapacidad busca los recursos para el día normal y comprueba
+// que las combinaciones más baratas que la elegida no cumplen
+func TestOptimizarCapacidad(t *testing.T) {
+	escenario, err := CargarEscenario(filepath.Join("escenarios", "dia_normal.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rangos, costes, objetivo, err := capacidadDesdeOpciones("plazas=2-8,mecanicos=1-3", "plazas=40,mecanicos=160", "mecanica", 95, 30*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan, err := OptimizarCapacidad(escenario, rangos, costes, objetivo, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Mejor == nil || plan.Mejor.Espera > 30*time.Minute {
+		t.Fatalf("se esperaba una configuración que cumpliera: %+v", plan.Mejor)
+	}
+	for _, c := range plan.Simuladas[1 : len(plan.Simuladas)-1] {
+		if c.Cumple || c.Coste > plan.Mejor.Coste {
+			t.Errorf("%s (coste %.0f) no debía cumplir ni costar más que la elegida", c, c.Coste)
+		}
+	}
+	var tabla bytes.Buffer
+	ImprimirPlanCapacidad(&tabla, plan)
+	if !strings.Contains(tabla.String(), "La más barata que cumple: "+plan.Mejor.String()) {
+		t.Errorf("la tabla no dice la elegida:\n%s", tabla.String())
+	}
+
+	// Con un objetivo imposible basta con probar la más cara
+	objetivo.Maximo = time.Second
+	plan, err = OptimizarCapacidad(escenario, rangos, costes, objetivo, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Mejor != nil || len(plan.Simuladas) != 1 || plan.Simuladas[0].String() != "mecanicos=3 plazas=8" {
+		t.Fatalf("plan inesperado: %+v", plan)
+	}
+}
+
+func TestCapacidadNoValida(t *testing.T) {
+	escenario, _ := CargarEscenario(filepath.Join("escenarios", "dia_normal.yaml"))
+	casos := [][3]string{
+		{"plazas=2-8", "plazas=40", "pintura"},
+		{"plazas=8-2", "plazas=40", "mecanica"},
+		{"plazas=2-8,elevadores=1-2", "plazas=40,elevadores=10", "mecanica"},
+		{"plazas=2-8,mecanicos=1-3", "plazas=40", "mecanica"},
+		{"plazas", "plazas=40", "mecanica"},
+		{"plazas=1-2", "plazas=gratis", "mecanica"},
+	}
+	for _, c := range casos {
+		rangos, costes, objetivo, err := capacidadDesdeOpciones(c[0], c[1], c[2], 95, time.Minute)
+		if err == nil {
+			_, err = OptimizarCapacidad(escenario, rangos, costes, objetivo, 1)
+		}
+		if err == nil {
+			t.Errorf("%v: se esperaba un error", c)
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ============================================================================
+// FASES DE LA SIMULACIÓN
+// ============================================================================
+
+// Recursos del taller de siempre
+const (
+	RecursoPlazas    = "plazas"
+	RecursoMecanicos = "mecanicos"
+	// RecursoPolivalentes son los mecánicos que saben de todo en
+	// PipelineTallerEspecialistas
+	RecursoPolivalentes = "mecanicos_polivalentes"
+)
+
+// RecursoEspecialidad es el recurso de los mecánicos de una especialidad en
+// PipelineTallerEspecialistas ("mecanicos_electrica")
+func RecursoEspecialidad(especialidad Especialidad) string {
+	return RecursoMecanicos + "_" + string(especialidad)
+}
+
+// Especialidades son todas las especialidades de los mecánicos
+func Especialidades() []Especialidad {
+	return []Especialidad{EspMecanica, EspElectrica, EspCarroceria}
+}
+
+// especialidadDe es la especialidad que hace falta para una incidencia
+func especialidadDe(tipo TipoIncidencia) Especialidad {
+	switch tipo {
+	case Electrica:
+		return EspElectrica
+	case Carroceria:
+		return EspCarroceria
+	}
+	return EspMecanica
+}
+
+// Fase es un paso por el que pasan todos los coches. Al empezar coge una
+// unidad de cada recurso de Adquiere (esperando si no hay) y al acabar
+// suelta las de Libera, que pueden ser recursos cogidos en fases
+// anteriores: la plaza se coge en la Entrada y se suelta tras la Revisión.
+type Fase struct {
+	Nombre   string
+	Adquiere []string
+	Libera   []string
+	// Tiempo es lo que tarda un coche en la fase; si es nil, su TiempoEn la
+	// fase
+	Tiempo func(v *Vehiculo) time.Duration
+	// Trabajadores es cuántos coches pueden estar a la vez en la fase, sin
+	// contar los recursos; 0 es sin límite
+	Trabajadores int
+}
+
+func (f Fase) duracion(v *Vehiculo) time.Duration {
+	if f.Tiempo == nil {
+		return v.TiempoEn(f.Nombre)
+	}
+	return f.Tiempo(v)
+}
+
+// GrupoRecurso es una parte de un recurso repartido que solo atiende a los
+// coches de ciertas especialidades, como los mecánicos de eléctrica. Sus
+// unidades son las de Recurso, que es un recurso más del pipeline.
+type GrupoRecurso struct {
+	Recurso        string
+	Especialidades []Especialidad
+}
+
+func (g GrupoRecurso) atiende(especialidad Especialidad) bool {
+	for _, e := range g.Especialidades {
+		if e == especialidad {
+			return true
+		}
+	}
+	return false
+}
+
+// Pipeline son las fases, en orden, y cuántas unidades hay de cada recurso.
+// Se monta con NewPipeline, ConRecurso, ConGrupos y ConFase; las
+// simulaciones suponen que es válido (Validar).
+type Pipeline struct {
+	Fases    []Fase
+	Recursos map[string]int
+	// Grupos son los recursos repartidos: una fase que coge uno de ellos
+	// coge en realidad una unidad del primer grupo con sitio que atienda la
+	// especialidad del coche, y la suelta cuando la fase suelta el recurso
+	Grupos map[string][]GrupoRecurso
+}
+
+// NewPipeline crea un pipeline sin fases ni recursos
+func NewPipeline() *Pipeline {
+	return &Pipeline{Recursos: make(map[string]int), Grupos: make(map[string][]GrupoRecurso)}
+}
+
+// ConRecurso añade (o cambia) un recurso con su capacidad
+func (p *Pipeline) ConRecurso(nombre string, capacidad int) *Pipeline {
+	p.Recursos[nombre] = capacidad
+	return p
+}
+
+// ConGrupos reparte el recurso nombre entre grupos, por orden de
+// preferencia. Los recursos de los grupos se añaden aparte con ConRecurso.
+func (p *Pipeline) ConGrupos(nombre string, grupos ...GrupoRecurso) *Pipeline {
+	p.Grupos[nombre] = grupos
+	return p
+}
+
+// ConFase añade una fase al final
+func (p *Pipeline) ConFase(fase Fase) *Pipeline {
+	p.Fases = append(p.Fases, fase)
+	return p
+}
+
+// PipelineTaller es el taller de siempre: Entrada, Reparación, Limpieza y
+// Revisión Final, con una plaza desde la Entrada hasta el final y un
+// mecánico durante la Reparación
+func PipelineTaller(numPlazas, numMecanicos int) *Pipeline {
+	return NewPipeline().
+		ConRecurso(RecursoPlazas, numPlazas).
+		ConRecurso(RecursoMecanicos, numMecanicos).
+		ConFase(Fase{Nombre: "Entrada", Adquiere: []string{RecursoPlazas}}).
+		ConFase(Fase{Nombre: "Reparación", Adquiere: []string{RecursoMecanicos}, Libera: []string{RecursoMecanicos}}).
+		ConFase(Fase{Nombre: "Limpieza"}).
+		ConFase(Fase{Nombre: "Revisión Final", Libera: []string{RecursoPlazas}})
+}
+
+// PipelineTallerEspecialistas es el taller de siempre con los mecánicos
+// separados por especialidad (ConEspecialistas)
+func PipelineTallerEspecialistas(numPlazas int, mecanicos map[Especialidad]int, polivalentes int) *Pipeline {
+	return PipelineTaller(numPlazas, 0).ConEspecialistas(mecanicos, polivalentes)
+}
+
+// ConEspecialistas reparte los mecánicos por especialidad: cada coche lo
+// repara un mecánico de la suya o, si no hay ninguno libre, uno de los
+// polivalentes, que saben de todo. Las especialidades sin mecánicos solo las
+// atienden los polivalentes.
+func (p *Pipeline) ConEspecialistas(mecanicos map[Especialidad]int, polivalentes int) *Pipeline {
+	delete(p.Recursos, RecursoMecanicos)
+	var grupos []GrupoRecurso
+	for _, especialidad := range Especialidades() {
+		if mecanicos[especialidad] > 0 {
+			recurso := RecursoEspecialidad(especialidad)
+			p.ConRecurso(recurso, mecanicos[especialidad])
+			grupos = append(grupos, GrupoRecurso{Recurso: recurso, Especialidades: []Especialidad{especialidad}})
+		}
+	}
+	if polivalentes > 0 {
+		p.ConRecurso(RecursoPolivalentes, polivalentes)
+		grupos = append(grupos, GrupoRecurso{Recurso: RecursoPolivalentes, Especialidades: Especialidades()})
+	}
+	return p.ConGrupos(RecursoMecanicos, grupos...)
+}
+
+// Validar comprueba que las fases se pueden recorrer: que los recursos
+// existen y que cada coche suelta todo lo que coge, sin coger dos veces lo
+// mismo. No mira si hay grupos para todas las especialidades (Atender).
+func (p *Pipeline) Validar() error {
+	if len(p.Fases) == 0 {
+		return fmt.Errorf("el pipeline no tiene fases")
+	}
+	for nombre, capacidad := range p.Recursos {
+		if capacidad <= 0 {
+			return fmt.Errorf("el recurso '%s' necesita al menos una unidad", nombre)
+		}
+	}
+	repartidos := make(map[string]bool)
+	for nombre, grupos := range p.Grupos {
+		if _, ok := p.Recursos[nombre]; ok {
+			return fmt.Errorf("el recurso '%s' no puede tener unidades propias y grupos", nombre)
+		}
+		for _, g := range grupos {
+			if _, ok := p.Recursos[g.Recurso]; !ok {
+				return fmt.Errorf("%s: el grupo '%s' no es un recurso", nombre, g.Recurso)
+			}
+			if repartidos[g.Recurso] {
+				return fmt.Errorf("%s: el grupo '%s' está repetido", nombre, g.Recurso)
+			}
+			repartidos[g.Recurso] = true
+			if len(g.Especialidades) == 0 {
+				return fmt.Errorf("%s: el grupo '%s' no atiende ninguna especialidad", nombre, g.Recurso)
+			}
+		}
+	}
+	existe := func(recurso string) bool {
+		_, propio := p.Recursos[recurso]
+		_, repartido := p.Grupos[recurso]
+		return (propio && !repartidos[recurso]) || repartido
+	}
+
+	nombres := make(map[string]bool)
+	cogidos := make(map[string]bool)
+	for _, fase := range p.Fases {
+		if fase.Nombre == "" || nombres[fase.Nombre] {
+			return fmt.Errorf("las fases necesitan un nombre distinto cada una ('%s')", fase.Nombre)
+		}
+		nombres[fase.Nombre] = true
+		if fase.Trabajadores < 0 {
+			return fmt.Errorf("%s: el número de trabajadores no puede ser negativo", fase.Nombre)
+		}
+		for _, recurso := range fase.Adquiere {
+			if !existe(recurso) {
+				return fmt.Errorf("%s: el recurso '%s' no existe", fase.Nombre, recurso)
+			}
+			if cogidos[recurso] {
+				return fmt.Errorf("%s: el recurso '%s' ya se cogió antes", fase.Nombre, recurso)
+			}
+			cogidos[recurso] = true
+		}
+		for _, recurso := range fase.Libera {
+			if !cogidos[recurso] {
+				return fmt.Errorf("%s: se suelta el recurso '%s' sin haberlo cogido", fase.Nombre, recurso)
+			}
+			delete(cogidos, recurso)
+		}
+	}
+	if len(cogidos) > 0 {
+		var pendientes []string
+		for recurso := range cogidos {
+			pendientes = append(pendientes, recurso)
+		}
+		sort.Strings(pendientes)
+		return fmt.Errorf("los recursos %v no se sueltan nunca", pendientes)
+	}
+	return nil
+}
+
+// Atender comprueba que los coches de la incidencia pueden coger todo lo que
+// piden las fases, es decir, que algún grupo de cada recurso repartido
+// atiende su especialidad
+func (p *Pipeline) Atender(tipo TipoIncidencia) error {
+	for _, fase := range p.Fases {
+		for _, recurso := range fase.Adquiere {
+			if _, repartido := p.Grupos[recurso]; repartido && len(p.alternativas(recurso, tipo)) == 0 {
+				return fmt.Errorf("%s: nadie de '%s' atiende la especialidad %s", fase.Nombre, recurso, especialidadDe(tipo))
+			}
+		}
+	}
+	return nil
+}
+
+// alternativas son los recursos de los que puede sacar una unidad un coche
+// de la incidencia cuando una fase pide recurso, por orden de preferencia
+func (p *Pipeline) alternativas(recurso string, tipo TipoIncidencia) []string {
+	grupos, repartido := p.Grupos[recurso]
+	if !repartido {
+		return []string{recurso}
+	}
+	var alternativas []string
+	for _, g := range grupos {
+		if g.atiende(especialidadDe(tipo)) {
+			alternativas = append(alternativas, g.Recurso)
+		}
+	}
+	return alternativas
+}
+
+// alternativasFase son las alternativas de cada recurso que coge v al
+// empezar la fase
+func (p *Pipeline) alternativasFase(fase int, v *Vehiculo) [][]string {
+	adquiere := p.Fases[fase].Adquiere
+	alternativas := make([][]string, len(adquiere))
+	for i, recurso := range adquiere {
+		alternativas[i] = p.alternativas(recurso, v.Incidencia)
+	}
+	return alternativas
+}
+
+// NombresFases devuelve los nombres de las fases en orden
+func (p *Pipeline) NombresFases() []string {
+	nombres := make([]string, len(p.Fases))
+	for i, fase := range p.Fases {
+		nombres[i] = fase.Nombre
+	}
+	return nombres
+}
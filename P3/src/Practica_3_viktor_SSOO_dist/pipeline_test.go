@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPipelineValidar(t *testing.T) {
+	if err := PipelineTaller(5, 3).Validar(); err != nil {
+		t.Fatalf("el pipeline del taller no es válido: %v", err)
+	}
+
+	casos := map[string]*Pipeline{
+		"sin fases":       NewPipeline().ConRecurso(RecursoPlazas, 1),
+		"sin capacidad":   PipelineTaller(0, 3),
+		"recurso":         NewPipeline().ConFase(Fase{Nombre: "Pintura", Adquiere: []string{"cabinas"}, Libera: []string{"cabinas"}}),
+		"nombre repetido": NewPipeline().ConFase(Fase{Nombre: "Entrada"}).ConFase(Fase{Nombre: "Entrada"}),
+		"sin soltar":      NewPipeline().ConRecurso(RecursoPlazas, 1).ConFase(Fase{Nombre: "Entrada", Adquiere: []string{RecursoPlazas}}),
+		"sin coger":       NewPipeline().ConRecurso(RecursoPlazas, 1).ConFase(Fase{Nombre: "Salida", Libera: []string{RecursoPlazas}}),
+		"coger dos veces": NewPipeline().ConRecurso(RecursoPlazas, 2).
+			ConFase(Fase{Nombre: "Entrada", Adquiere: []string{RecursoPlazas}}).
+			ConFase(Fase{Nombre: "Otra", Adquiere: []string{RecursoPlazas}, Libera: []string{RecursoPlazas}}),
+		"trabajadores": NewPipeline().ConFase(Fase{Nombre: "Entrada", Trabajadores: -1}),
+	}
+	for nombre, pipeline := range casos {
+		if err := pipeline.Validar(); err == nil {
+			t.Errorf("%s: se esperaba un error", nombre)
+		}
+	}
+}
+
+// TestPipelinePersonalizado añade una fase de secado de duración fija con un
+// solo trabajador y comprueba el resultado a mano
+func TestPipelinePersonalizado(t *testing.T) {
+	pipeline := NewPipeline().
+		ConRecurso(RecursoPlazas, 2).
+		ConFase(Fase{Nombre: "Entrada", Adquiere: []string{RecursoPlazas}}).
+		ConFase(Fase{Nombre: "Secado", Trabajadores: 1, Tiempo: func(*Vehiculo) time.Duration { return 10 * time.Second }}).
+		ConFase(Fase{Nombre: "Salida", Libera: []string{RecursoPlazas}})
+	if err := pipeline.Validar(); err != nil {
+		t.Fatal(err)
+	}
+
+	vehiculos := []*Vehiculo{NewVehiculo(1, Carroceria), NewVehiculo(2, Carroceria)}
+	vehiculos[1].TiempoLlegada = vehiculos[0].TiempoLlegada.Add(time.Nanosecond)
+
+	// Los dos entran a la vez; el 1 seca de 1 a 11s y el 2 de 11 a 21s
+	var salida bytes.Buffer
+	resultado, err := SimularPipelineEventos(pipeline, vehiculos, &salida)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if time.Duration(resultado.Duracion).Round(time.Millisecond) != 22*time.Second {
+		t.Fatalf("la simulación duró %v, se esperaban 22s", resultado.Duracion)
+	}
+	if !strings.Contains(salida.String(), "Tiempo 11s Coche 2 Incidencia Carrocería Fase Secado Estado En Proceso\n") {
+		t.Fatalf("el coche 2 no empezó a secarse a los 11s:\n%s", salida.String())
+	}
+	if len(resultado.Fases) != 3 || resultado.Fases[1].Fase != "Secado" || time.Duration(resultado.Fases[1].EsperaMedia).Round(time.Millisecond) != 5*time.Second {
+		t.Fatalf("métricas de fases inesperadas: %+v", resultado.Fases)
+	}
+}
+
+// TestPipelineConGoroutines corre un pipeline de cinco fases con las dos
+// implementaciones con goroutines
+func TestPipelineConGoroutines(t *testing.T) {
+	pipeline := PipelineTaller(3, 2).ConRecurso("elevadores", 1)
+	pipeline.Fases = append(pipeline.Fases[:1], append([]Fase{
+		{Nombre: "Diagnóstico", Adquiere: []string{"elevadores"}, Libera: []string{"elevadores"}, Trabajadores: 1},
+	}, pipeline.Fases[1:]...)...)
+
+	simulaciones := map[string]func(*Pipeline, []*Vehiculo, Reloj) (*ResultadoSimulacion, error){
+		"RWMutex":   SimularPipelineRWMutex,
+		"WaitGroup": SimularPipelineWaitGroup,
+	}
+	for nombre, simular := range simulaciones {
+		resultado, err := simularConRelojAutomatico(simular, pipeline, generarVehiculos(2, 2, 2))
+		if err != nil {
+			t.Fatalf("%s: %v", nombre, err)
+		}
+		if resultado.Global.Vehiculos != 6 || len(resultado.Fases) != 5 {
+			t.Fatalf("%s: resultado inesperado: %+v", nombre, resultado)
+		}
+		for _, fase := range resultado.Fases {
+			if fase.Atendidos != 6 {
+				t.Errorf("%s: la fase %s atendió %d coches", nombre, fase.Fase, fase.Atendidos)
+			}
+		}
+	}
+}
+
+// TestPipelineEspecialistas comprueba los grupos de mecánicos por
+// especialidad
+func TestPipelineEspecialistas(t *testing.T) {
+	pipeline := PipelineTallerEspecialistas(4, map[Especialidad]int{EspMecanica: 2, EspElectrica: 1}, 0)
+	if err := pipeline.Validar(); err != nil {
+		t.Fatal(err)
+	}
+	if err := pipeline.Atender(Electrica); err != nil {
+		t.Fatal(err)
+	}
+	if err := pipeline.Atender(Carroceria); err == nil {
+		t.Fatal("nadie sabe de carrocería y se esperaba un error")
+	}
+	if err := pipeline.ConEspecialistas(map[Especialidad]int{EspMecanica: 2}, 1).Atender(Carroceria); err != nil {
+		t.Fatalf("los polivalentes deberían atender carrocería: %v", err)
+	}
+
+	base := func() *Pipeline { return PipelineTaller(4, 0).ConRecurso("expertos", 1) }
+	casos := map[string]*Pipeline{
+		"unidades propias":   PipelineTaller(4, 2).ConGrupos(RecursoMecanicos, GrupoRecurso{Recurso: RecursoPlazas, Especialidades: Especialidades()}),
+		"grupo sin recurso":  base().ConGrupos(RecursoMecanicos, GrupoRecurso{Recurso: "aprendices", Especialidades: Especialidades()}),
+		"grupo repetido":     base().ConGrupos(RecursoMecanicos, GrupoRecurso{Recurso: "expertos", Especialidades: Especialidades()}, GrupoRecurso{Recurso: "expertos", Especialidades: Especialidades()}),
+		"sin especialidades": base().ConGrupos(RecursoMecanicos, GrupoRecurso{Recurso: "expertos"}),
+	}
+	for nombre, pipeline := range casos {
+		if err := pipeline.Validar(); err == nil {
+			t.Errorf("%s: se esperaba un error", nombre)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"sort"
+	"time"
+)
+
+// EnvejecimientoDefecto es lo que tiene que esperar un trabajo para subir un
+// nivel de prioridad
+const EnvejecimientoDefecto = 2 * time.Minute
+
+// antesEnCola dice si el trabajo a debe atenderse antes que b. Cada trabajo
+// sube un nivel de prioridad por cada intervalo de envejecimiento que lleva
+// esperando, así que uno de prioridad baja acaba pasando por delante de los
+// de alta que lleguen mucho después. Como todos envejecen al mismo ritmo el
+// orden entre dos trabajos no cambia con el tiempo. Con envejecimiento <= 0
+// solo cuenta la prioridad y, a igualdad, el orden de llegada.
+func antesEnCola(prioridadA Prioridad, llegadaA time.Time, prioridadB Prioridad, llegadaB time.Time, envejecimiento time.Duration) bool {
+	if envejecimiento <= 0 {
+		if prioridadA != prioridadB {
+			return prioridadA > prioridadB
+		}
+		return llegadaA.Before(llegadaB)
+	}
+
+	// a va antes si su ventaja de prioridad compensa lo que llegó más tarde
+	ventaja := time.Duration(prioridadA-prioridadB) * envejecimiento
+	retraso := llegadaA.Sub(llegadaB)
+	if ventaja != retraso {
+		return ventaja > retraso
+	}
+	return llegadaA.Before(llegadaB)
+}
+
+// ordenarCola deja la cola en el orden en que deben atenderse los trabajos
+func ordenarCola(cola []*TrabajoMecanico, envejecimiento time.Duration) {
+	sort.SliceStable(cola, func(i, j int) bool {
+		return antesEnCola(cola[i].Incidencia.Prioridad, cola[i].Llegada, cola[j].Incidencia.Prioridad, cola[j].Llegada, envejecimiento)
+	})
+}
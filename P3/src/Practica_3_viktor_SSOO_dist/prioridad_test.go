@@ -0,0 +1,85 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// TestOrdenarCola comprueba el orden por prioridad, el desempate por llegada
+// y que el envejecimiento evita que los de prioridad baja esperen siempre
+func TestOrdenarCola(t *testing.T) {
+	base := time.Now()
+	trabajo := func(id int, prioridad Prioridad, llegada time.Duration) *TrabajoMecanico {
+		return &TrabajoMecanico{
+			Vehiculo:   VehiculoCompleto{ID: id},
+			Incidencia: IncidenciaCompleta{ID: id, Prioridad: prioridad},
+			Llegada:    base.Add(llegada),
+		}
+	}
+
+	casos := []struct {
+		nombre         string
+		envejecimiento time.Duration
+		esperado       []int
+	}{
+		// 1: baja al principio; 2: media a los 30s; 3: alta a los 90s; 4: alta a los 10s
+		{"sin envejecimiento", 0, []int{4, 3, 2, 1}},
+		{"envejecimiento lento", time.Hour, []int{4, 3, 2, 1}},
+		{"envejecimiento rápido", 20 * time.Second, []int{4, 1, 2, 3}},
+	}
+	for _, caso := range casos {
+		cola := []*TrabajoMecanico{
+			trabajo(1, PrioridadBaja, 0),
+			trabajo(2, PrioridadMedia, 30*time.Second),
+			trabajo(3, PrioridadAlta, 90*time.Second),
+			trabajo(4, PrioridadAlta, 10*time.Second),
+		}
+		ordenarCola(cola, caso.envejecimiento)
+		if got := idsCola(cola); !slices.Equal(got, caso.esperado) {
+			t.Errorf("%s: orden %v, se esperaba %v", caso.nombre, got, caso.esperado)
+		}
+	}
+}
+
+// TestAsignarPorPrioridad comprueba que el taller reparte primero lo urgente
+// y que un trabajo sin hueco no bloquea los de otras especialidades
+func TestAsignarPorPrioridad(t *testing.T) {
+	mm := NewMecanicoManager()
+	taller := NewTaller(mm, NewVehiculoManager(), NewIncidenciaManager())
+	taller.Envejecimiento = 0
+	mec := mm.CrearMecanico("Luis", EspMecanica, 5)
+	ele := mm.CrearMecanico("Marta", EspElectrica, 5)
+
+	agregar := func(id int, tipo TipoIncidencia, prioridad Prioridad) {
+		taller.ColaTrabajo = append(taller.ColaTrabajo, &TrabajoMecanico{
+			Vehiculo:   VehiculoCompleto{ID: id},
+			Incidencia: IncidenciaCompleta{ID: id, Tipo: tipo, Prioridad: prioridad},
+			Llegada:    time.Now(),
+		})
+	}
+	agregar(1, Mecanica, PrioridadBaja)
+	agregar(2, Mecanica, PrioridadBaja)
+	agregar(3, Mecanica, PrioridadMedia)
+	agregar(4, Mecanica, PrioridadAlta)
+	agregar(5, Electrica, PrioridadBaja)
+	taller.AsignarTrabajosAutomaticamente()
+
+	if got := []int{mec.ColaPersonal[0].Vehiculo.ID, mec.ColaPersonal[1].Vehiculo.ID}; !slices.Equal(got, []int{4, 3}) {
+		t.Fatalf("el mecánico recibió %v, se esperaba [4 3]", got)
+	}
+	if len(ele.ColaPersonal) != 1 {
+		t.Fatal("el trabajo eléctrico no debía quedarse detrás de los mecánicos")
+	}
+	if got := idsCola(taller.ColaTrabajo); !slices.Equal(got, []int{1, 2}) {
+		t.Fatalf("quedan en cola %v, se esperaba [1 2]", got)
+	}
+}
+
+func idsCola(cola []*TrabajoMecanico) []int {
+	ids := make([]int, len(cola))
+	for i, tr := range cola {
+		ids[i] = tr.Vehiculo.ID
+	}
+	return ids
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// RELOJES
+// ============================================================================
+
+// Reloj es de donde sacan la hora y las esperas el taller y las simulaciones.
+// Con un reloj que no sea el real el mismo escenario se puede correr mucho
+// más deprisa, y las duraciones que se informan son las simuladas.
+type Reloj interface {
+	Ahora() time.Time
+	Desde(t time.Time) time.Duration
+	Dormir(d time.Duration)
+	Despues(d time.Duration) <-chan time.Time
+}
+
+// RelojReal usa la hora del sistema
+type RelojReal struct{}
+
+func (RelojReal) Ahora() time.Time                         { return time.Now() }
+func (RelojReal) Desde(t time.Time) time.Duration          { return time.Since(t) }
+func (RelojReal) Dormir(d time.Duration)                   { time.Sleep(d) }
+func (RelojReal) Despues(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// RelojEscalado va Factor veces más deprisa que el real. Sirve para cualquier
+// código, pero como sigue dependiendo del planificador los resultados
+// cambian un poco de una ejecución a otra.
+type RelojEscalado struct {
+	Factor float64
+	inicio time.Time
+}
+
+// NewRelojEscalado crea un reloj que empieza ahora y corre factor veces más
+// deprisa
+func NewRelojEscalado(factor float64) *RelojEscalado {
+	return &RelojEscalado{Factor: factor, inicio: time.Now()}
+}
+
+func (r *RelojEscalado) Ahora() time.Time {
+	return r.inicio.Add(time.Duration(float64(time.Since(r.inicio)) * r.Factor))
+}
+
+func (r *RelojEscalado) Desde(t time.Time) time.Duration { return r.Ahora().Sub(t) }
+
+func (r *RelojEscalado) Dormir(d time.Duration) {
+	time.Sleep(time.Duration(float64(d) / r.Factor))
+}
+
+func (r *RelojEscalado) Despues(d time.Duration) <-chan time.Time {
+	aviso := make(chan time.Time, 1)
+	time.AfterFunc(time.Duration(float64(d)/r.Factor), func() { aviso <- r.Ahora() })
+	return aviso
+}
+
+// RelojVirtual solo avanza cuando se le pide (Avanzar, AvanzarHasta) o, en
+// modo Automatico, cuando todas las goroutines que lo usan están esperando:
+// entonces salta directamente a la siguiente espera que vence. Una simulación
+// de horas tarda así lo que tarden sus cálculos, y las esperas que vencen a
+// la vez se despiertan siempre en el orden en que se pidieron.
+type RelojVirtual struct {
+	mutex     sync.Mutex
+	ahora     time.Time
+	esperas   esperasReloj
+	secuencia int
+
+	// actividad cuenta las llamadas al reloj, para saber si alguien sigue
+	// trabajando, y despertando las goroutines que se han despertado de
+	// Dormir y aún no han vuelto a correr
+	actividad   uint64
+	despertando int
+}
+
+// NewRelojVirtual crea un reloj parado en inicio
+func NewRelojVirtual(inicio time.Time) *RelojVirtual {
+	return &RelojVirtual{ahora: inicio}
+}
+
+func (r *RelojVirtual) Ahora() time.Time {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.ahora
+}
+
+func (r *RelojVirtual) Desde(t time.Time) time.Duration { return r.Ahora().Sub(t) }
+
+// Dormir bloquea hasta que el reloj llega a ahora+d
+func (r *RelojVirtual) Dormir(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	<-r.esperar(d, true)
+
+	r.mutex.Lock()
+	r.despertando--
+	r.actividad++
+	r.mutex.Unlock()
+}
+
+// Despues devuelve un canal que recibe la hora cuando el reloj llega a
+// ahora+d
+func (r *RelojVirtual) Despues(d time.Duration) <-chan time.Time {
+	return r.esperar(d, false)
+}
+
+func (r *RelojVirtual) esperar(d time.Duration, dormido bool) chan time.Time {
+	aviso := make(chan time.Time, 1)
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.actividad++
+	if d <= 0 {
+		aviso <- r.ahora
+		return aviso
+	}
+	r.secuencia++
+	heap.Push(&r.esperas, &esperaReloj{instante: r.ahora.Add(d), orden: r.secuencia, aviso: aviso, dormido: dormido})
+	return aviso
+}
+
+// Avanzar mueve el reloj d hacia delante despertando lo que venza por el
+// camino
+func (r *RelojVirtual) Avanzar(d time.Duration) {
+	r.AvanzarHasta(r.Ahora().Add(d))
+}
+
+// AvanzarHasta mueve el reloj hasta t (nunca hacia atrás). Cada espera se
+// despierta con el reloj marcando su instante.
+func (r *RelojVirtual) AvanzarHasta(t time.Time) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for r.esperas.Len() > 0 && !r.esperas[0].instante.After(t) {
+		r.despertar(heap.Pop(&r.esperas).(*esperaReloj))
+	}
+	if t.After(r.ahora) {
+		r.ahora = t
+	}
+}
+
+// Siguiente dice cuándo vence la próxima espera
+func (r *RelojVirtual) Siguiente() (time.Time, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.esperas.Len() == 0 {
+		return time.Time{}, false
+	}
+	return r.esperas[0].instante, true
+}
+
+// Automatico hace avanzar el reloj solo: cada vez que pasa reposo (tiempo
+// real) sin que nadie use el reloj y hay esperas pendientes, salta a la
+// siguiente. Devuelve la función para pararlo.
+func (r *RelojVirtual) Automatico(reposo time.Duration) func() {
+	parar := make(chan struct{})
+	parado := make(chan struct{})
+	go func() {
+		defer close(parado)
+		tic := time.NewTicker(reposo)
+		defer tic.Stop()
+		var ultima uint64
+		for {
+			select {
+			case <-parar:
+				return
+			case <-tic.C:
+			}
+
+			r.mutex.Lock()
+			if r.actividad == ultima && r.despertando == 0 && r.esperas.Len() > 0 {
+				instante := r.esperas[0].instante
+				for r.esperas.Len() > 0 && !r.esperas[0].instante.After(instante) {
+					r.despertar(heap.Pop(&r.esperas).(*esperaReloj))
+				}
+			}
+			ultima = r.actividad
+			r.mutex.Unlock()
+		}
+	}()
+	return func() {
+		close(parar)
+		<-parado
+	}
+}
+
+// despertar se llama con el mutex cogido
+func (r *RelojVirtual) despertar(e *esperaReloj) {
+	if e.instante.After(r.ahora) {
+		r.ahora = e.instante
+	}
+	if e.dormido {
+		r.despertando++
+	}
+	r.actividad++
+	e.aviso <- r.ahora
+}
+
+type esperaReloj struct {
+	instante time.Time
+	orden    int
+	aviso    chan time.Time
+	dormido  bool
+}
+
+// esperasReloj implementa heap.Interface por instante y, a igualdad, por
+// orden de llegada
+type esperasReloj []*esperaReloj
+
+func (e esperasReloj) Len() int { return len(e) }
+
+func (e esperasReloj) Less(i, j int) bool {
+	if !e[i].instante.Equal(e[j].instante) {
+		return e[i].instante.Before(e[j].instante)
+	}
+	return e[i].orden < e[j].orden
+}
+
+func (e esperasReloj) Swap(i, j int) { e[i], e[j] = e[j], e[i] }
+
+func (e *esperasReloj) Push(x interface{}) { *e = append(*e, x.(*esperaReloj)) }
+
+func (e *esperasReloj) Pop() interface{} {
+	old := *e
+	n := len(old)
+	item := old[n-1]
+	*e = old[:n-1]
+	return item
+}
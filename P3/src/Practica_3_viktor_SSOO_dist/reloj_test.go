@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestRelojVirtual comprueba que el reloj solo avanza cuando se le pide y
+// que despierta las esperas en orden
+func TestRelojVirtual(t *testing.T) {
+	inicio := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+	reloj := NewRelojVirtual(inicio)
+
+	tarde := reloj.Despues(2 * time.Hour)
+	pronto := reloj.Despues(30 * time.Minute)
+	if siguiente, _ := reloj.Siguiente(); !siguiente.Equal(inicio.Add(30 * time.Minute)) {
+		t.Fatalf("la siguiente espera vence a las %v", siguiente)
+	}
+
+	reloj.Avanzar(time.Hour)
+	select {
+	case instante := <-pronto:
+		if !instante.Equal(inicio.Add(30 * time.Minute)) {
+			t.Fatalf("la espera de 30m se despertó a las %v", instante)
+		}
+	default:
+		t.Fatal("la espera de 30m debía haber vencido")
+	}
+	select {
+	case <-tarde:
+		t.Fatal("la espera de 2h no debía haber vencido")
+	default:
+	}
+	if reloj.Desde(inicio) != time.Hour {
+		t.Fatalf("el reloj marca %v desde el inicio", reloj.Desde(inicio))
+	}
+}
+
+// TestRelojVirtualAutomatico lanza goroutines que duermen horas y comprueba
+// que acaban al momento, en orden y con el tiempo simulado correcto
+func TestRelojVirtualAutomatico(t *testing.T) {
+	inicio := time.Now()
+	reloj := NewRelojVirtual(inicio)
+	parar := reloj.Automatico(50 * time.Microsecond)
+	defer parar()
+
+	var mutex sync.Mutex
+	var orden []int
+	var wg sync.WaitGroup
+	for _, horas := range []int{3, 1, 2} {
+		wg.Add(1)
+		go func(horas int) {
+			defer wg.Done()
+			for i := 0; i < horas; i++ {
+				reloj.Dormir(time.Hour)
+			}
+			mutex.Lock()
+			orden = append(orden, horas)
+			mutex.Unlock()
+		}(horas)
+	}
+	wg.Wait()
+
+	if fmt.Sprint(orden) != "[1 2 3]" {
+		t.Fatalf("las goroutines acabaron en orden %v", orden)
+	}
+	if transcurrido := reloj.Desde(inicio); transcurrido != 3*time.Hour {
+		t.Fatalf("se simularon %v, se esperaban 3h", transcurrido)
+	}
+	if real := time.Since(inicio); real > 5*time.Second {
+		t.Fatalf("tres horas simuladas tardaron %v de verdad", real)
+	}
+}
+
+func TestRelojEscalado(t *testing.T) {
+	reloj := NewRelojEscalado(1000)
+	inicio := reloj.Ahora()
+	reloj.Dormir(10 * time.Second)
+	if transcurrido := reloj.Desde(inicio); transcurrido < 10*time.Second || transcurrido > 100*time.Second {
+		t.Fatalf("se esperaban unos 10s simulados, pasaron %v", transcurrido)
+	}
+	<-reloj.Despues(5 * time.Second)
+}
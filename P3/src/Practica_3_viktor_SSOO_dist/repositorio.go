@@ -0,0 +1,369 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ============================================================================
+// REPOSITORIOS (ALMACENAMIENTO DE LOS MANAGERS)
+// ============================================================================
+
+// Repositorio guarda los registros de un manager identificados por su ID.
+// Los registros llegan ya serializados en JSON.
+type Repositorio interface {
+	Guardar(id int, datos []byte) error
+	Eliminar(id int) error
+	// Cargar devuelve los registros guardados y el mayor ID que se ha llegado
+	// a guardar, aunque ese registro se haya eliminado después
+	Cargar() (map[int][]byte, int, error)
+	Cerrar() error
+}
+
+// RepositorioMemoria guarda los registros en memoria (se pierden al salir)
+type RepositorioMemoria struct {
+	registros map[int][]byte
+	ultimoID  int
+	mutex     sync.Mutex
+}
+
+// NewRepositorioMemoria crea un repositorio vacío en memoria
+func NewRepositorioMemoria() *RepositorioMemoria {
+	return &RepositorioMemoria{
+		registros: make(map[int][]byte),
+	}
+}
+
+func (r *RepositorioMemoria) Guardar(id int, datos []byte) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.registros[id] = datos
+	if id > r.ultimoID {
+		r.ultimoID = id
+	}
+	return nil
+}
+
+func (r *RepositorioMemoria) Eliminar(id int) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delete(r.registros, id)
+	return nil
+}
+
+func (r *RepositorioMemoria) Cargar() (map[int][]byte, int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	copia := make(map[int][]byte, len(r.registros))
+	for id, datos := range r.registros {
+		copia[id] = datos
+	}
+	return copia, r.ultimoID, nil
+}
+
+func (r *RepositorioMemoria) Cerrar() error {
+	return nil
+}
+
+// Operaciones que se anotan en el diario
+const (
+	opGuardar  = "guardar"
+	opEliminar = "eliminar"
+)
+
+// CompactarCadaDefecto es el número de entradas del diario tras el que se
+// escribe un snapshot nuevo y se vacía el diario
+const CompactarCadaDefecto = 100
+
+// entradaDiario es una línea del diario de operaciones
+type entradaDiario struct {
+	Op    string          `json:"op"`
+	ID    int             `json:"id"`
+	Datos json.RawMessage `json:"datos,omitempty"`
+}
+
+// snapshotArchivo es el contenido completo del repositorio en un instante
+type snapshotArchivo struct {
+	UltimoID  int                     `json:"ultimo_id"`
+	Registros map[int]json.RawMessage `json:"registros"`
+}
+
+// RepositorioArchivo guarda los registros en disco usando un diario de solo
+// escritura al final (<nombre>.diario) y un snapshot (<nombre>.snapshot).
+// Cada cambio se añade al diario; cada CompactarCada entradas se vuelca todo
+// al snapshot y el diario se vacía.
+type RepositorioArchivo struct {
+	rutaDiario    string
+	rutaSnapshot  string
+	registros     map[int][]byte
+	ultimoID      int
+	diario        *os.File
+	entradas      int
+	CompactarCada int
+	mutex         sync.Mutex
+}
+
+// NewRepositorioArchivo abre (o crea) el repositorio <nombre> dentro de dir
+// y recupera su contenido a partir del snapshot y el diario
+func NewRepositorioArchivo(dir, nombre string) (*RepositorioArchivo, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("no se pudo crear el directorio %s: %w", dir, err)
+	}
+
+	r := &RepositorioArchivo{
+		rutaDiario:    filepath.Join(dir, nombre+".diario"),
+		rutaSnapshot:  filepath.Join(dir, nombre+".snapshot"),
+		registros:     make(map[int][]byte),
+		CompactarCada: CompactarCadaDefecto,
+	}
+
+	if err := r.leerSnapshot(); err != nil {
+		return nil, err
+	}
+	if err := r.reproducirDiario(); err != nil {
+		return nil, err
+	}
+
+	diario, err := os.OpenFile(r.rutaDiario, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo abrir el diario %s: %w", r.rutaDiario, err)
+	}
+	r.diario = diario
+	return r, nil
+}
+
+func (r *RepositorioArchivo) leerSnapshot() error {
+	contenido, err := os.ReadFile(r.rutaSnapshot)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("no se pudo leer el snapshot %s: %w", r.rutaSnapshot, err)
+	}
+
+	var snap snapshotArchivo
+	if err := json.Unmarshal(contenido, &snap); err != nil {
+		return fmt.Errorf("snapshot %s corrupto: %w", r.rutaSnapshot, err)
+	}
+	for id, datos := range snap.Registros {
+		r.registros[id] = datos
+	}
+	r.ultimoID = snap.UltimoID
+	return nil
+}
+
+func (r *RepositorioArchivo) reproducirDiario() error {
+	f, err := os.Open(r.rutaDiario)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("no se pudo leer el diario %s: %w", r.rutaDiario, err)
+	}
+	defer f.Close()
+
+	lector := bufio.NewReader(f)
+	for linea := 1; ; linea++ {
+		texto, errLectura := lector.ReadBytes('\n')
+		if len(texto) > 0 {
+			var entrada entradaDiario
+			if err := json.Unmarshal(texto, &entrada); err != nil {
+				// Una última línea sin terminar es una escritura que se cortó
+				// (por ejemplo al apagarse el equipo) y se descarta
+				if errLectura != nil {
+					break
+				}
+				return fmt.Errorf("diario %s corrupto en la línea %d: %w", r.rutaDiario, linea, err)
+			}
+			r.aplicar(entrada)
+			r.entradas++
+		}
+		if errLectura != nil {
+			break
+		}
+	}
+	return nil
+}
+
+func (r *RepositorioArchivo) aplicar(entrada entradaDiario) {
+	switch entrada.Op {
+	case opGuardar:
+		r.registros[entrada.ID] = entrada.Datos
+		if entrada.ID > r.ultimoID {
+			r.ultimoID = entrada.ID
+		}
+	case opEliminar:
+		delete(r.registros, entrada.ID)
+	}
+}
+
+func (r *RepositorioArchivo) anotar(entrada entradaDiario) error {
+	linea, err := json.Marshal(entrada)
+	if err != nil {
+		return err
+	}
+	linea = append(linea, '\n')
+
+	if _, err := r.diario.Write(linea); err != nil {
+		return fmt.Errorf("no se pudo escribir en el diario %s: %w", r.rutaDiario, err)
+	}
+	if err := r.diario.Sync(); err != nil {
+		return fmt.Errorf("no se pudo sincronizar el diario %s: %w", r.rutaDiario, err)
+	}
+
+	r.aplicar(entrada)
+	r.entradas++
+	if r.CompactarCada > 0 && r.entradas >= r.CompactarCada {
+		return r.compactar()
+	}
+	return nil
+}
+
+// compactar escribe el snapshot completo y vacía el diario. El snapshot se
+// escribe en un archivo temporal y se renombra para no dejarlo a medias; si
+// se corta antes de vaciar el diario, volver a aplicarlo no cambia nada.
+func (r *RepositorioArchivo) compactar() error {
+	snap := snapshotArchivo{
+		UltimoID:  r.ultimoID,
+		Registros: make(map[int]json.RawMessage, len(r.registros)),
+	}
+	for id, datos := range r.registros {
+		snap.Registros[id] = datos
+	}
+	contenido, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+
+	temporal := r.rutaSnapshot + ".tmp"
+	f, err := os.Create(temporal)
+	if err != nil {
+		return fmt.Errorf("no se pudo crear el snapshot %s: %w", temporal, err)
+	}
+	if _, err := f.Write(contenido); err != nil {
+		f.Close()
+		return fmt.Errorf("no se pudo escribir el snapshot %s: %w", temporal, err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("no se pudo sincronizar el snapshot %s: %w", temporal, err)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(temporal, r.rutaSnapshot); err != nil {
+		return fmt.Errorf("no se pudo reemplazar el snapshot %s: %w", r.rutaSnapshot, err)
+	}
+
+	if err := r.diario.Truncate(0); err != nil {
+		return fmt.Errorf("no se pudo vaciar el diario %s: %w", r.rutaDiario, err)
+	}
+	r.entradas = 0
+	return nil
+}
+
+func (r *RepositorioArchivo) Guardar(id int, datos []byte) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.anotar(entradaDiario{Op: opGuardar, ID: id, Datos: datos})
+}
+
+func (r *RepositorioArchivo) Eliminar(id int) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.anotar(entradaDiario{Op: opEliminar, ID: id})
+}
+
+func (r *RepositorioArchivo) Cargar() (map[int][]byte, int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	copia := make(map[int][]byte, len(r.registros))
+	for id, datos := range r.registros {
+		copia[id] = datos
+	}
+	return copia, r.ultimoID, nil
+}
+
+// Cerrar vuelca un snapshot final y cierra el diario
+func (r *RepositorioArchivo) Cerrar() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.diario == nil {
+		return nil
+	}
+	errCompactar := r.compactar()
+	errCerrar := r.diario.Close()
+	r.diario = nil
+	if errCompactar != nil {
+		return errCompactar
+	}
+	return errCerrar
+}
+
+// ============================================================================
+// SERIALIZACIÓN DE LAS ENTIDADES
+// ============================================================================
+
+// mecanicoGuardado son los datos de un mecánico que se guardan; la cola y el
+// canal se vuelven a crear al cargarlo
+type mecanicoGuardado struct {
+	ID           int          `json:"id"`
+	Nombre       string       `json:"nombre"`
+	Especialidad Especialidad `json:"especialidad"`
+	Experiencia  int          `json:"experiencia"`
+	Activo       bool         `json:"activo"`
+}
+
+func (m *Mecanico) guardado() mecanicoGuardado {
+	return mecanicoGuardado{
+		ID:           m.ID,
+		Nombre:       m.Nombre,
+		Especialidad: m.Especialidad,
+		Experiencia:  m.Experiencia,
+		Activo:       m.Activo,
+	}
+}
+
+// guardarEnRepositorio serializa valor y lo guarda con el ID indicado
+func guardarEnRepositorio(repo Repositorio, id int, valor interface{}) error {
+	datos, err := json.Marshal(valor)
+	if err != nil {
+		return err
+	}
+	return repo.Guardar(id, datos)
+}
+
+// cargarDeRepositorio pasa cada registro de repo a decodificar y devuelve el
+// siguiente ID libre
+func cargarDeRepositorio(repo Repositorio, decodificar func(datos []byte) error) (int, error) {
+	registros, ultimoID, err := repo.Cargar()
+	if err != nil {
+		return 0, err
+	}
+	for id, datos := range registros {
+		if err := decodificar(datos); err != nil {
+			return 0, fmt.Errorf("registro %d ilegible: %w", id, err)
+		}
+		if id > ultimoID {
+			ultimoID = id
+		}
+	}
+	return ultimoID + 1, nil
+}
+
+// avisarErrorPersistencia informa de un cambio que no se pudo guardar en
+// operaciones que no devuelven error
+func avisarErrorPersistencia(err error) {
+	if err != nil {
+		fmt.Printf("AVISO: el cambio no se pudo guardar: %v\n", err)
+	}
+}
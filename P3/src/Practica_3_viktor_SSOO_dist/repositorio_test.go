@@ -0,0 +1,129 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// abrirClientes abre el gestor de clientes guardado en dir
+func abrirClientes(t *testing.T, dir string, compactarCada int) (*ClienteManager, *RepositorioArchivo) {
+	repo, err := NewRepositorioArchivo(dir, "clientes")
+	if err != nil {
+		t.Fatalf("no se pudo abrir el repositorio: %v", err)
+	}
+	repo.CompactarCada = compactarCada
+	cm, err := NewClienteManagerConRepositorio(repo)
+	if err != nil {
+		t.Fatalf("no se pudieron cargar los clientes: %v", err)
+	}
+	return cm, repo
+}
+
+// TestRepositorioArchivo_SobreviveReinicio comprueba que los datos y el
+// siguiente ID se recuperan tanto desde el diario como desde el snapshot
+func TestRepositorioArchivo_SobreviveReinicio(t *testing.T) {
+	for _, compactarCada := range []int{0, 2} {
+		dir := t.TempDir()
+
+		cm, repo := abrirClientes(t, dir, compactarCada)
+		cm.CrearCliente("Ana", "600000001", "ana@test.com")
+		cm.CrearCliente("Luis", "600000002", "luis@test.com")
+		cm.CrearCliente("Eva", "600000003", "eva@test.com")
+		if err := cm.ActualizarCliente(1, "Ana María", "", ""); err != nil {
+			t.Fatal(err)
+		}
+		if err := cm.EliminarCliente(3); err != nil {
+			t.Fatal(err)
+		}
+		// Simulamos una caída: el diario no se cierra de forma ordenada
+		repo.diario.Close()
+
+		cm, repo = abrirClientes(t, dir, compactarCada)
+		if n := len(cm.ListarClientes()); n != 2 {
+			t.Fatalf("compactarCada=%d: esperados 2 clientes, hay %d", compactarCada, n)
+		}
+		if c := cm.clientes[1]; c == nil || c.Nombre != "Ana María" {
+			t.Fatalf("compactarCada=%d: el cliente 1 no conserva la actualización: %+v", compactarCada, c)
+		}
+		// El ID 3 se eliminó pero no se debe reutilizar
+		if c := cm.CrearCliente("Pedro", "600000004", "pedro@test.com"); c.ID != 4 {
+			t.Fatalf("compactarCada=%d: esperado ID 4 tras recargar, obtenido %d", compactarCada, c.ID)
+		}
+		if err := repo.Cerrar(); err != nil {
+			t.Fatal(err)
+		}
+
+		// Tras un cierre ordenado todo queda en el snapshot
+		cm, repo = abrirClientes(t, dir, compactarCada)
+		if c := cm.CrearCliente("Rosa", "600000005", "rosa@test.com"); c.ID != 5 {
+			t.Fatalf("compactarCada=%d: esperado ID 5 tras cerrar, obtenido %d", compactarCada, c.ID)
+		}
+		repo.Cerrar()
+	}
+}
+
+// TestRepositorioArchivo_LineaCortada comprueba que una escritura a medias
+// al final del diario se descarta sin perder lo anterior
+func TestRepositorioArchivo_LineaCortada(t *testing.T) {
+	dir := t.TempDir()
+
+	cm, repo := abrirClientes(t, dir, 0)
+	cm.CrearCliente("Ana", "600000001", "ana@test.com")
+	repo.diario.Close()
+
+	f, err := os.OpenFile(filepath.Join(dir, "clientes.diario"), os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"op":"guardar","id":2,"datos":{"id":2,"nom`)
+	f.Close()
+
+	cm, repo = abrirClientes(t, dir, 0)
+	defer repo.Cerrar()
+	if n := len(cm.ListarClientes()); n != 1 {
+		t.Fatalf("esperado 1 cliente, hay %d", n)
+	}
+}
+
+// TestRepositorioArchivo_Mecanicos comprueba que los mecánicos recuperados
+// tienen su cola y su canal listos para trabajar
+func TestRepositorioArchivo_Mecanicos(t *testing.T) {
+	dir := t.TempDir()
+
+	repo, err := NewRepositorioArchivo(dir, "mecanicos")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mm, err := NewMecanicoManagerConRepositorio(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mm.CrearMecanico("Carlos", EspElectrica, 7)
+	mm.CambiarEstadoActivo(1, false)
+	repo.Cerrar()
+
+	repo, err = NewRepositorioArchivo(dir, "mecanicos")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Cerrar()
+	mm, err = NewMecanicoManagerConRepositorio(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, existe := mm.ObtenerMecanico(1)
+	if !existe {
+		t.Fatal("el mecánico 1 no se ha recuperado")
+	}
+	if m.Especialidad != EspElectrica || m.Experiencia != 7 || m.Activo {
+		t.Fatalf("datos del mecánico incorrectos: %+v", m)
+	}
+	if m.Canal == nil || m.ColaPersonal == nil {
+		t.Fatal("el mecánico recuperado no tiene cola ni canal")
+	}
+	if nuevo := mm.CrearMecanico("Marta", EspMecanica, 3); nuevo.ID != 2 {
+		t.Fatalf("esperado ID 2, obtenido %d", nuevo.ID)
+	}
+}
@@ -1,10 +1,10 @@
 package main
 
 import (
-	"container/heap"
-	"math/rand"
+	"context"
+	"io"
+	"os"
 	"sync"
-	"time"
 )
 
 // ColaPrioridad implementa heap.Interface para ordenar vehículos por prioridad
@@ -41,367 +41,196 @@ func (cp *ColaPrioridad) Pop() interface{} {
 // IMPLEMENTACIÓN CON RWMUTEX
 // ============================================
 
-// SimularTallerRWMutex simula el taller usando RWMutex
-func SimularTallerRWMutex(vehiculos []*Vehiculo, numPlazas, numMecanicos int) {
-	taller := NewTallerSimulacion(numPlazas, numMecanicos)
-	tiempoInicio := time.Now()
-
-	var rwMutex sync.RWMutex
-	var wg sync.WaitGroup
+// SimularTallerRWMutex simula el taller usando RWMutex en tiempo real y
+// devuelve sus métricas, o un *ErrorBloqueo si se queda parado
+func SimularTallerRWMutex(vehiculos []*Vehiculo, numPlazas, numMecanicos int) (*ResultadoSimulacion, error) {
+	return SimularTallerRWMutexConReloj(vehiculos, numPlazas, numMecanicos, RelojReal{})
+}
 
-	// Colas de prioridad para cada fase
-	colaEntrada := make(ColaPrioridad, 0)
-	colaReparacion := make(ColaPrioridad, 0)
-	colaLimpieza := make(ColaPrioridad, 0)
-	colaRevision := make(ColaPrioridad, 0)
-
-	heap.Init(&colaEntrada)
-	heap.Init(&colaReparacion)
-	heap.Init(&colaLimpieza)
-	heap.Init(&colaRevision)
-
-	done := make(chan bool)
-	vehiculosCompletados := 0
-	totalVehiculos := len(vehiculos)
-
-	// Goroutine para procesar Fase 1: Entrada
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		for {
-			select {
-			case <-done:
-				return
-			default:
-				rwMutex.Lock()
-				if colaEntrada.Len() == 0 {
-					rwMutex.Unlock()
-					time.Sleep(50 * time.Millisecond)
-					continue
-				}
-				v := heap.Pop(&colaEntrada).(*Vehiculo)
-				rwMutex.Unlock()
+// SimularTallerRWMutexConReloj es como SimularTallerRWMutex pero todas las
+// esperas y los tiempos del registro van por reloj, y las métricas que
+// devuelve se miden con ese reloj.
+func SimularTallerRWMutexConReloj(vehiculos []*Vehiculo, numPlazas, numMecanicos int, reloj Reloj) (*ResultadoSimulacion, error) {
+	return SimularPipelineRWMutex(PipelineTaller(numPlazas, numMecanicos), vehiculos, reloj)
+}
 
-				taller.PlazasSem <- struct{}{}
+// SimularPipelineRWMutex recorre pipeline con EstrategiaRWMutex escribiendo
+// el registro en la salida estándar
+func SimularPipelineRWMutex(pipeline *Pipeline, vehiculos []*Vehiculo, reloj Reloj) (*ResultadoSimulacion, error) {
+	return EstrategiaRWMutex{}.Simular(context.Background(), pipeline, vehiculos, reloj, os.Stdout)
+}
 
-				v.LogEstado("Entrada", "Esperando", time.Since(tiempoInicio))
-				v.LogEstado("Entrada", "En Proceso", time.Since(tiempoInicio))
-				time.Sleep(v.TiempoFase)
-				v.LogEstado("Entrada", "Completado", time.Since(tiempoInicio))
+// EstrategiaRWMutex tiene una goroutine por trabajador de cada fase
+// esperando en la ColaBloqueante de la fase, que va protegida con un
+// RWMutex. En las fases sin límite cada coche va en su propia goroutine.
+type EstrategiaRWMutex struct{}
 
-				rwMutex.Lock()
-				heap.Push(&colaReparacion, v)
-				rwMutex.Unlock()
-			}
-		}
-	}()
-
-	// Goroutine para procesar Fase 2: Reparación
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		for {
-			select {
-			case <-done:
-				return
-			default:
-				rwMutex.Lock()
-				if colaReparacion.Len() == 0 {
-					rwMutex.Unlock()
-					time.Sleep(50 * time.Millisecond)
-					continue
-				}
-				v := heap.Pop(&colaReparacion).(*Vehiculo)
-				rwMutex.Unlock()
+func (EstrategiaRWMutex) Nombre() string { return "rwmutex" }
 
-				taller.MecanicosSem <- struct{}{}
+func (EstrategiaRWMutex) Simular(ctx context.Context, pipeline *Pipeline, vehiculos []*Vehiculo, reloj Reloj, salida io.Writer) (*ResultadoSimulacion, error) {
+	entorno, ctx := nuevoEntornoSimulacion(ctx, pipeline, reloj, salida)
+	defer entorno.parar()
 
-				v.LogEstado("Reparación", "Esperando", time.Since(tiempoInicio))
-				v.LogEstado("Reparación", "En Proceso", time.Since(tiempoInicio))
-				time.Sleep(v.TiempoFase)
-				v.LogEstado("Reparación", "Completado", time.Since(tiempoInicio))
+	var wg sync.WaitGroup
 
-				<-taller.MecanicosSem
+	// Una cola de prioridad para cada fase
+	colas := make([]*ColaBloqueante, len(pipeline.Fases))
+	for i := range colas {
+		colas[i] = NewColaBloqueante()
+	}
 
-				rwMutex.Lock()
-				heap.Push(&colaLimpieza, v)
-				rwMutex.Unlock()
+	for i, fase := range pipeline.Fases {
+		pasar := func(v *Vehiculo) error {
+			if err := entorno.procesar(ctx, v, i); err != nil {
+				return err
+			}
+			if entorno.ultimaFase(i) {
+				entorno.salir()
+			} else {
+				colas[i+1].Meter(v)
 			}
+			return nil
 		}
-	}()
-
-	// Goroutine para procesar Fase 3: Limpieza
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		for {
-			select {
-			case <-done:
-				return
-			default:
-				rwMutex.Lock()
-				if colaLimpieza.Len() == 0 {
-					rwMutex.Unlock()
-					time.Sleep(50 * time.Millisecond)
-					continue
-				}
-				v := heap.Pop(&colaLimpieza).(*Vehiculo)
-				rwMutex.Unlock()
-
-				v.LogEstado("Limpieza", "Esperando", time.Since(tiempoInicio))
-				v.LogEstado("Limpieza", "En Proceso", time.Since(tiempoInicio))
-				time.Sleep(v.TiempoFase)
-				v.LogEstado("Limpieza", "Completado", time.Since(tiempoInicio))
 
-				rwMutex.Lock()
-				heap.Push(&colaRevision, v)
-				rwMutex.Unlock()
+		if fase.Trabajadores > 0 {
+			for j := 0; j < fase.Trabajadores; j++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for v, err := colas[i].Sacar(ctx); err == nil; v, err = colas[i].Sacar(ctx) {
+						if pasar(v) != nil {
+							return
+						}
+					}
+				}()
 			}
+			continue
 		}
-	}()
-
-	// Goroutine para procesar Fase 4: Revisión Final
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		for {
-			select {
-			case <-done:
-				return
-			default:
-				rwMutex.Lock()
-				if colaRevision.Len() == 0 {
-					rwMutex.Unlock()
-					time.Sleep(50 * time.Millisecond)
-					continue
-				}
-				v := heap.Pop(&colaRevision).(*Vehiculo)
-				rwMutex.Unlock()
-
-				v.LogEstado("Revisión Final", "Esperando", time.Since(tiempoInicio))
-				v.LogEstado("Revisión Final", "En Proceso", time.Since(tiempoInicio))
-				time.Sleep(v.TiempoFase)
-				v.LogEstado("Revisión Final", "Completado", time.Since(tiempoInicio))
 
-				<-taller.PlazasSem
-
-				rwMutex.Lock()
-				vehiculosCompletados++
-				rwMutex.Unlock()
+		// Sin límite de trabajadores cada coche va en su propia goroutine
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for v, err := colas[i].Sacar(ctx); err == nil; v, err = colas[i].Sacar(ctx) {
+				wg.Add(1)
+				go func(v *Vehiculo) {
+					defer wg.Done()
+					pasar(v)
+				}(v)
 			}
-		}
-	}()
-
-	// Agregar vehículos de forma aleatoria
-	vehiculosMezclados := make([]*Vehiculo, len(vehiculos))
-	copy(vehiculosMezclados, vehiculos)
-	rand.Shuffle(len(vehiculosMezclados), func(i, j int) {
-		vehiculosMezclados[i], vehiculosMezclados[j] = vehiculosMezclados[j], vehiculosMezclados[i]
-	})
-
-	for _, v := range vehiculosMezclados {
-		rwMutex.Lock()
-		heap.Push(&colaEntrada, v)
-		rwMutex.Unlock()
-		time.Sleep(time.Duration(rand.Intn(100)) * time.Millisecond)
+		}()
 	}
 
+	entorno.introducir(ctx, vehiculos, func(v *Vehiculo) { colas[0].Meter(v) })
+
 	// Esperar a que todos terminen
-	for vehiculosCompletados < totalVehiculos {
-		time.Sleep(100 * time.Millisecond)
+	duracion, err := entorno.esperarFin(ctx)
+	for _, cola := range colas {
+		cola.Cerrar()
 	}
-
-	time.Sleep(500 * time.Millisecond)
-	close(done)
 	wg.Wait()
+	if err != nil {
+		return nil, err
+	}
+	return entorno.registro.resultado(duracion), nil
 }
 
 // ============================================
 // IMPLEMENTACIÓN CON WAITGROUP
 // ============================================
 
-// SimularTallerWaitGroup simula el taller usando WaitGroup
-func SimularTallerWaitGroup(vehiculos []*Vehiculo, numPlazas, numMecanicos int) {
-	taller := NewTallerSimulacion(numPlazas, numMecanicos)
-	tiempoInicio := time.Now()
-
-	var mutex sync.Mutex
-	var wg sync.WaitGroup
-
-	colaEntrada := make(ColaPrioridad, 0)
-	colaReparacion := make(ColaPrioridad, 0)
-	colaLimpieza := make(ColaPrioridad, 0)
-	colaRevision := make(ColaPrioridad, 0)
-
-	heap.Init(&colaEntrada)
-	heap.Init(&colaReparacion)
-	heap.Init(&colaLimpieza)
-	heap.Init(&colaRevision)
-
-	done := make(chan bool)
-	vehiculosCompletados := 0
-	totalVehiculos := len(vehiculos)
-
-	// Procesador de Fase 1: Entrada
-	go func() {
-		for {
-			select {
-			case <-done:
-				return
-			default:
-				mutex.Lock()
-				if colaEntrada.Len() == 0 {
-					mutex.Unlock()
-					time.Sleep(50 * time.Millisecond)
-					continue
-				}
-				v := heap.Pop(&colaEntrada).(*Vehiculo)
-				mutex.Unlock()
-
-				wg.Add(1)
-				go func(vehiculo *Vehiculo) {
-					defer wg.Done()
+// SimularTallerWaitGroup simula el taller usando WaitGroup en tiempo real y
+// devuelve sus métricas, o un *ErrorBloqueo si se queda parado
+func SimularTallerWaitGroup(vehiculos []*Vehiculo, numPlazas, numMecanicos int) (*ResultadoSimulacion, error) {
+	return SimularTallerWaitGroupConReloj(vehiculos, numPlazas, numMecanicos, RelojReal{})
+}
 
-					taller.PlazasSem <- struct{}{}
+// SimularTallerWaitGroupConReloj es como SimularTallerWaitGroup pero todas las
+// esperas y los tiempos del registro van por reloj, y las métricas que
+// devuelve se miden con ese reloj.
+func SimularTallerWaitGroupConReloj(vehiculos []*Vehiculo, numPlazas, numMecanicos int, reloj Reloj) (*ResultadoSimulacion, error) {
+	return SimularPipelineWaitGroup(PipelineTaller(numPlazas, numMecanicos), vehiculos, reloj)
+}
 
-					vehiculo.LogEstado("Entrada", "Esperando", time.Since(tiempoInicio))
-					vehiculo.LogEstado("Entrada", "En Proceso", time.Since(tiempoInicio))
-					time.Sleep(vehiculo.TiempoFase)
-					vehiculo.LogEstado("Entrada", "Completado", time.Since(tiempoInicio))
+// SimularPipelineWaitGroup recorre pipeline con EstrategiaWaitGroup
+// escribiendo el registro en la salida estándar
+func SimularPipelineWaitGroup(pipeline *Pipeline, vehiculos []*Vehiculo, reloj Reloj) (*ResultadoSimulacion, error) {
+	return EstrategiaWaitGroup{}.Simular(context.Background(), pipeline, vehiculos, reloj, os.Stdout)
+}
 
-					mutex.Lock()
-					heap.Push(&colaReparacion, vehiculo)
-					mutex.Unlock()
-				}(v)
-			}
-		}
-	}()
-
-	// Procesador de Fase 2: Reparación
-	go func() {
-		for {
-			select {
-			case <-done:
-				return
-			default:
-				mutex.Lock()
-				if colaReparacion.Len() == 0 {
-					mutex.Unlock()
-					time.Sleep(50 * time.Millisecond)
-					continue
-				}
-				v := heap.Pop(&colaReparacion).(*Vehiculo)
-				mutex.Unlock()
+// EstrategiaWaitGroup tiene un procesador por fase que espera en la
+// ColaBloqueante de la fase y lanza una goroutine por coche, y espera a
+// todas con un WaitGroup. En las fases con límite de trabajadores el
+// procesador espera a que quede uno libre.
+type EstrategiaWaitGroup struct{}
 
-				wg.Add(1)
-				go func(vehiculo *Vehiculo) {
-					defer wg.Done()
+func (EstrategiaWaitGroup) Nombre() string { return "waitgroup" }
 
-					taller.MecanicosSem <- struct{}{}
+func (EstrategiaWaitGroup) Simular(ctx context.Context, pipeline *Pipeline, vehiculos []*Vehiculo, reloj Reloj, salida io.Writer) (*ResultadoSimulacion, error) {
+	entorno, ctx := nuevoEntornoSimulacion(ctx, pipeline, reloj, salida)
+	defer entorno.parar()
 
-					vehiculo.LogEstado("Reparación", "Esperando", time.Since(tiempoInicio))
-					vehiculo.LogEstado("Reparación", "En Proceso", time.Since(tiempoInicio))
-					time.Sleep(vehiculo.TiempoFase)
-					vehiculo.LogEstado("Reparación", "Completado", time.Since(tiempoInicio))
+	var wg sync.WaitGroup
 
-					<-taller.MecanicosSem
+	colas := make([]*ColaBloqueante, len(pipeline.Fases))
+	for i := range colas {
+		colas[i] = NewColaBloqueante()
+	}
 
-					mutex.Lock()
-					heap.Push(&colaLimpieza, vehiculo)
-					mutex.Unlock()
-				}(v)
-			}
+	// Un procesador por fase
+	for i, fase := range pipeline.Fases {
+		var trabajadores chan struct{}
+		if fase.Trabajadores > 0 {
+			trabajadores = make(chan struct{}, fase.Trabajadores)
 		}
-	}()
-
-	// Procesador de Fase 3: Limpieza
-	go func() {
-		for {
-			select {
-			case <-done:
-				return
-			default:
-				mutex.Lock()
-				if colaLimpieza.Len() == 0 {
-					mutex.Unlock()
-					time.Sleep(50 * time.Millisecond)
-					continue
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for {
+				v, err := colas[i].Sacar(ctx)
+				if err != nil {
+					return
 				}
-				v := heap.Pop(&colaLimpieza).(*Vehiculo)
-				mutex.Unlock()
 
-				wg.Add(1)
-				go func(vehiculo *Vehiculo) {
-					defer wg.Done()
-
-					vehiculo.LogEstado("Limpieza", "Esperando", time.Since(tiempoInicio))
-					vehiculo.LogEstado("Limpieza", "En Proceso", time.Since(tiempoInicio))
-					time.Sleep(vehiculo.TiempoFase)
-					vehiculo.LogEstado("Limpieza", "Completado", time.Since(tiempoInicio))
-
-					mutex.Lock()
-					heap.Push(&colaRevision, vehiculo)
-					mutex.Unlock()
-				}(v)
-			}
-		}
-	}()
-
-	// Procesador de Fase 4: Revisión Final
-	go func() {
-		for {
-			select {
-			case <-done:
-				return
-			default:
-				mutex.Lock()
-				if colaRevision.Len() == 0 {
-					mutex.Unlock()
-					time.Sleep(50 * time.Millisecond)
-					continue
+				if trabajadores != nil {
+					select {
+					case trabajadores <- struct{}{}:
+					case <-ctx.Done():
+						return
+					}
 				}
-				v := heap.Pop(&colaRevision).(*Vehiculo)
-				mutex.Unlock()
-
 				wg.Add(1)
 				go func(vehiculo *Vehiculo) {
 					defer wg.Done()
 
-					vehiculo.LogEstado("Revisión Final", "Esperando", time.Since(tiempoInicio))
-					vehiculo.LogEstado("Revisión Final", "En Proceso", time.Since(tiempoInicio))
-					time.Sleep(vehiculo.TiempoFase)
-					vehiculo.LogEstado("Revisión Final", "Completado", time.Since(tiempoInicio))
-
-					<-taller.PlazasSem
-
-					mutex.Lock()
-					vehiculosCompletados++
-					mutex.Unlock()
+					err := entorno.procesar(ctx, vehiculo, i)
+					if trabajadores != nil {
+						<-trabajadores
+					}
+					if err != nil {
+						return
+					}
+
+					if entorno.ultimaFase(i) {
+						entorno.salir()
+					} else {
+						colas[i+1].Meter(vehiculo)
+					}
 				}(v)
 			}
-		}
-	}()
-
-	// Agregar vehículos de forma aleatoria
-	vehiculosMezclados := make([]*Vehiculo, len(vehiculos))
-	copy(vehiculosMezclados, vehiculos)
-	rand.Shuffle(len(vehiculosMezclados), func(i, j int) {
-		vehiculosMezclados[i], vehiculosMezclados[j] = vehiculosMezclados[j], vehiculosMezclados[i]
-	})
-
-	for _, v := range vehiculosMezclados {
-		mutex.Lock()
-		heap.Push(&colaEntrada, v)
-		mutex.Unlock()
-		time.Sleep(time.Duration(rand.Intn(100)) * time.Millisecond)
+		}(i)
 	}
 
+	entorno.introducir(ctx, vehiculos, func(v *Vehiculo) { colas[0].Meter(v) })
+
 	// Esperar a que todos terminen
-	for vehiculosCompletados < totalVehiculos {
-		time.Sleep(100 * time.Millisecond)
+	duracion, err := entorno.esperarFin(ctx)
+	for _, cola := range colas {
+		cola.Cerrar()
 	}
-
-	time.Sleep(500 * time.Millisecond)
-	close(done)
 	wg.Wait()
+	if err != nil {
+		return nil, err
+	}
+	return entorno.registro.resultado(duracion), nil
 }
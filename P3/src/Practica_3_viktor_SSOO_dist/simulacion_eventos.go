@@ -0,0 +1,258 @@
+package main
+
+import (
+	"container/heap"
+	"io"
+	"time"
+)
+
+// ============================================
+// SIMULACIÓN POR EVENTOS DISCRETOS
+// ============================================
+
+// SimularTallerEventos simula el mismo taller que SimularTallerWaitGroup
+// (una plaza desde la Entrada hasta el final de la Revisión Final, un
+// mecánico durante la Reparación y sin más límites) pero por eventos
+// discretos: no hay goroutines ni esperas, solo una agenda de eventos
+// ordenada por tiempo simulado. Así el resultado es siempre el mismo y un
+// año de taller se simula en segundos.
+//
+// Cada vehículo llega cuando indica su TiempoLlegada, contando desde el que
+// llega primero. En cada fase se atiende antes al de más prioridad y, a
+// igualdad, al que llegó antes. Si salida no es nil se escribe en ella el
+// mismo registro que LogEstado. Si la agenda se vacía con coches sin
+// terminar devuelve un *ErrorBloqueo, como las estrategias con goroutines.
+func SimularTallerEventos(vehiculos []*Vehiculo, numPlazas, numMecanicos int, salida io.Writer) (*ResultadoSimulacion, error) {
+	return SimularPipelineEventos(PipelineTaller(numPlazas, numMecanicos), vehiculos, salida)
+}
+
+// SimularPipelineEventos es SimularTallerEventos con cualquier pipeline. Un
+// coche entra en una fase cuando es el primero de su cola, hay sitio entre
+// los trabajadores de la fase y quedan unidades de todo lo que coge.
+func SimularPipelineEventos(pipeline *Pipeline, vehiculos []*Vehiculo, salida io.Writer) (*ResultadoSimulacion, error) {
+	s := &simulacionEventos{
+		pipeline:     pipeline,
+		planificador: nuevoPlanificador(pipeline),
+		salida:       salida,
+		registro:     nuevoRegistroSimulacion(pipeline),
+	}
+
+	llegadas, primero := llegadasEnOrden(vehiculos)
+	for _, v := range llegadas {
+		s.programar(v.TiempoLlegada.Sub(primero), v, -1)
+	}
+
+	for s.agenda.Len() > 0 {
+		e := heap.Pop(&s.agenda).(*eventoSimulacion)
+		s.ahora = e.instante
+		if e.fase < 0 {
+			s.llegar(e.vehiculo)
+		} else {
+			s.terminar(e.vehiculo, e.fase)
+		}
+	}
+	if _, _, salidos := s.registro.avance(); salidos < len(vehiculos) {
+		return nil, s.registro.bloqueo(s.ahora, len(vehiculos)-salidos)
+	}
+	return s.registro.resultado(s.ahora), nil
+}
+
+type simulacionEventos struct {
+	pipeline     *Pipeline
+	planificador *planificador
+	ahora        time.Duration
+	agenda       agendaEventos
+	secuencia    int
+	salida       io.Writer
+	registro     *registroSimulacion
+}
+
+func (s *simulacionEventos) llegar(v *Vehiculo) {
+	s.registro.llegar(v, s.ahora)
+	s.planificador.encolar(v, 0)
+	s.repartir()
+}
+
+// terminar cierra la fase del vehículo, suelta lo que ya no necesita y lo
+// pasa a la cola de la siguiente
+func (s *simulacionEventos) terminar(v *Vehiculo, fase int) {
+	s.registrar(v, fase, "Completado")
+	s.planificador.soltar(fase, s.registro.terminar(v, fase, s.ahora))
+	if fase+1 < len(s.pipeline.Fases) {
+		s.registro.encolar(v, fase+1, s.ahora)
+		s.planificador.encolar(v, fase+1)
+	}
+	s.repartir()
+}
+
+// repartir mete en cada fase, en orden, a todos los que pueden empezar
+func (s *simulacionEventos) repartir() {
+	for {
+		v, fase, cogidos, ok := s.planificador.siguiente()
+		if !ok {
+			return
+		}
+		s.empezar(v, fase, cogidos)
+	}
+}
+
+func (s *simulacionEventos) empezar(v *Vehiculo, fase int, cogidos []string) {
+	s.registro.empezar(v, fase, cogidos, s.ahora)
+	s.registrar(v, fase, "Esperando")
+	s.registrar(v, fase, "En Proceso")
+	s.programar(s.ahora+s.pipeline.Fases[fase].duracion(v), v, fase)
+}
+
+func (s *simulacionEventos) registrar(v *Vehiculo, fase int, estado string) {
+	if s.salida != nil {
+		v.EscribirEstado(s.salida, s.pipeline.Fases[fase].Nombre, estado, s.ahora)
+	}
+}
+
+// programar apunta en la agenda que en instante pasa algo con v: que llega
+// (fase -1) o que termina la fase
+func (s *simulacionEventos) programar(instante time.Duration, v *Vehiculo, fase int) {
+	s.secuencia++
+	heap.Push(&s.agenda, &eventoSimulacion{instante: instante, orden: s.secuencia, vehiculo: v, fase: fase})
+}
+
+// planificador lleva las colas de las fases y los recursos libres, y decide
+// quién empieza. No sabe nada del tiempo: lo usan la simulación por eventos
+// y EstrategiaPool, que no comparte sus colas con nadie.
+type planificador struct {
+	pipeline *Pipeline
+	libres   map[string]int
+	enFase   []int
+	colas    []ColaPrioridad
+	// repartida dice si la fase coge algún recurso repartido en grupos
+	repartida []bool
+}
+
+func nuevoPlanificador(pipeline *Pipeline) *planificador {
+	p := &planificador{
+		pipeline:  pipeline,
+		libres:    make(map[string]int),
+		enFase:    make([]int, len(pipeline.Fases)),
+		colas:     make([]ColaPrioridad, len(pipeline.Fases)),
+		repartida: make([]bool, len(pipeline.Fases)),
+	}
+	for i, fase := range pipeline.Fases {
+		for _, recurso := range fase.Adquiere {
+			_, repartido := pipeline.Grupos[recurso]
+			p.repartida[i] = p.repartida[i] || repartido
+		}
+	}
+	for recurso, capacidad := range pipeline.Recursos {
+		p.libres[recurso] = capacidad
+	}
+	for i := range p.colas {
+		heap.Init(&p.colas[i])
+	}
+	return p
+}
+
+// encolar pone a v a esperar la fase
+func (p *planificador) encolar(v *Vehiculo, fase int) {
+	heap.Push(&p.colas[fase], v)
+}
+
+// siguiente saca al primero que puede empezar, mirando las fases en orden,
+// y le da sus recursos, que devuelve
+func (p *planificador) siguiente() (*Vehiculo, int, []string, bool) {
+	for fase := range p.colas {
+		if i, cogidos, ok := p.elegir(fase); ok {
+			p.enFase[fase]++
+			for _, recurso := range cogidos {
+				p.libres[recurso]--
+			}
+			return heap.Remove(&p.colas[fase], i).(*Vehiculo), fase, cogidos, true
+		}
+	}
+	return nil, 0, nil, false
+}
+
+// soltar apunta que un coche ha terminado la fase y devuelve los recursos
+// que suelta
+func (p *planificador) soltar(fase int, soltados []string) {
+	p.enFase[fase]--
+	for _, recurso := range soltados {
+		p.libres[recurso]++
+	}
+}
+
+// elegir busca en la cola de la fase al coche que entra y los recursos que
+// coge. Si todos piden lo mismo solo puede ser el primero; si la fase coge
+// recursos repartidos, un coche puede pasar delante de otro de más
+// prioridad que espera a un grupo ocupado.
+func (p *planificador) elegir(fase int) (int, []string, bool) {
+	f := p.pipeline.Fases[fase]
+	cola := p.colas[fase]
+	if cola.Len() == 0 || (f.Trabajadores > 0 && p.enFase[fase] >= f.Trabajadores) {
+		return 0, nil, false
+	}
+	if !p.repartida[fase] {
+		cogidos, ok := p.recursosLibres(cola[0], fase)
+		return 0, cogidos, ok
+	}
+	elegido := -1
+	var cogidosElegido []string
+	for i, v := range cola {
+		if elegido >= 0 && !cola.Less(i, elegido) {
+			continue
+		}
+		if cogidos, ok := p.recursosLibres(v, fase); ok {
+			elegido, cogidosElegido = i, cogidos
+		}
+	}
+	return elegido, cogidosElegido, elegido >= 0
+}
+
+// recursosLibres elige de dónde saca v lo que pide la fase, si hay sitio
+func (p *planificador) recursosLibres(v *Vehiculo, fase int) ([]string, bool) {
+	alternativas := p.pipeline.alternativasFase(fase, v)
+	cogidos := make([]string, len(alternativas))
+	for i, recursos := range alternativas {
+		for _, recurso := range recursos {
+			if p.libres[recurso] > 0 {
+				cogidos[i] = recurso
+				break
+			}
+		}
+		if cogidos[i] == "" {
+			return nil, false
+		}
+	}
+	return cogidos, true
+}
+
+type eventoSimulacion struct {
+	instante time.Duration
+	orden    int
+	vehiculo *Vehiculo
+	fase     int
+}
+
+// agendaEventos implementa heap.Interface por instante y, a igualdad, por
+// el orden en que se programaron
+type agendaEventos []*eventoSimulacion
+
+func (a agendaEventos) Len() int { return len(a) }
+
+func (a agendaEventos) Less(i, j int) bool {
+	if a[i].instante != a[j].instante {
+		return a[i].instante < a[j].instante
+	}
+	return a[i].orden < a[j].orden
+}
+
+func (a agendaEventos) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+
+func (a *agendaEventos) Push(x interface{}) { *a = append(*a, x.(*eventoSimulacion)) }
+
+func (a *agendaEventos) Pop() interface{} {
+	old := *a
+	n := len(old)
+	item := old[n-1]
+	*a = old[:n-1]
+	return item
+}
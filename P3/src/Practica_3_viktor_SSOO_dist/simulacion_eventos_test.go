@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSimularTallerEventos comprueba a mano una simulación pequeña: con una
+// plaza, el coche de prioridad alta adelanta al de baja que llegó a la vez
+func TestSimularTallerEventos(t *testing.T) {
+	vehiculos := []*Vehiculo{
+		NewVehiculo(1, Carroceria),
+		NewVehiculo(2, Carroceria),
+		NewVehiculo(3, Mecanica),
+	}
+	for _, v := range vehiculos {
+		v.TiempoLlegada = vehiculos[0].TiempoLlegada
+	}
+
+	var salida bytes.Buffer
+	resultado, err := SimularTallerEventos(vehiculos, 1, 1, &salida)
+	if err != nil {
+		t.Fatal(err)
+	}
+	duracion := resultado.Duracion
+
+	// El 1 ocupa la plaza 4x1s, luego el 3 4x5s y por último el 2 4x1s
+	if duracion != Duracion(28*time.Second) {
+		t.Fatalf("la simulación duró %v, se esperaban 28s", duracion)
+	}
+
+	lineas := strings.Split(strings.TrimSpace(salida.String()), "\n")
+	if len(lineas) != 3*3*4 {
+		t.Fatalf("se escribieron %d líneas, se esperaban %d", len(lineas), 3*3*4)
+	}
+	esperadas := []string{
+		"Tiempo 0s Coche 1 Incidencia Carrocería Fase Entrada Estado Esperando",
+		"Tiempo 4s Coche 3 Incidencia Mecánica Fase Entrada Estado En Proceso",
+		"Tiempo 24s Coche 3 Incidencia Mecánica Fase Revisión Final Estado Completado",
+		"Tiempo 28s Coche 2 Incidencia Carrocería Fase Revisión Final Estado Completado",
+	}
+	for _, esperada := range esperadas {
+		if !strings.Contains(salida.String(), esperada+"\n") {
+			t.Errorf("falta la línea %q", esperada)
+		}
+	}
+	if lineas[len(lineas)-1] != esperadas[len(esperadas)-1] {
+		t.Errorf("la última línea es %q", lineas[len(lineas)-1])
+	}
+}
+
+func TestSimularTallerEventosDeterminista(t *testing.T) {
+	vehiculos := generarVehiculos(10, 10, 10)
+
+	var primera, segunda bytes.Buffer
+	r1, err1 := SimularTallerEventos(vehiculos, 5, 3, &primera)
+	r2, err2 := SimularTallerEventos(vehiculos, 5, 3, &segunda)
+	if err1 != nil || err2 != nil {
+		t.Fatal(err1, err2)
+	}
+	if r1.Duracion != r2.Duracion || primera.String() != segunda.String() {
+		t.Fatal("dos simulaciones iguales dieron resultados distintos")
+	}
+}
+
+// TestSimularTallerEventosUnAño simula un coche cada cinco minutos durante
+// un año
+func TestSimularTallerEventosUnAño(t *testing.T) {
+	inicio := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	incidencias := []TipoIncidencia{Mecanica, Electrica, Carroceria}
+	var vehiculos []*Vehiculo
+	for i := 0; i < 365*24*12; i++ {
+		v := NewVehiculo(i+1, incidencias[i%len(incidencias)])
+		v.TiempoLlegada = inicio.Add(time.Duration(i) * 5 * time.Minute)
+		vehiculos = append(vehiculos, v)
+	}
+
+	empezado := time.Now()
+	resultado, err := SimularTallerEventos(vehiculos, 5, 3, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	duracion := time.Duration(resultado.Duracion)
+	if duracion < 365*24*time.Hour-5*time.Minute {
+		t.Fatalf("la simulación solo duró %v", duracion)
+	}
+	if real := time.Since(empezado); real > 10*time.Second {
+		t.Fatalf("un año simulado tardó %v de verdad", real)
+	}
+}
+
+// TestSimularEspecialistas comprueba a mano un taller con un mecánico de
+// cada especialidad: los dos coches de mecánica tienen que turnarse aunque el
+// eléctrico esté libre
+func TestSimularEspecialistas(t *testing.T) {
+	pipeline := PipelineTallerEspecialistas(4, map[Especialidad]int{EspMecanica: 1, EspElectrica: 1}, 0)
+	vehiculos := generarVehiculos(2, 2, 0)
+	for _, v := range vehiculos {
+		v.TiempoLlegada = vehiculos[0].TiempoLlegada
+	}
+
+	// El segundo de mecánica repara de 10 a 15s y sale a los 25s; con dos
+	// mecánicos para todo habría salido a los 21s
+	resultado, err := SimularPipelineEventos(pipeline, vehiculos, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resultado.Duracion != Duracion(25*time.Second) {
+		t.Fatalf("la simulación duró %v, se esperaban 25s", resultado.Duracion)
+	}
+	for _, recurso := range []string{RecursoEspecialidad(EspMecanica), RecursoEspecialidad(EspElectrica)} {
+		if resultado.Recursos[recurso] != 1 || resultado.Utilizacion[recurso] <= 0 {
+			t.Errorf("faltan las métricas de %s: %v %v", recurso, resultado.Recursos, resultado.Utilizacion)
+		}
+	}
+}
+
+// TestSimularEspecialistasAdelantar comprueba que un coche de prioridad baja
+// no espera detrás de uno de prioridad alta si su mecánico está libre
+func TestSimularEspecialistasAdelantar(t *testing.T) {
+	pipeline := PipelineTallerEspecialistas(4, map[Especialidad]int{EspMecanica: 1, EspElectrica: 1}, 0)
+	vehiculos := generarVehiculos(2, 1, 0)
+	vehiculos[1].TiempoLlegada = vehiculos[0].TiempoLlegada
+	vehiculos[2].TiempoLlegada = vehiculos[0].TiempoLlegada.Add(6 * time.Second)
+
+	// A los 9s el segundo de mecánica sigue esperando a su mecánico, pero el
+	// eléctrico pasa a reparar sin esperar
+	resultado, err := SimularPipelineEventos(pipeline, vehiculos, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range resultado.Vehiculos {
+		if v.Incidencia == Electrica && v.Espera != 0 {
+			t.Fatalf("el coche eléctrico esperó %v", v.Espera)
+		}
+	}
+}
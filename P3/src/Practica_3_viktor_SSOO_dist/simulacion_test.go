@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"os"
 	"testing"
 	"time"
 )
@@ -26,11 +27,10 @@ func TestCase1(t *testing.T) {
 	fmt.Println("└────────────────────────────────────────────────────────────┘")
 	fmt.Println("")
 
-	inicioRW := time.Now()
-	SimularTallerRWMutex(vehiculos, 5, 3)
-	duracionRW := time.Since(inicioRW)
-
-	time.Sleep(2 * time.Second)
+	resultadoRW, err := simularConRelojAutomatico(SimularPipelineRWMutex, PipelineTaller(5, 3), vehiculos)
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	vehiculos = generarVehiculos(10, 10, 10)
 
@@ -39,16 +39,19 @@ func TestCase1(t *testing.T) {
 	fmt.Println("└────────────────────────────────────────────────────────────┘")
 	fmt.Println("")
 
-	inicioWG := time.Now()
-	SimularTallerWaitGroup(vehiculos, 5, 3)
-	duracionWG := time.Since(inicioWG)
+	resultadoWG, err := simularConRelojAutomatico(SimularPipelineWaitGroup, PipelineTaller(5, 3), vehiculos)
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	// Tiempos finales
 	fmt.Println("════════════════════════════════════════════════════════════════")
 	fmt.Println("    TIEMPOS REGISTRADOS - TEST CASE 1")
 	fmt.Println("════════════════════════════════════════════════════════════════")
-	fmt.Printf("• RWMutex:   %v\n", duracionRW)
-	fmt.Printf("• WaitGroup: %v\n", duracionWG)
+	fmt.Printf("• RWMutex:   %v (simulado)\n", resultadoRW.Duracion)
+	resultadoRW.Imprimir(os.Stdout)
+	fmt.Printf("• WaitGroup: %v (simulado)\n", resultadoWG.Duracion)
+	resultadoWG.Imprimir(os.Stdout)
 	fmt.Println("════════════════════════════════════════════════════════════════")
 	fmt.Println("")
 }
@@ -73,11 +76,10 @@ func TestCase2(t *testing.T) {
 	fmt.Println("└────────────────────────────────────────────────────────────┘")
 	fmt.Println("")
 
-	inicioRW := time.Now()
-	SimularTallerRWMutex(vehiculos, 5, 3)
-	duracionRW := time.Since(inicioRW)
-
-	time.Sleep(2 * time.Second)
+	resultadoRW, err := simularConRelojAutomatico(SimularPipelineRWMutex, PipelineTaller(5, 3), vehiculos)
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	vehiculos = generarVehiculos(20, 5, 5)
 
@@ -86,16 +88,19 @@ func TestCase2(t *testing.T) {
 	fmt.Println("└────────────────────────────────────────────────────────────┘")
 	fmt.Println("")
 
-	inicioWG := time.Now()
-	SimularTallerWaitGroup(vehiculos, 5, 3)
-	duracionWG := time.Since(inicioWG)
+	resultadoWG, err := simularConRelojAutomatico(SimularPipelineWaitGroup, PipelineTaller(5, 3), vehiculos)
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	// Tiempos finales
 	fmt.Println("════════════════════════════════════════════════════════════════")
 	fmt.Println("    TIEMPOS REGISTRADOS - TEST CASE 2")
 	fmt.Println("════════════════════════════════════════════════════════════════")
-	fmt.Printf("• RWMutex:   %v\n", duracionRW)
-	fmt.Printf("• WaitGroup: %v\n", duracionWG)
+	fmt.Printf("• RWMutex:   %v (simulado)\n", resultadoRW.Duracion)
+	resultadoRW.Imprimir(os.Stdout)
+	fmt.Printf("• WaitGroup: %v (simulado)\n", resultadoWG.Duracion)
+	resultadoWG.Imprimir(os.Stdout)
 	fmt.Println("════════════════════════════════════════════════════════════════")
 	fmt.Println("")
 }
@@ -120,11 +125,10 @@ func TestCase3(t *testing.T) {
 	fmt.Println("└────────────────────────────────────────────────────────────┘")
 	fmt.Println("")
 
-	inicioRW := time.Now()
-	SimularTallerRWMutex(vehiculos, 5, 3)
-	duracionRW := time.Since(inicioRW)
-
-	time.Sleep(2 * time.Second)
+	resultadoRW, err := simularConRelojAutomatico(SimularPipelineRWMutex, PipelineTaller(5, 3), vehiculos)
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	vehiculos = generarVehiculos(5, 5, 20)
 
@@ -133,16 +137,19 @@ func TestCase3(t *testing.T) {
 	fmt.Println("└────────────────────────────────────────────────────────────┘")
 	fmt.Println("")
 
-	inicioWG := time.Now()
-	SimularTallerWaitGroup(vehiculos, 5, 3)
-	duracionWG := time.Since(inicioWG)
+	resultadoWG, err := simularConRelojAutomatico(SimularPipelineWaitGroup, PipelineTaller(5, 3), vehiculos)
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	// SECCIÓN MODIFICADA: Solo mostramos los tiempos finales
 	fmt.Println("════════════════════════════════════════════════════════════════")
 	fmt.Println("    TIEMPOS REGISTRADOS - TEST CASE 3")
 	fmt.Println("════════════════════════════════════════════════════════════════")
-	fmt.Printf("• RWMutex:   %v\n", duracionRW)
-	fmt.Printf("• WaitGroup: %v\n", duracionWG)
+	fmt.Printf("• RWMutex:   %v (simulado)\n", resultadoRW.Duracion)
+	resultadoRW.Imprimir(os.Stdout)
+	fmt.Printf("• WaitGroup: %v (simulado)\n", resultadoWG.Duracion)
+	resultadoWG.Imprimir(os.Stdout)
 	fmt.Println("════════════════════════════════════════════════════════════════")
 	fmt.Println("")
 }
@@ -169,3 +176,12 @@ func generarVehiculos(numA, numB, numC int) []*Vehiculo {
 
 	return vehiculos
 }
+
+// simularConRelojAutomatico corre una simulación con goroutines sobre un
+// reloj virtual que avanza solo
+func simularConRelojAutomatico(simular func(*Pipeline, []*Vehiculo, Reloj) (*ResultadoSimulacion, error), pipeline *Pipeline, vehiculos []*Vehiculo) (*ResultadoSimulacion, error) {
+	reloj := NewRelojVirtual(time.Now())
+	parar := reloj.Automatico(50 * time.Microsecond)
+	defer parar()
+	return simular(pipeline, vehiculos, reloj)
+}
@@ -0,0 +1,411 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ============================================================================
+// MODELO DE TIEMPOS
+// ============================================================================
+
+// TipoDistribucion es la forma en que varía un tiempo alrededor de su media
+type TipoDistribucion string
+
+const (
+	DistribucionFija        TipoDistribucion = "fija"
+	DistribucionUniforme    TipoDistribucion = "uniforme"
+	DistribucionExponencial TipoDistribucion = "exponencial"
+	DistribucionLogNormal   TipoDistribucion = "lognormal"
+)
+
+// Distribucion describe cómo se reparte un tiempo. Todas conservan la media,
+// así que cambiar de distribución no cambia el tiempo medio sino cuánto se
+// aleja cada caso de él.
+type Distribucion struct {
+	Tipo TipoDistribucion `json:"tipo"`
+	// Amplitud es, en la uniforme, lo que se aleja como mucho de la media en
+	// proporción (0.2 = ±20 %)
+	Amplitud float64 `json:"amplitud,omitempty"`
+	// Sigma es la desviación típica del logaritmo en la lognormal
+	Sigma float64 `json:"sigma,omitempty"`
+}
+
+func (d Distribucion) validar() error {
+	switch d.Tipo {
+	case "", DistribucionFija, DistribucionExponencial:
+	case DistribucionUniforme:
+		if d.Amplitud < 0 || d.Amplitud > 1 {
+			return fmt.Errorf("la amplitud de la uniforme debe estar entre 0 y 1, es %v", d.Amplitud)
+		}
+	case DistribucionLogNormal:
+		if d.Sigma < 0 {
+			return fmt.Errorf("la sigma de la lognormal no puede ser negativa, es %v", d.Sigma)
+		}
+	default:
+		return fmt.Errorf("distribución '%s' no válida", d.Tipo)
+	}
+	return nil
+}
+
+// muestrear saca un tiempo de media dada
+func (d Distribucion) muestrear(media time.Duration, rng *rand.Rand) time.Duration {
+	factor := 1.0
+	switch d.Tipo {
+	case DistribucionUniforme:
+		factor = 1 + d.Amplitud*(2*rng.Float64()-1)
+	case DistribucionExponencial:
+		factor = rng.ExpFloat64()
+	case DistribucionLogNormal:
+		// exp(N(-σ²/2, σ)) tiene media 1
+		factor = math.Exp(d.Sigma*rng.NormFloat64() - d.Sigma*d.Sigma/2)
+	}
+	return time.Duration(float64(media) * factor)
+}
+
+// TramoExperiencia multiplica por Factor el tiempo de los mecánicos con al
+// menos Desde años de experiencia
+type TramoExperiencia struct {
+	Desde  int     `json:"desde"`
+	Factor float64 `json:"factor"`
+}
+
+// ModeloTiempos calcula lo que duran las reparaciones del taller y las fases
+// de la simulación. Es seguro usarlo desde varias goroutines.
+type ModeloTiempos struct {
+	// Reparacion es el tiempo base de una reparación según el tipo de
+	// incidencia, antes de aplicar la experiencia del mecánico
+	Reparacion map[TipoIncidencia]time.Duration
+	// Fase es lo que dura cada fase de la simulación según la categoría del
+	// vehículo
+	Fase map[TipoIncidencia]time.Duration
+	// Fases es, por categoría, lo que dura cada fase por su nombre; las que
+	// no aparecen duran lo de Fase. Así la carrocería puede tardar mucho en
+	// repararse y poco en limpiarse.
+	Fases map[TipoIncidencia]map[string]time.Duration
+	// Experiencia está ordenada por Desde; se aplica el último tramo al que
+	// llega el mecánico. Sin tramos la experiencia no cuenta.
+	Experiencia []TramoExperiencia
+	// Distribucion se usa para todos los tipos salvo los que tengan una
+	// propia en Distribuciones
+	Distribucion   Distribucion
+	Distribuciones map[TipoIncidencia]Distribucion
+	// Minimo es lo menos que puede durar una reparación
+	Minimo time.Duration
+	// Semilla del generador aleatorio. Con 0 se elige una al primer uso y se
+	// guarda aquí para poder repetir la ejecución.
+	Semilla int64
+
+	rng   *rand.Rand
+	mutex sync.Mutex
+}
+
+// ModeloTiemposPorDefecto da los tiempos de siempre: la reparación tarda
+// 10-Experiencia segundos con un mínimo de 3, y cada fase de la simulación
+// 5, 3 o 1 segundos según la categoría. Todo fijo, sin azar.
+func ModeloTiemposPorDefecto() *ModeloTiempos {
+	m := &ModeloTiempos{
+		Reparacion: map[TipoIncidencia]time.Duration{
+			Mecanica:   10 * time.Second,
+			Electrica:  10 * time.Second,
+			Carroceria: 10 * time.Second,
+		},
+		Fase: map[TipoIncidencia]time.Duration{
+			Mecanica:   5 * time.Second,
+			Electrica:  3 * time.Second,
+			Carroceria: 1 * time.Second,
+		},
+		Fases:          make(map[TipoIncidencia]map[string]time.Duration),
+		Distribucion:   Distribucion{Tipo: DistribucionFija},
+		Distribuciones: make(map[TipoIncidencia]Distribucion),
+		Minimo:         3 * time.Second,
+	}
+	for anios := 0; anios <= 7; anios++ {
+		m.Experiencia = append(m.Experiencia, TramoExperiencia{Desde: anios, Factor: float64(10-anios) / 10})
+	}
+	return m
+}
+
+// Duracion es un time.Duration que en JSON se escribe como texto ("1m30s").
+// Se lee igual de JSON que de YAML.
+// También se aceptan números, que se toman como segundos.
+type Duracion time.Duration
+
+func (d Duracion) String() string { return time.Duration(d).String() }
+
+func (d Duracion) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duracion) UnmarshalJSON(datos []byte) error {
+	var segundos float64
+	if err := json.Unmarshal(datos, &segundos); err == nil {
+		*d = Duracion(segundos * float64(time.Second))
+		return nil
+	}
+	var texto string
+	if err := json.Unmarshal(datos, &texto); err != nil {
+		return fmt.Errorf("duración no válida: %s", datos)
+	}
+	duracion, err := time.ParseDuration(texto)
+	if err != nil {
+		return err
+	}
+	*d = Duracion(duracion)
+	return nil
+}
+
+// UnmarshalYAML acepta lo mismo que UnmarshalJSON
+func (d *Duracion) UnmarshalYAML(nodo *yaml.Node) error {
+	var segundos float64
+	if err := nodo.Decode(&segundos); err == nil {
+		*d = Duracion(segundos * float64(time.Second))
+		return nil
+	}
+	duracion, err := time.ParseDuration(nodo.Value)
+	if err != nil {
+		return fmt.Errorf("línea %d: duración no válida: %s", nodo.Line, nodo.Value)
+	}
+	*d = Duracion(duracion)
+	return nil
+}
+
+// archivoTiempos es el formato del archivo de configuración. Los tipos se
+// escriben como en la API ("mecanica", "electrica", "carroceria").
+type archivoTiempos struct {
+	Reparacion     map[string]Duracion     `json:"reparacion"`
+	Fase           map[string]Duracion     `json:"fase"`
+	Experiencia    []TramoExperiencia      `json:"experiencia"`
+	Distribucion   *Distribucion           `json:"distribucion"`
+	Distribuciones map[string]Distribucion `json:"distribuciones"`
+	Minimo         *Duracion               `json:"minimo"`
+	Semilla        int64                   `json:"semilla"`
+
+	// Fases es, por tipo, lo que dura cada fase por su nombre
+	Fases map[string]map[string]Duracion `json:"fases"`
+}
+
+// CargarModeloTiempos lee el modelo de un archivo JSON. Lo que no aparezca
+// en el archivo se queda como en ModeloTiemposPorDefecto, por ejemplo:
+//
+//	{
+//	  "reparacion": {"mecanica": "8s", "carroceria": "15s"},
+//	  "fases": {"carroceria": {"Reparación": "9s", "Limpieza": "1s"}},
+//	  "experiencia": [{"desde": 0, "factor": 1.2}, {"desde": 5, "factor": 0.8}],
+//	  "distribucion": {"tipo": "lognormal", "sigma": 0.3},
+//	  "semilla": 42
+//	}
+func CargarModeloTiempos(ruta string) (*ModeloTiempos, error) {
+	datos, err := os.ReadFile(ruta)
+	if err != nil {
+		return nil, err
+	}
+	var archivo archivoTiempos
+	if err := json.Unmarshal(datos, &archivo); err != nil {
+		return nil, fmt.Errorf("%s: %w", ruta, err)
+	}
+
+	m := ModeloTiemposPorDefecto()
+	if err := m.aplicar(archivo); err != nil {
+		return nil, fmt.Errorf("%s: %w", ruta, err)
+	}
+	return m, nil
+}
+
+func (m *ModeloTiempos) aplicar(archivo archivoTiempos) error {
+	copiarTiempos := func(destino map[TipoIncidencia]time.Duration, origen map[string]Duracion) error {
+		for nombre, duracion := range origen {
+			tipo, ok := parsearTipoIncidencia(nombre)
+			if !ok {
+				return fmt.Errorf("tipo de incidencia '%s' no válido", nombre)
+			}
+			if duracion < 0 {
+				return fmt.Errorf("el tiempo de %s no puede ser negativo", nombre)
+			}
+			destino[tipo] = time.Duration(duracion)
+		}
+		return nil
+	}
+	if err := copiarTiempos(m.Reparacion, archivo.Reparacion); err != nil {
+		return err
+	}
+	if err := copiarTiempos(m.Fase, archivo.Fase); err != nil {
+		return err
+	}
+	for nombre, fases := range archivo.Fases {
+		tipo, ok := parsearTipoIncidencia(nombre)
+		if !ok {
+			return fmt.Errorf("tipo de incidencia '%s' no válido", nombre)
+		}
+		if m.Fases[tipo] == nil {
+			m.Fases[tipo] = make(map[string]time.Duration)
+		}
+		for fase, duracion := range fases {
+			if duracion < 0 {
+				return fmt.Errorf("el tiempo de %s en %s no puede ser negativo", nombre, fase)
+			}
+			m.Fases[tipo][fase] = time.Duration(duracion)
+		}
+	}
+
+	if archivo.Experiencia != nil {
+		for _, tramo := range archivo.Experiencia {
+			if tramo.Factor <= 0 {
+				return fmt.Errorf("el factor del tramo desde %d años debe ser positivo", tramo.Desde)
+			}
+		}
+		m.Experiencia = append([]TramoExperiencia(nil), archivo.Experiencia...)
+		sort.Slice(m.Experiencia, func(i, j int) bool { return m.Experiencia[i].Desde < m.Experiencia[j].Desde })
+	}
+
+	if archivo.Distribucion != nil {
+		if err := archivo.Distribucion.validar(); err != nil {
+			return err
+		}
+		m.Distribucion = *archivo.Distribucion
+	}
+	for nombre, distribucion := range archivo.Distribuciones {
+		tipo, ok := parsearTipoIncidencia(nombre)
+		if !ok {
+			return fmt.Errorf("tipo de incidencia '%s' no válido", nombre)
+		}
+		if err := distribucion.validar(); err != nil {
+			return fmt.Errorf("%s: %w", nombre, err)
+		}
+		m.Distribuciones[tipo] = distribucion
+	}
+
+	if archivo.Minimo != nil {
+		m.Minimo = time.Duration(*archivo.Minimo)
+	}
+	m.Semilla = archivo.Semilla
+	return nil
+}
+
+// TiempoReparacion es lo que tarda un mecánico con esa experiencia en
+// reparar una incidencia del tipo dado
+func (m *ModeloTiempos) TiempoReparacion(tipo TipoIncidencia, experiencia int) time.Duration {
+	media := time.Duration(float64(m.Reparacion[tipo]) * m.factorExperiencia(experiencia))
+	tiempo := m.muestrear(tipo, media)
+	if tiempo < m.Minimo {
+		tiempo = m.Minimo
+	}
+	return tiempo
+}
+
+// TiempoFase es lo que dura una fase de la simulación para un vehículo de la
+// categoría dada
+func (m *ModeloTiempos) TiempoFase(tipo TipoIncidencia) time.Duration {
+	return m.muestrear(tipo, m.Fase[tipo])
+}
+
+// TiemposFases es lo que duran, para un vehículo de la categoría dada, las
+// fases que tienen un tiempo propio en Fases (nil si no hay ninguna)
+func (m *ModeloTiempos) TiemposFases(tipo TipoIncidencia) map[string]time.Duration {
+	if len(m.Fases[tipo]) == 0 {
+		return nil
+	}
+	// Por orden de nombre, para que con la misma semilla salga lo mismo
+	nombres := make([]string, 0, len(m.Fases[tipo]))
+	for nombre := range m.Fases[tipo] {
+		nombres = append(nombres, nombre)
+	}
+	sort.Strings(nombres)
+	tiempos := make(map[string]time.Duration, len(nombres))
+	for _, nombre := range nombres {
+		tiempos[nombre] = m.muestrear(tipo, m.Fases[tipo][nombre])
+	}
+	return tiempos
+}
+
+func (m *ModeloTiempos) factorExperiencia(experiencia int) float64 {
+	factor := 1.0
+	for _, tramo := range m.Experiencia {
+		if experiencia < tramo.Desde {
+			break
+		}
+		factor = tramo.Factor
+	}
+	return factor
+}
+
+// copiar da otro modelo con los mismos tiempos y semilla pero con su propia
+// secuencia aleatoria
+func (m *ModeloTiempos) copiar() *ModeloTiempos {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	copia := &ModeloTiempos{
+		Reparacion:     make(map[TipoIncidencia]time.Duration, len(m.Reparacion)),
+		Fase:           make(map[TipoIncidencia]time.Duration, len(m.Fase)),
+		Fases:          make(map[TipoIncidencia]map[string]time.Duration, len(m.Fases)),
+		Experiencia:    append([]TramoExperiencia(nil), m.Experiencia...),
+		Distribucion:   m.Distribucion,
+		Distribuciones: make(map[TipoIncidencia]Distribucion, len(m.Distribuciones)),
+		Minimo:         m.Minimo,
+		Semilla:        m.Semilla,
+	}
+	for tipo, tiempo := range m.Reparacion {
+		copia.Reparacion[tipo] = tiempo
+	}
+	for tipo, tiempo := range m.Fase {
+		copia.Fase[tipo] = tiempo
+	}
+	for tipo, fases := range m.Fases {
+		copia.Fases[tipo] = make(map[string]time.Duration, len(fases))
+		for fase, tiempo := range fases {
+			copia.Fases[tipo][fase] = tiempo
+		}
+	}
+	for tipo, distribucion := range m.Distribuciones {
+		copia.Distribuciones[tipo] = distribucion
+	}
+	return copia
+}
+
+// reiniciar hace que la secuencia aleatoria vuelva a empezar desde la
+// semilla
+func (m *ModeloTiempos) reiniciar() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.rng = nil
+}
+
+func (m *ModeloTiempos) muestrear(tipo TipoIncidencia, media time.Duration) time.Duration {
+	distribucion, propia := m.Distribuciones[tipo]
+	if !propia {
+		distribucion = m.Distribucion
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return distribucion.muestrear(media, m.generador())
+}
+
+// ElegirSemilla devuelve la semilla de la que sale la secuencia aleatoria,
+// eligiéndola ya si era 0, para poder apuntarla y repetir la ejecución
+func (m *ModeloTiempos) ElegirSemilla() int64 {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.generador()
+	return m.Semilla
+}
+
+// generador crea el generador aleatorio la primera vez. Hay que tener cogido
+// el mutex.
+func (m *ModeloTiempos) generador() *rand.Rand {
+	if m.rng == nil {
+		if m.Semilla == 0 {
+			m.Semilla = time.Now().UnixNano()
+		}
+		m.rng = rand.New(rand.NewSource(m.Semilla))
+	}
+	return m.rng
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestModeloTiemposPorDefecto comprueba que sin configuración los tiempos son
+// los de antes
+func TestModeloTiemposPorDefecto(t *testing.T) {
+	m := ModeloTiemposPorDefecto()
+
+	for experiencia, esperado := range map[int]time.Duration{
+		0: 10 * time.Second, 4: 6 * time.Second, 7: 3 * time.Second, 15: 3 * time.Second,
+	} {
+		if tiempo := m.TiempoReparacion(Electrica, experiencia); tiempo != esperado {
+			t.Errorf("reparación con %d años: %v, se esperaba %v", experiencia, tiempo, esperado)
+		}
+	}
+	for tipo, esperado := range map[TipoIncidencia]time.Duration{
+		Mecanica: 5 * time.Second, Electrica: 3 * time.Second, Carroceria: time.Second,
+	} {
+		if v := NewVehiculo(1, tipo); v.TiempoFase != esperado {
+			t.Errorf("fase de %s: %v, se esperaba %v", tipo, v.TiempoFase, esperado)
+		}
+	}
+}
+
+func escribirModelo(t *testing.T, contenido string) string {
+	t.Helper()
+	ruta := filepath.Join(t.TempDir(), "tiempos.json")
+	if err := os.WriteFile(ruta, []byte(contenido), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return ruta
+}
+
+// TestCargarModeloTiempos carga un archivo con distribuciones y comprueba
+// medias, límites y que la semilla repite los mismos tiempos
+func TestCargarModeloTiempos(t *testing.T) {
+	ruta := escribirModelo(t, `{
+		"reparacion": {"mecanica": "8s", "carroceria": 20},
+		"experiencia": [{"desde": 5, "factor": 0.5}, {"desde": 0, "factor": 1}],
+		"distribucion": {"tipo": "uniforme", "amplitud": 0.25},
+		"distribuciones": {"carroceria": {"tipo": "lognormal", "sigma": 0.5}},
+		"minimo": "1s",
+		"semilla": 42
+	}`)
+	m, err := CargarModeloTiempos(ruta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Reparacion[Electrica] != 10*time.Second || m.Fase[Mecanica] != 5*time.Second {
+		t.Fatalf("lo que no está en el archivo debía quedar por defecto: %v %v", m.Reparacion, m.Fase)
+	}
+
+	const n = 5000
+	var suma time.Duration
+	for i := 0; i < n; i++ {
+		tiempo := m.TiempoReparacion(Mecanica, 6)
+		if tiempo < 3*time.Second || tiempo > 5*time.Second {
+			t.Fatalf("la uniforme ±25%% de 4s dio %v", tiempo)
+		}
+		suma += tiempo
+	}
+	if media := suma / n; media < 3900*time.Millisecond || media > 4100*time.Millisecond {
+		t.Fatalf("media de la uniforme %v, se esperaban 4s", media)
+	}
+
+	suma = 0
+	for i := 0; i < n; i++ {
+		suma += m.TiempoReparacion(Carroceria, 0)
+	}
+	if media := suma / n; media < 19*time.Second || media > 21*time.Second {
+		t.Fatalf("media de la lognormal %v, se esperaban 20s", media)
+	}
+
+	otro, _ := CargarModeloTiempos(ruta)
+	m, _ = CargarModeloTiempos(ruta)
+	for i := 0; i < 10; i++ {
+		if a, b := m.TiempoReparacion(Carroceria, 0), otro.TiempoReparacion(Carroceria, 0); a != b {
+			t.Fatalf("con la misma semilla se esperaban los mismos tiempos: %v y %v", a, b)
+		}
+	}
+
+	for _, malo := range []string{
+		`{"reparacion": {"pintura": "5s"}}`,
+		`{"distribucion": {"tipo": "normal"}}`,
+		`{"distribucion": {"tipo": "uniforme", "amplitud": 2}}`,
+		`{"experiencia": [{"desde": 0, "factor": 0}]}`,
+		`{"minimo": "rápido"}`,
+		`{"fases": {"pintura": {"Entrada": "1s"}}}`,
+		`{"fases": {"carroceria": {"Entrada": "-1s"}}}`,
+	} {
+		if _, err := CargarModeloTiempos(escribirModelo(t, malo)); err == nil {
+			t.Errorf("se esperaba error con %s", malo)
+		}
+	}
+}
+
+// TestTiemposPorFase da a la carrocería una reparación larga y comprueba que
+// solo cambia esa fase
+func TestTiemposPorFase(t *testing.T) {
+	m, err := CargarModeloTiempos(escribirModelo(t, `{"fases": {"carroceria": {"Reparación": "9s"}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	carroceria := NewVehiculoConTiempos(1, Carroceria, m)
+	if carroceria.TiempoEn("Reparación") != 9*time.Second || carroceria.TiempoEn("Limpieza") != time.Second {
+		t.Fatalf("tiempos de fase inesperados: %v %v", carroceria.TiempoFase, carroceria.TiemposFase)
+	}
+	if mecanica := NewVehiculoConTiempos(2, Mecanica, m); mecanica.TiemposFase != nil {
+		t.Fatalf("la mecánica no tiene tiempos propios y salieron %v", mecanica.TiemposFase)
+	}
+
+	// 1s de entrada, 9s de reparación y 1s de limpieza y de revisión
+	resultado, err := SimularPipelineEventos(PipelineTaller(1, 1), []*Vehiculo{carroceria}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resultado.Duracion != Duracion(12*time.Second) {
+		t.Fatalf("la simulación duró %v, se esperaban 12s", resultado.Duracion)
+	}
+}
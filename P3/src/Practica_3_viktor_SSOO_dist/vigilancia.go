@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ============================================================================
+// VIGILANCIA DE BLOQUEOS
+// ============================================================================
+
+// PlazoBloqueo es el tiempo, medido con el reloj de la simulación, que puede
+// pasar sin que ningún coche llegue, empiece o termine una fase antes de dar
+// la simulación por bloqueada. Solo cuenta si además no hay nadie trabajando
+// en ninguna fase ni se está esperando a que llegue el siguiente coche, así
+// que ni las fases largas ni las horas sin llegadas lo disparan. Con un reloj
+// virtual automático un bloqueo se detecta enseguida: la espera del
+// vigilante es lo único que queda pendiente y el reloj salta hasta ella.
+const PlazoBloqueo = time.Minute
+
+// ErrorBloqueo es el error con el que se aborta una simulación que ha dejado
+// de avanzar. Cuenta dónde estaba cada coche y quién tenía los recursos.
+type ErrorBloqueo struct {
+	Instante   time.Duration
+	Pendientes int
+	Fases      []EstadoFaseBloqueo
+	Recursos   []EstadoRecursoBloqueo
+}
+
+// EstadoFaseBloqueo es cuántos coches esperaban para entrar en una fase y
+// cuántos estaban dentro
+type EstadoFaseBloqueo struct {
+	Fase      string
+	EnCola    int
+	EnProceso int
+}
+
+// EstadoRecursoBloqueo es cuántas unidades de un recurso tenían cogidas los
+// coches
+type EstadoRecursoBloqueo struct {
+	Recurso   string
+	EnUso     int
+	Capacidad int
+}
+
+func (e *ErrorBloqueo) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "simulación bloqueada: %d coches sin terminar y nada se mueve desde hace %v (tiempo %v)",
+		e.Pendientes, PlazoBloqueo, e.Instante.Round(time.Millisecond))
+	for _, f := range e.Fases {
+		fmt.Fprintf(&b, "\n  Fase %s: %d en cola, %d en proceso", f.Fase, f.EnCola, f.EnProceso)
+	}
+	for _, r := range e.Recursos {
+		fmt.Fprintf(&b, "\n  Recurso %s: %d de %d en uso", r.Recurso, r.EnUso, r.Capacidad)
+	}
+	return b.String()
+}
+
+// vigilar cancela ctx con un *ErrorBloqueo si pasa PlazoBloqueo sin
+// progreso, sin nadie trabajando ni llegando y con coches por terminar.
+// Termina con ctx o cuando se cierra parada.
+func (e *entornoSimulacion) vigilar(ctx context.Context, cancelar context.CancelCauseFunc, parada <-chan struct{}) {
+	ultimo := -1
+	for {
+		select {
+		case <-e.reloj.Despues(PlazoBloqueo):
+		case <-ctx.Done():
+			return
+		case <-parada:
+			return
+		}
+
+		progreso, pendientes := e.parado(ultimo)
+		if pendientes > 0 {
+			// Un reloj virtual puede adelantarse a una goroutine que aún no ha
+			// vuelto a correr, así que se confirma con un rato de tiempo real
+			time.Sleep(confirmacionBloqueo)
+			if _, pendientes := e.parado(ultimo); pendientes > 0 {
+				cancelar(e.registro.bloqueo(e.ahora(), pendientes))
+				return
+			}
+		}
+		ultimo = progreso
+	}
+}
+
+// confirmacionBloqueo es el tiempo real que tiene que seguir parada una
+// simulación para darla por bloqueada
+const confirmacionBloqueo = 100 * time.Millisecond
+
+// parado devuelve el progreso y, si la simulación sigue sin avanzar desde
+// ultimo, sin nadie trabajando ni llegando, cuántos coches quedan por
+// terminar (0 si no está parada)
+func (e *entornoSimulacion) parado(ultimo int) (progreso, pendientes int) {
+	e.mutexFin.Lock()
+	total, esperandoLlegada := e.total, e.esperandoLlegada
+	e.mutexFin.Unlock()
+	progreso, trabajando, salidos := e.registro.avance()
+	if progreso != ultimo || trabajando > 0 || esperandoLlegada {
+		return progreso, 0
+	}
+	return progreso, total - salidos
+}
+
+// avance dice cuánto ha progresado la simulación, cuántos coches están
+// dentro de alguna fase y cuántos han salido
+func (r *registroSimulacion) avance() (progreso, trabajando, salidos int) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, f := range r.fases {
+		trabajando += f.enProceso
+	}
+	return r.progreso, trabajando, r.salidos
+}
+
+// bloqueo describe dónde está cada coche y qué recursos tienen cogidos
+func (r *registroSimulacion) bloqueo(ahora time.Duration, pendientes int) *ErrorBloqueo {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	e := &ErrorBloqueo{Instante: ahora, Pendientes: pendientes}
+	for i, f := range r.fases {
+		e.Fases = append(e.Fases, EstadoFaseBloqueo{Fase: r.pipeline.Fases[i].Nombre, EnCola: f.longitud, EnProceso: f.enProceso})
+	}
+	enUso := make(map[string]int)
+	for _, s := range r.vehiculos {
+		for _, cogido := range s.recursos {
+			enUso[cogido.recurso]++
+		}
+	}
+	for recurso, capacidad := range r.pipeline.Recursos {
+		e.Recursos = append(e.Recursos, EstadoRecursoBloqueo{Recurso: recurso, EnUso: enUso[recurso], Capacidad: capacidad})
+	}
+	sort.Slice(e.Recursos, func(i, j int) bool { return e.Recursos[i].Recurso < e.Recursos[j].Recurso })
+	return e
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestVigilanciaBloqueo corre un pipeline que nunca suelta la única plaza
+// (Validar no lo dejaría pasar): el primer coche sale y los demás se quedan
+// esperando para siempre. Todas las estrategias, y las funciones
+// SimularPipeline* que las envuelven, tienen que abortar con un
+// *ErrorBloqueo que cuente dónde se quedaron.
+func TestVigilanciaBloqueo(t *testing.T) {
+	pipeline := NewPipeline().
+		ConRecurso(RecursoPlazas, 1).
+		ConFase(Fase{Nombre: "Entrada", Adquiere: []string{RecursoPlazas}}).
+		ConFase(Fase{Nombre: "Salida"})
+
+	comprobar := func(t *testing.T, err error) {
+		t.Helper()
+		var bloqueo *ErrorBloqueo
+		if !errors.As(err, &bloqueo) {
+			t.Fatalf("se esperaba un *ErrorBloqueo y se obtuvo %v", err)
+		}
+		if bloqueo.Pendientes != 2 || bloqueo.Fases[0].EnCola != 2 || bloqueo.Fases[1].EnProceso != 0 {
+			t.Errorf("estado inesperado: %+v", bloqueo)
+		}
+		if !strings.Contains(err.Error(), "Recurso plazas: 1 de 1 en uso") {
+			t.Errorf("el error no cuenta el estado de las plazas:\n%v", err)
+		}
+	}
+
+	for _, estrategia := range Estrategias() {
+		t.Run(estrategia.Nombre(), func(t *testing.T) {
+			reloj := NewRelojVirtual(time.Now())
+			parar := reloj.Automatico(50 * time.Microsecond)
+			defer parar()
+
+			_, err := estrategia.Simular(context.Background(), pipeline, generarVehiculos(3, 0, 0), reloj, nil)
+			comprobar(t, err)
+		})
+	}
+
+	simulaciones := map[string]func(*Pipeline, []*Vehiculo, Reloj) (*ResultadoSimulacion, error){
+		"SimularPipelineRWMutex":   SimularPipelineRWMutex,
+		"SimularPipelineWaitGroup": SimularPipelineWaitGroup,
+	}
+	for nombre, simular := range simulaciones {
+		t.Run(nombre, func(t *testing.T) {
+			resultado, err := simularConRelojAutomatico(simular, pipeline, generarVehiculos(3, 0, 0))
+			if resultado != nil {
+				t.Errorf("con el bloqueo no debería haber resultado: %+v", resultado)
+			}
+			comprobar(t, err)
+		})
+	}
+	t.Run("SimularPipelineEventos", func(t *testing.T) {
+		_, err := SimularPipelineEventos(pipeline, generarVehiculos(3, 0, 0), nil)
+		comprobar(t, err)
+	})
+}
+
+// TestVigilanciaFaseLarga comprueba que una fase que dura más que
+// PlazoBloqueo no se confunde con un bloqueo
+func TestVigilanciaFaseLarga(t *testing.T) {
+	pipeline := PipelineTaller(1, 1)
+	pipeline.Fases[2].Tiempo = func(*Vehiculo) time.Duration { return 3 * PlazoBloqueo }
+
+	for _, estrategia := range Estrategias() {
+		t.Run(estrategia.Nombre(), func(t *testing.T) {
+			reloj := NewRelojVirtual(time.Now())
+			parar := reloj.Automatico(50 * time.Microsecond)
+			defer parar()
+
+			resultado, err := estrategia.Simular(context.Background(), pipeline, generarVehiculos(1, 1, 0), reloj, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resultado.Global.Vehiculos != 2 {
+				t.Fatalf("salieron %d coches", resultado.Global.Vehiculos)
+			}
+		})
+	}
+}